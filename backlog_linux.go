@@ -0,0 +1,38 @@
+//go:build linux
+
+package appx
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/oy3o/appx/security"
+)
+
+// somaxconn 返回内核允许的最大 listen backlog (net.core.somaxconn)
+func somaxconn() (int64, bool) {
+	v, err := security.ReadSysctl("net.core.somaxconn")
+	return v, err == nil
+}
+
+// setListenBacklog 对已处于 LISTEN 状态的 socket 再次调用 listen(2) 以调整 backlog。
+// Linux 允许重复调用 listen 修改队列长度，超过 somaxconn 的部分会被内核截断。
+func setListenBacklog(ln net.Listener, n int) error {
+	sc, ok := ln.(syscall.Conn)
+	if !ok {
+		return errors.New("listener does not expose a raw socket")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var listenErr error
+	if err := raw.Control(func(fd uintptr) {
+		listenErr = syscall.Listen(int(fd), n)
+	}); err != nil {
+		return err
+	}
+	return listenErr
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package appx
+
+import (
+	"errors"
+	"net"
+)
+
+func somaxconn() (int64, bool) { return 0, false }
+
+func setListenBacklog(ln net.Listener, n int) error {
+	return errors.New("setting listen backlog is only supported on linux")
+}
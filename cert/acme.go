@@ -1,24 +1,246 @@
 package cert
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 )
 
+// accountKeyName 与 autocert 在 Cache 中保存账户私钥使用的 key 一致，
+// 这样未单独配置 AccountKeyFile 时可以无缝复用 autocert 已生成的账户。
+const accountKeyName = "acme_account+key"
+
+// 签发失败后的默认重试间隔。autocert 内部会在失败后冷却 1 分钟，重试间隔不能比它更短。
+const (
+	defaultRetryBackoff    = time.Minute
+	defaultMaxRetryBackoff = time.Hour
+)
+
+// acmeFailure 记录某个域名连续签发失败的次数与下次允许重试的时间
+type acmeFailure struct {
+	count   int
+	retryAt time.Time
+	err     error
+}
+
 func (m *Manager) initACME() {
 	cacheDir := m.cfg.ACME.CacheDir
 	if cacheDir == "" {
 		cacheDir = "./certs-cache"
 	}
 
-	hostPolicy := autocert.HostWhitelist(m.cfg.ACME.Domains...)
-	if len(m.cfg.ACME.Domains) == 0 {
+	// 按需签发 (HTTP-01/TLS-ALPN-01) 的域名交给 autocert，DNS-01 域名由 runDNS01 在后台签发。
+	// 配置已在 New 中校验过，这里不会出错
+	http01, dns01, _ := splitChallenges(m.cfg.ACME)
+	m.dns01Domains = dns01
+	m.dns01Certs = make(map[string]*tls.Certificate)
+
+	hostPolicy := autocert.HostWhitelist(http01...)
+	if len(http01) == 0 && len(dns01) == 0 {
 		m.logger.Warn().Msg("ACME Domains are empty. HostPolicy will deny all requests. Please specify domains in config.")
 	}
 
 	m.acmeManager = &autocert.Manager{
 		Prompt:     autocert.AcceptTOS,
 		HostPolicy: hostPolicy,
-		Cache:      autocert.DirCache(cacheDir),
+		Cache:      &observedCache{Cache: autocert.DirCache(cacheDir), onCertPut: m.acmeIssued},
 		Email:      m.cfg.ACME.Email,
 	}
+	m.loadDNS01Cache(context.Background())
+
+	// 显式加载账户私钥，保证重启后复用同一个 ACME 账户，避免触发账户注册频率限制
+	keyFile := m.cfg.ACME.AccountKeyFile
+	if keyFile == "" {
+		keyFile = filepath.Join(cacheDir, accountKeyName)
+	}
+	key, created, err := loadOrCreateAccountKey(keyFile)
+	if err != nil {
+		m.logger.Error().Err(err).Str("file", keyFile).Msg("Failed to load ACME account key, a new account will be registered")
+		return
+	}
+	m.acmeManager.Client = &acme.Client{Key: key}
+
+	thumbprint, _ := acme.JWKThumbprint(key.Public())
+	m.logger.Info().
+		Str("file", keyFile).
+		Str("thumbprint", thumbprint).
+		Bool("created", created).
+		Msg("ACME account key loaded")
+}
+
+// loadOrCreateAccountKey 从 path 加载 PEM 格式的账户私钥，不存在时生成 ECDSA P-256 私钥并以 0600 权限保存
+func loadOrCreateAccountKey(path string) (crypto.Signer, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := parseAccountKey(data)
+		return key, false, err
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, false, err
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, pemData, 0o600); err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// parseAccountKey 解析 PEM 格式的 EC/RSA/PKCS#8 私钥
+func parseAccountKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("acme account key: no PEM block found")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("acme account key: unsupported key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("acme account key: unsupported PEM type %q", block.Type)
+	}
+}
+
+// acmeGetCertificate 包装 autocert 的 GetCertificate，为签发失败的域名提供指数退避并记录失败指标。
+// 退避期间的握手直接返回上一次的错误，不会再次触发签发。
+func (m *Manager) acmeGetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	// DNS-01 证书已在后台签发，按 SNI 直接命中 (含通配符)
+	if idx := m.dnsCerts.Load(); idx != nil {
+		if cert := idx.lookup(hello.ServerName); cert != nil {
+			return m.stapleOCSP(cert), nil
+		}
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if f, backoff := m.acmeBackoff(name); backoff {
+		return nil, fmt.Errorf("cert manager: ACME issuance for %s backing off until %s: %w",
+			name, f.retryAt.Format(time.RFC3339), f.err)
+	}
+
+	cert, err := m.acmeManager.GetCertificate(hello)
+	if err == nil {
+		return m.stapleOCSP(cert), nil
+	}
+	if name == "" {
+		return cert, err
+	}
+	// 不在白名单中的域名不会触发签发，不计入失败
+	if m.acmeManager.HostPolicy != nil && m.acmeManager.HostPolicy(context.Background(), name) != nil {
+		return cert, err
+	}
+
+	m.acmeFailed(name, err)
+	return nil, err
+}
+
+// acmeBackoff 返回域名最近一次签发失败的记录，以及当前是否处于退避期间
+func (m *Manager) acmeBackoff(name string) (acmeFailure, bool) {
+	m.acmeMu.Lock()
+	f, failed := m.acmeFailures[name]
+	m.acmeMu.Unlock()
+	return f, failed && time.Now().Before(f.retryAt)
+}
+
+// acmeFailed 记录一次签发失败并计算下次允许重试的时间
+func (m *Manager) acmeFailed(name string, err error) {
+	m.acmeMu.Lock()
+	if m.acmeFailures == nil {
+		m.acmeFailures = make(map[string]acmeFailure)
+	}
+	f := m.acmeFailures[name]
+	f.count++
+	f.err = err
+	backoff := m.retryBackoff(f.count)
+	f.retryAt = time.Now().Add(backoff)
+	m.acmeFailures[name] = f
+	m.acmeMu.Unlock()
+
+	acmeIssuanceTotal.WithLabelValues(issuanceFailure).Inc()
+	m.logger.Warn().Err(err).
+		Str("domain", name).
+		Int("attempt", f.count).
+		Dur("retry_in", backoff).
+		Msg("ACME certificate issuance failed")
+}
+
+// retryBackoff 返回第 n 次失败后的重试间隔
+func (m *Manager) retryBackoff(n int) time.Duration {
+	base := max(m.cfg.ACME.RetryBackoff, defaultRetryBackoff)
+	limit := m.cfg.ACME.MaxRetryBackoff
+	if limit <= 0 {
+		limit = defaultMaxRetryBackoff
+	}
+
+	d := base
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// acmeIssued 在 autocert 将新签发 (或续期) 的证书写入 Cache 时调用
+func (m *Manager) acmeIssued(domain string) {
+	m.acmeMu.Lock()
+	delete(m.acmeFailures, domain)
+	m.acmeMu.Unlock()
+
+	acmeIssuanceTotal.WithLabelValues(issuanceSuccess).Inc()
+	m.logger.Info().Str("domain", domain).Msg("ACME certificate issued")
+}
+
+// observedCache 包装 autocert.Cache，在证书写入时回调，用于观测签发与续期成功。
+// autocert 只会在拿到新证书后写入证书条目，加载已有缓存不会触发。
+type observedCache struct {
+	autocert.Cache
+	onCertPut func(domain string)
+}
+
+func (c *observedCache) Put(ctx context.Context, key string, data []byte) error {
+	if err := c.Cache.Put(ctx, key, data); err != nil {
+		return err
+	}
+	// 跳过挑战令牌与账户私钥等非证书条目；DNS-01 证书在签发流程中单独记录
+	if strings.HasSuffix(key, "+token") || strings.HasSuffix(key, "+http-01") || strings.HasSuffix(key, dns01CacheSuffix) ||
+		strings.HasPrefix(key, "acme_account") {
+		return nil
+	}
+	c.onCertPut(strings.TrimSuffix(key, "+rsa"))
+	return nil
 }
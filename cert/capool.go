@@ -0,0 +1,133 @@
+package cert
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// CAPool 从目录加载 PEM 格式的 CA 证书，并在目录内容变化时原子地重建证书池。
+// 用于 mTLS 客户端 CA 轮换：与 Manager 的服务端证书一样，无需重启即可生效。
+type CAPool struct {
+	dir    string
+	logger *zerolog.Logger
+
+	pool atomic.Pointer[x509.CertPool]
+	// signature 是上一次成功加载时目录内容的摘要 (文件名、大小、修改时间)
+	signature atomic.Pointer[string]
+
+	startOnce sync.Once
+}
+
+// NewCAPool 加载 dir 下所有文件中的 PEM 证书 (不递归子目录)。
+// 目录不可读或没有任何证书时返回错误。
+func NewCAPool(dir string, logger *zerolog.Logger) (*CAPool, error) {
+	p := &CAPool{dir: dir, logger: logger}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Pool 返回当前的证书池 (Lock-free)，可在 tls.Config.GetConfigForClient 中使用
+func (p *CAPool) Pool() *x509.CertPool {
+	return p.pool.Load()
+}
+
+// Start 启动目录监听，按与证书文件相同的周期检查目录变化，ctx 结束时停止。只会启动一次。
+func (p *CAPool) Start(ctx context.Context) {
+	p.startOnce.Do(func() {
+		go p.watch(ctx)
+	})
+}
+
+// Reload 立即重新加载目录。加载失败时保留原有的证书池。
+func (p *CAPool) Reload() error {
+	sig, err := p.dirSignature()
+	if err != nil {
+		return fmt.Errorf("ca pool: %w", err)
+	}
+
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return fmt.Errorf("ca pool: %w", err)
+	}
+	pool := x509.NewCertPool()
+	var files int
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(p.dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("ca pool: %w", err)
+		}
+		if pool.AppendCertsFromPEM(data) {
+			files++
+		}
+	}
+	if files == 0 {
+		return fmt.Errorf("ca pool: no PEM certificates found in %s", p.dir)
+	}
+
+	// 原子替换，进行中的握手继续使用旧的证书池
+	p.pool.Store(pool)
+	p.signature.Store(&sig)
+	p.logger.Info().Str("dir", p.dir).Int("files", files).Msg("Client CA pool loaded")
+	return nil
+}
+
+// watch 定期检查目录是否变化
+func (p *CAPool) watch(ctx context.Context) {
+	ticker := time.NewTicker(fileWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sig, err := p.dirSignature()
+			if err != nil {
+				p.logger.Warn().Err(err).Str("dir", p.dir).Msg("Failed to stat client CA directory")
+				continue
+			}
+			if last := p.signature.Load(); last != nil && *last == sig {
+				continue
+			}
+			if err := p.Reload(); err != nil {
+				p.logger.Error().Err(err).Msg("Failed to reload client CA pool")
+			}
+		}
+	}
+}
+
+// dirSignature 返回目录中普通文件的名称、大小与修改时间摘要
+func (p *CAPool) dirSignature() (string, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", e.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|"), nil
+}
@@ -1,23 +1,113 @@
 package cert
 
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
 // ACME (Let's Encrypt) 配置
 type ACME struct {
 	Enabled  bool     `mapstructure:"enabled" yaml:"enabled"`
 	Email    string   `mapstructure:"email" yaml:"email"`
 	Domains  []string `mapstructure:"domains" yaml:"domains"`
 	CacheDir string   `mapstructure:"cache_dir" yaml:"cache_dir"`
+	// AccountKeyFile ACME 账户私钥路径 (PEM)，不存在时自动生成。
+	// 默认保存在 CacheDir 中；在容器等 CacheDir 不持久的环境下，应指向持久化存储，
+	// 否则每次重启都会注册新账户，容易触发 CA 的账户创建频率限制。
+	AccountKeyFile string `mapstructure:"account_key_file" yaml:"account_key_file"`
+	// RetryBackoff 某个域名签发失败后的首次重试间隔，之后每次失败翻倍 (默认 1 分钟)。
+	// 不能小于 autocert 内置的 1 分钟失败冷却时间，小于时按 1 分钟处理。
+	RetryBackoff time.Duration `mapstructure:"retry_backoff" yaml:"retry_backoff"`
+	// MaxRetryBackoff 重试间隔的上限 (默认 1 小时)
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff" yaml:"max_retry_backoff"`
+	// Challenge 是 Domains 使用的挑战类型: http-01 (默认) | dns-01。
+	// 设为 dns-01 时 Domains 可以包含通配符域名 (如 *.example.com)，并且必须设置 DNSProvider；
+	// 80/443 端口无法从公网访问的主机也应使用 dns-01。
+	Challenge string `mapstructure:"challenge" yaml:"challenge"`
+	// Challenges 按域名选择挑战类型，可与 Domains 同时使用 (Domains 中的域名使用 Challenge)。
+	// 通配符域名 (如 *.example.com) 只能通过 DNS-01 验证；例如同时服务 example.com 与 *.example.com 时，
+	// 顶级域名使用 HTTP-01，通配符使用 DNS-01。
+	Challenges []DomainChallenge `mapstructure:"challenges" yaml:"challenges"`
+	// DNSProvider 负责发布/清理 DNS-01 挑战的 TXT 记录，存在 DNS-01 域名时必须设置
+	DNSProvider DNSProvider `mapstructure:"-" yaml:"-"`
+}
+
+// ACME 挑战类型 (ACME.Challenge 与 DomainChallenge.Challenge)
+const (
+	// ChallengeHTTP01 通过 80 端口的 HTTP 请求验证 (由 Manager.HTTPHandler 应答)，也会尝试 TLS-ALPN-01
+	ChallengeHTTP01 = "http-01"
+	// ChallengeDNS01 通过 _acme-challenge TXT 记录验证，支持通配符域名
+	ChallengeDNS01 = "dns-01"
+)
+
+// DomainChallenge 指定某个域名使用的 ACME 挑战类型，Challenge 留空表示使用 ACME.Challenge
+type DomainChallenge struct {
+	Domain    string `mapstructure:"domain" yaml:"domain"`
+	Challenge string `mapstructure:"challenge" yaml:"challenge"`
+}
+
+// 证书来源模式 (Config.Mode)
+const (
+	// ModeOff 不开启 TLS，New 返回 nil
+	ModeOff = "off"
+	// ModeSelfSigned 生成临时自签名证书，仅用于开发/测试环境
+	ModeSelfSigned = "self-signed"
+	// ModeManual 只使用 CertFile/KeyFile，加载失败时 New 返回错误
+	ModeManual = "manual"
+	// ModeACME 只使用 ACME 自动签发
+	ModeACME = "acme"
+	// ModeAuto 证书文件可用时使用手动证书，否则 (或即将过期时) 使用 ACME
+	ModeAuto = "auto"
+)
+
+// 证书文件的变化检测方式 (Config.WatchMode)
+const (
+	WatchPoll     = "poll"
+	WatchFSNotify = "fsnotify"
+)
+
+// KeyPair 是一组证书与私钥文件路径
+type KeyPair struct {
+	CertFile string `mapstructure:"cert_file" yaml:"cert_file"`
+	KeyFile  string `mapstructure:"key_file" yaml:"key_file"`
 }
 
 type Config struct {
+	// Mode 选择证书来源: off | self-signed | manual | acme | auto。
+	// 留空时保持旧行为：加载手动证书，ACME.Enabled 时作为降级。
+	Mode string `mapstructure:"mode" yaml:"mode"`
+
 	// 手动证书路径
 	CertFile string `mapstructure:"cert_file" yaml:"cert_file"`
 	KeyFile  string `mapstructure:"key_file" yaml:"key_file"`
 
+	// Certificates 按域名区分的额外手动证书，根据客户端 SNI 与证书的 DNS SAN 匹配选择 (支持通配符)。
+	// 未匹配任何证书的握手使用 CertFile/KeyFile；文件变化时与 CertFile 一同热重载，
+	// 每一对证书独立加载，某一对损坏时继续使用它的旧证书，不影响其余证书的更新。
+	Certificates []KeyPair `mapstructure:"certificates" yaml:"certificates"`
+
 	ACME ACME `mapstructure:"acme" yaml:"acme"`
 
+	// WatchInterval 轮询证书文件变化的周期 (默认 1 分钟)
+	WatchInterval time.Duration `mapstructure:"watch_interval" yaml:"watch_interval"`
+	// WatchMode 证书文件的变化检测方式: poll (默认) | fsnotify。
+	// fsnotify 监听证书所在目录，文件被替换 (含重命名、切换符号链接) 后立即重载，仍按 WatchInterval 轮询兜底；
+	// 当前平台不支持 fsnotify 时自动降级为轮询。
+	WatchMode string `mapstructure:"watch_mode" yaml:"watch_mode"`
+
 	// 降级阈值：如果手动证书还有多少天过期，就切换到 ACME (默认 30 天)
 	// 如果为 0，表示只有文件不存在或已完全过期才切换
 	FallbackThresholdDays int `mapstructure:"fallback_threshold_days" yaml:"fallback_threshold_days"`
+
+	// DisableOCSPStapling 关闭 OCSP Stapling。默认对链中带有签发者、且声明了 OCSP 服务器的证书
+	// (手动与 ACME 证书) 自动获取 OCSP 响应并在握手中附带，客户端无需自行查询证书吊销状态。
+	// 无法访问 CA 的 OCSP 服务器 (如隔离网络) 时可关闭。
+	DisableOCSPStapling bool `mapstructure:"disable_ocsp_stapling" yaml:"disable_ocsp_stapling"`
+
+	// Registerer 注册证书指标 (appx_cert_*) 的 Prometheus Registerer，为 nil 时使用默认 Registry。
+	// 测试中可传入独立的 prometheus.NewRegistry()，避免多个 Manager 共享全局指标。
+	Registerer prometheus.Registerer `mapstructure:"-" yaml:"-"`
 }
 
 func DefaultConfig() Config {
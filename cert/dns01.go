@@ -0,0 +1,278 @@
+package cert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// DNSProvider 发布与清理 DNS-01 挑战的 TXT 记录，通常对接 DNS 服务商的 API。
+// fqdn 形如 "_acme-challenge.example.com" (通配符域名使用其父域名)，value 为 TXT 记录的内容。
+// Present 返回时记录应已可被 CA 查询到，必要时由实现自行等待 DNS 传播。
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+const (
+	// dns01CacheSuffix 是 DNS-01 证书在 ACME Cache 中的 key 后缀，与 autocert 的条目区分
+	dns01CacheSuffix = "+dns01"
+	// dns01RenewBefore 证书剩余有效期不足该值时续期
+	dns01RenewBefore = 30 * 24 * time.Hour
+	// dns01CheckInterval 检查是否需要签发/续期的间隔
+	dns01CheckInterval = time.Hour
+	// dns01Timeout 单次签发 (含等待 CA 验证) 的最长时间
+	dns01Timeout = 10 * time.Minute
+)
+
+// splitChallenges 按挑战类型拆分 ACME 域名，并校验通配符域名只使用 DNS-01。
+// Domains 使用 ACME.Challenge 指定的挑战类型，Challenges 中未指定挑战类型的条目同样使用 ACME.Challenge。
+func splitChallenges(cfg ACME) (http01, dns01 []string, err error) {
+	add := func(domain, challenge string) error {
+		if challenge == "" {
+			challenge = cfg.Challenge
+		}
+		switch challenge {
+		case "", ChallengeHTTP01:
+			if strings.HasPrefix(domain, "*.") {
+				return fmt.Errorf("wildcard domain %q requires the %s challenge", domain, ChallengeDNS01)
+			}
+			http01 = append(http01, domain)
+		case ChallengeDNS01:
+			dns01 = append(dns01, domain)
+		default:
+			return fmt.Errorf("unknown ACME challenge %q for domain %q", challenge, domain)
+		}
+		return nil
+	}
+
+	switch cfg.Challenge {
+	case "", ChallengeHTTP01, ChallengeDNS01:
+	default:
+		return nil, nil, fmt.Errorf("unknown ACME challenge %q", cfg.Challenge)
+	}
+	for _, domain := range cfg.Domains {
+		if err := add(domain, ""); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, dc := range cfg.Challenges {
+		domain := strings.ToLower(strings.TrimSuffix(dc.Domain, "."))
+		if domain == "" {
+			return nil, nil, errors.New("ACME challenge entry with empty domain")
+		}
+		if err := add(domain, dc.Challenge); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(dns01) > 0 && cfg.DNSProvider == nil {
+		return nil, nil, fmt.Errorf("ACME.DNSProvider is required for %s domains %v", ChallengeDNS01, dns01)
+	}
+	return http01, dns01, nil
+}
+
+// loadDNS01Cache 从 ACME Cache 恢复上次签发的 DNS-01 证书，避免重启后重复签发
+func (m *Manager) loadDNS01Cache(ctx context.Context) {
+	for _, domain := range m.dns01Domains {
+		data, err := m.acmeManager.Cache.Get(ctx, domain+dns01CacheSuffix)
+		if err != nil {
+			continue
+		}
+		cert, err := parseCertPEM(data)
+		if err != nil {
+			m.logger.Warn().Err(err).Str("domain", domain).Msg("Ignoring invalid cached DNS-01 certificate")
+			continue
+		}
+		if time.Now().After(cert.Leaf.NotAfter) {
+			continue
+		}
+		m.dns01Certs[domain] = cert
+		m.registerOCSP(cert)
+	}
+	m.publishDNS01()
+}
+
+// publishDNS01 重建 DNS-01 证书的 SNI 索引 (copy-on-write)
+func (m *Manager) publishDNS01() {
+	idx := newSNIIndex()
+	for domain, cert := range m.dns01Certs {
+		idx.add(cert, []string{domain})
+	}
+	m.dnsCerts.Store(idx)
+}
+
+// runDNS01 在后台签发并续期 DNS-01 域名的证书。
+// 与按需签发的 HTTP-01 不同，DNS-01 证书在启动后立即签发，之后每小时检查一次是否需要续期。
+func (m *Manager) runDNS01(ctx context.Context) {
+	ticker := time.NewTicker(dns01CheckInterval)
+	defer ticker.Stop()
+	for {
+		m.renewDNS01(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// renewDNS01 签发缺失或即将过期的 DNS-01 证书，失败的域名按 ACME 重试间隔退避
+func (m *Manager) renewDNS01(ctx context.Context) {
+	for _, domain := range m.dns01Domains {
+		if cert := m.dns01Certs[domain]; cert != nil && time.Until(cert.Leaf.NotAfter) > dns01RenewBefore {
+			continue
+		}
+		if _, backoff := m.acmeBackoff(domain); backoff {
+			continue
+		}
+
+		cert, err := m.obtainDNS01(ctx, domain)
+		if err != nil {
+			if ctx.Err() == nil {
+				m.acmeFailed(domain, err)
+			}
+			continue
+		}
+		old := m.dns01Certs[domain]
+		m.dns01Certs[domain] = cert
+		m.publishDNS01()
+		m.registerOCSP(cert)
+		m.unregisterOCSP(old)
+		m.acmeIssued(domain)
+	}
+}
+
+// obtainDNS01 通过 DNS-01 挑战为 domain 签发证书，并写入 ACME Cache
+func (m *Manager) obtainDNS01(ctx context.Context, domain string) (*tls.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, dns01Timeout)
+	defer cancel()
+
+	client := m.acmeManager.Client
+	if client == nil {
+		return nil, errors.New("ACME account key unavailable")
+	}
+	acct := &acme.Account{}
+	if m.cfg.ACME.Email != "" {
+		acct.Contact = []string{"mailto:" + m.cfg.ACME.Email}
+	}
+	if _, err := client.Register(ctx, acct, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("register ACME account: %w", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range order.AuthzURLs {
+		if err := m.solveDNS01(ctx, client, u); err != nil {
+			return nil, err
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{domain}}, key)
+	if err != nil {
+		return nil, err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := encodeCertPEM(chain, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.acmeManager.Cache.Put(ctx, domain+dns01CacheSuffix, data); err != nil {
+		m.logger.Warn().Err(err).Str("domain", domain).Msg("Failed to cache DNS-01 certificate")
+	}
+	return parseCertPEM(data)
+}
+
+// solveDNS01 完成一个授权的 DNS-01 挑战：发布 TXT 记录、通知 CA 验证并等待结果，结束后清理记录
+func (m *Manager) solveDNS01(ctx context.Context, client *acme.Client, authzURL string) error {
+	z, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return err
+	}
+	if z.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == ChallengeDNS01 {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("CA offered no %s challenge for %s", ChallengeDNS01, z.Identifier.Value)
+	}
+
+	value, err := client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return err
+	}
+	fqdn := "_acme-challenge." + z.Identifier.Value
+	provider := m.cfg.ACME.DNSProvider
+	if err := provider.Present(ctx, fqdn, value); err != nil {
+		return fmt.Errorf("present TXT record %s: %w", fqdn, err)
+	}
+	defer func() {
+		if err := provider.CleanUp(context.WithoutCancel(ctx), fqdn, value); err != nil {
+			m.logger.Warn().Err(err).Str("fqdn", fqdn).Msg("Failed to clean up DNS-01 TXT record")
+		}
+	}()
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return err
+	}
+	_, err = client.WaitAuthorization(ctx, z.URI)
+	return err
+}
+
+// encodeCertPEM 将私钥与证书链编码为单个 PEM 文件 (私钥在前，与 autocert 的缓存格式一致)
+func encodeCertPEM(chain [][]byte, key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	for _, c := range chain {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c})...)
+	}
+	return data, nil
+}
+
+// parseCertPEM 解析 encodeCertPEM 生成的 PEM 文件
+func parseCertPEM(data []byte) (*tls.Certificate, error) {
+	// X509KeyPair 从证书参数中只取 CERTIFICATE 块，从私钥参数中只取私钥块，因此可以传入同一份数据
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &cert, nil
+}
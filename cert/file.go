@@ -2,96 +2,285 @@ package cert
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
-// watchFileChanges 定期检查证书文件状态
-func (m *Manager) watchFileChanges(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+// clockDriftTolerance 是墙上时钟与单调时钟之间可容忍的偏差
+const clockDriftTolerance = time.Minute
+
+// fileWatchInterval 是检查证书文件 (及 CA 目录) 变化的默认周期
+const fileWatchInterval = time.Minute
+
+// fsnotifyDebounce 合并短时间内的多个文件事件 (证书与私钥通常先后写入)，避免读到一半更新的证书对
+const fsnotifyDebounce = 200 * time.Millisecond
+
+// fileState 是上一次成功加载时证书文件的状态
+type fileState struct {
+	modTime time.Time
+	info    os.FileInfo
+}
 
-	// 初始化 lastMod，防止启动时如果文件存在但很快被修改导致第一次变更被忽略
-	// 或者直接设为零值，第一次循环肯定会触发检查
-	var lastMod time.Time
-	if info, err := os.Stat(m.cfg.CertFile); err == nil {
-		lastMod = info.ModTime()
+// startWatch 启动后台 watcher 协程：配置了证书文件时检测文件变化，未关闭 OCSP Stapling 时获取并刷新 OCSP 响应
+// (手动与 ACME 证书)。两者都不需要时不启动。
+// 文件检测默认按 WatchInterval 轮询；WatchMode 为 fsnotify 时额外监听证书所在目录的事件，变化后立即重载，
+// 轮询仍然保留，用于兜底丢失的事件与定期检查过期时间。
+// 初始状态与目录监听在返回前同步建立，避免监听协程启动前发生的变更被忽略。
+func (m *Manager) startWatch(ctx context.Context) {
+	files := m.cfg.CertFile != "" && m.cfg.KeyFile != ""
+	if !files && m.cfg.DisableOCSPStapling {
+		return
 	}
+	var last fileState
+	var w *fsnotify.Watcher
+	if files {
+		last = m.currentFileState()
+		if m.cfg.WatchMode == WatchFSNotify {
+			var err error
+			if w, err = m.newFileWatcher(); err != nil {
+				m.logger.Warn().Err(err).Msg("fsnotify unavailable, falling back to polling certificate files")
+			}
+		}
+	}
+	go m.watch(ctx, files, last, w)
+}
+
+// watch 是 watcher 协程的主循环。files 为 false 时不检查证书文件，w 为 nil 时只轮询
+func (m *Manager) watch(ctx context.Context, files bool, last fileState, w *fsnotify.Watcher) {
+	var fileTick <-chan time.Time
+	if files {
+		interval := m.cfg.WatchInterval
+		if interval <= 0 {
+			interval = fileWatchInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		fileTick = ticker.C
+	}
+
+	var ocspTick <-chan time.Time
+	var ocspKick <-chan struct{}
+	if !m.cfg.DisableOCSPStapling {
+		ticker := time.NewTicker(ocspCheckInterval)
+		defer ticker.Stop()
+		ocspTick, ocspKick = ticker.C, m.ocspKick
+		m.refreshOCSP(ctx)
+	}
+
+	var events <-chan fsnotify.Event
+	var watchErrs <-chan error
+	if w != nil {
+		defer w.Close()
+		events, watchErrs = w.Events, w.Errors
+	}
+	debounce := time.NewTimer(fsnotifyDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			info, err := os.Stat(m.cfg.CertFile)
-			if err != nil {
-				// 文件丢失
-				if m.cfg.ACME.Enabled && !m.useACME.Load() {
-					m.logger.Warn().Err(err).Msg("Certificate file missing, switching to ACME")
-					m.useACME.Store(true)
-				}
+		case <-fileTick:
+			m.checkFiles(&last)
+		case <-ocspTick:
+			m.refreshOCSP(ctx)
+		case <-ocspKick:
+			m.refreshOCSP(ctx)
+		case _, ok := <-events:
+			if !ok {
+				events = nil
 				continue
 			}
-
-			// 检查是否需要重载：从 ACME 恢复 或 文件被修改
-			shouldReload := m.useACME.Load() || !info.ModTime().Equal(lastMod)
-
-			if shouldReload {
-				// 避免死循环：如果是恢复模式且文件没变（说明上次reload失败了），跳过
-				if m.useACME.Load() && info.ModTime().Equal(lastMod) {
-					continue
-				}
-
-				if err := m.reloadFileCert(); err != nil {
-					m.logger.Error().Err(err).Msg("Failed to reload certificate")
-				} else {
-					// 加载成功
-					lastMod = info.ModTime()
-					if m.useACME.Load() {
-						m.logger.Info().Msg("Certificate restored, switching back to manual mode")
-						m.useACME.Store(false)
-					}
-				}
+			debounce.Reset(fsnotifyDebounce)
+		case err, ok := <-watchErrs:
+			if !ok {
+				watchErrs = nil
+				continue
 			}
+			m.logger.Warn().Err(err).Msg("Certificate file watcher error")
+		case <-debounce.C:
+			m.checkFiles(&last)
+		}
+	}
+}
+
+// currentFileState 返回证书文件的当前状态，文件不存在时为零值 (第一次检查必定触发重载)
+func (m *Manager) currentFileState() fileState {
+	info, err := os.Stat(m.cfg.CertFile)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: m.latestModTime(info.ModTime()), info: info}
+}
+
+// newFileWatcher 监听证书与私钥文件所在的目录 (而不是文件本身)：
+// certbot、cert-manager 等工具通过重命名或切换符号链接原子替换文件，文件的 inode 会变化，直接监听文件会丢失后续事件
+func (m *Manager) newFileWatcher() (*fsnotify.Watcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{m.cfg.CertFile, m.cfg.KeyFile}
+	for _, pair := range m.cfg.Certificates {
+		paths = append(paths, pair.CertFile, pair.KeyFile)
+	}
+	dirs := make(map[string]bool)
+	for _, p := range paths {
+		dir := filepath.Dir(p)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("watch %s: %w", dir, err)
+		}
+	}
+	return w, nil
+}
 
-			// 检查过期时间 (仅在手动模式下)
-			if !m.useACME.Load() {
-				m.checkExpiration()
+// checkFiles 在证书文件变化 (修改时间或 inode) 或需要从 ACME 恢复时重载证书，并检查过期时间
+func (m *Manager) checkFiles(last *fileState) {
+	info, err := os.Stat(m.cfg.CertFile)
+	if err != nil {
+		// 文件丢失
+		if m.cfg.ACME.Enabled && !m.useACME.Load() {
+			m.logger.Warn().Err(err).Msg("Certificate file missing, switching to ACME")
+			m.setUseACME(true)
+		}
+		return
+	}
+	modTime := m.latestModTime(info.ModTime())
+	changed := !modTime.Equal(last.modTime) || (last.info != nil && !os.SameFile(info, last.info))
+
+	// 检查是否需要重载：从 ACME 恢复 或 文件被修改
+	shouldReload := m.useACME.Load() || changed
+
+	if shouldReload {
+		// 避免死循环：如果是恢复模式且文件没变（说明上次reload失败了），跳过
+		if m.useACME.Load() && !changed {
+			return
+		}
+
+		if err := m.reloadFileCert(); err != nil {
+			m.logger.Error().Err(err).Msg("Failed to reload certificate")
+		} else {
+			// 加载成功
+			*last = fileState{modTime: modTime, info: info}
+			if m.useACME.Load() {
+				m.logger.Info().Msg("Certificate restored, switching back to manual mode")
+				m.setUseACME(false)
 			}
 		}
 	}
+
+	// 检查过期时间 (仅在手动模式下)
+	if !m.useACME.Load() {
+		m.checkExpiration()
+	}
+}
+
+// latestModTime 返回 CertFile (修改时间为 mod) 与 Config.Certificates 中证书文件的最新修改时间
+func (m *Manager) latestModTime(mod time.Time) time.Time {
+	for _, pair := range m.cfg.Certificates {
+		if info, err := os.Stat(pair.CertFile); err == nil && info.ModTime().After(mod) {
+			mod = info.ModTime()
+		}
+	}
+	return mod
+}
+
+// Reload 立即从磁盘重载证书文件，不等待下一次轮询，返回重载的结果 (如证书轮换后由运维或 SIGHUP 触发)。
+// 重载成功且正在使用 ACME 降级时切换回手动证书 (随后仍按 FallbackThresholdDays 检查过期时间)。
+// 未配置证书文件 (如 ACME、自签名模式) 时直接返回 nil。握手路径读取证书始终无锁，不受重载影响。
+func (m *Manager) Reload() error {
+	if m.cfg.CertFile == "" || m.cfg.KeyFile == "" {
+		return nil
+	}
+	if err := m.reloadFileCert(); err != nil {
+		return err
+	}
+	if m.useACME.Load() {
+		m.logger.Info().Msg("Certificate reloaded, switching back to manual mode")
+		m.setUseACME(false)
+	}
+	m.checkExpiration()
+	return nil
 }
 
-// reloadFileCert 从磁盘加载证书并解析
+// reloadFileCert 从磁盘加载证书并解析。
+// CertFile 与 Config.Certificates 中的每一对证书各自独立重载：某一对加载失败时继续使用它的旧证书，
+// 其余证书照常更新，并返回汇总的错误；失败的证书没有旧证书可用 (如启动时) 时整体失败。
 func (m *Manager) reloadFileCert() error {
-	cert, err := tls.LoadX509KeyPair(m.cfg.CertFile, m.cfg.KeyFile)
-	if err != nil {
+	m.metrics.reloads.Inc()
+	if err := m.loadFileCert(); err != nil {
+		m.metrics.reloadErrors.Inc()
 		return err
 	}
+	return nil
+}
+
+// loadFileCert 执行 reloadFileCert 的实际加载，重载次数与失败次数由 reloadFileCert 统计
+func (m *Manager) loadFileCert() error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
 
-	if len(cert.Certificate) == 0 {
-		return fmt.Errorf("no certificate found in %s", m.cfg.CertFile)
+	var errs []error
+	cert, err := loadKeyPair(m.cfg.CertFile, m.cfg.KeyFile)
+	fresh := err == nil
+	if err != nil {
+		if cert = m.manualCert.Load(); cert == nil {
+			return err
+		}
+		errs = append(errs, err)
 	}
 
-	// 手动解析 Leaf 以便后续检查过期时间
-	if len(cert.Certificate) > 0 {
-		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
-		if err != nil {
+	var idx *sniIndex
+	if len(m.cfg.Certificates) > 0 {
+		certs, complete, err := loadSNIPairs(m.cfg.Certificates, m.sniPairs)
+		if !complete {
 			return err
 		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, c := range certs {
+			m.registerOCSP(c)
+		}
+		for _, old := range m.sniPairs {
+			if !slices.Contains(certs, old) {
+				m.unregisterOCSP(old)
+			}
+		}
+		m.sniPairs = certs
+		idx = buildSNIIndex(certs)
 	}
 
 	// 原子替换，无锁操作
-	m.manualCert.Store(&cert)
+	m.sniCerts.Store(idx)
+	if fresh {
+		loadedAt := time.Now()
+		old := m.manualCert.Swap(cert)
+		m.manualLoadedAt.Store(&loadedAt)
+		m.metrics.expiry.Set(float64(cert.Leaf.NotAfter.Unix()))
+		// 先登记新证书再注销旧证书：内容未变时沿用已获取的响应
+		m.registerOCSP(cert)
+		m.unregisterOCSP(old)
 
-	m.logger.Info().
-		Str("file", m.cfg.CertFile).
-		Time("expires", cert.Leaf.NotAfter).
-		Msg("Certificate loaded from file")
-	return nil
+		m.logger.Info().
+			Str("file", m.cfg.CertFile).
+			Time("expires", cert.Leaf.NotAfter).
+			Int("sni_certificates", len(m.cfg.Certificates)).
+			Msg("Certificate loaded from file")
+	}
+	return errors.Join(errs...)
 }
 
 // checkExpiration 检查当前手动证书是否即将过期
@@ -101,9 +290,24 @@ func (m *Manager) checkExpiration() {
 	if cert == nil || cert.Leaf == nil {
 		return
 	}
+	m.metrics.expiry.Set(float64(cert.Leaf.NotAfter.Unix()))
 
-	// 计算剩余时间
+	// 剩余时间以墙上时钟为准：单调时钟在虚拟机/宿主机挂起期间停止计时，
+	// 用它推算会让恢复后已经过期的证书看起来仍然有效。
 	timeLeft := time.Until(cert.Leaf.NotAfter)
+	if loadedAt := m.manualLoadedAt.Load(); loadedAt != nil {
+		// 对比加载以来墙上时钟与单调时钟各自经过的时间，发现跳变 (NTP 校正、挂起恢复) 时记录日志，
+		// 并以当前时刻重新锚定，避免同一次跳变被重复报告
+		monoLeft := cert.Leaf.NotAfter.Sub(*loadedAt) - time.Since(*loadedAt)
+		if drift := timeLeft - monoLeft; drift > clockDriftTolerance || drift < -clockDriftTolerance {
+			m.logger.Warn().
+				Dur("drift", drift).
+				Dur("time_left", timeLeft).
+				Msg("Wall clock jump detected while checking certificate expiration")
+			now := time.Now()
+			m.manualLoadedAt.Store(&now)
+		}
+	}
 	threshold := time.Duration(m.cfg.FallbackThresholdDays) * 24 * time.Hour
 
 	// 如果剩余时间小于阈值，且启用了 ACME，且当前未在使用 ACME
@@ -112,6 +316,6 @@ func (m *Manager) checkExpiration() {
 			Dur("time_left", timeLeft).
 			Dur("threshold", threshold).
 			Msg("Manual certificate is expiring soon, switching to ACME fallback")
-		m.useACME.Store(true)
+		m.setUseACME(true)
 	}
 }
@@ -0,0 +1,81 @@
+package cert
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// 证书来源 (CertInfo.Source)
+const (
+	SourceNone       = "none"
+	SourceManual     = "manual"
+	SourceSelfSigned = "self-signed"
+	SourceACME       = "acme"
+)
+
+// CertInfo 是当前对外提供的证书的元数据，由 Manager.CertInfo 返回
+type CertInfo struct {
+	// Source 当前使用的证书来源: none | manual | self-signed | acme
+	Source string `json:"source"`
+	// UsingACME 为 true 时证书由 ACME 按域名签发，下面的证书字段为空，ACMEDomains 为配置的域名
+	UsingACME   bool     `json:"using_acme"`
+	ACMEDomains []string `json:"acme_domains,omitempty"`
+
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	DNSNames    []string  `json:"dns_names,omitempty"`
+	IPAddresses []string  `json:"ip_addresses,omitempty"`
+	NotBefore   time.Time `json:"not_before,omitzero"`
+	NotAfter    time.Time `json:"not_after,omitzero"`
+	// DaysUntilExpiry 距离过期的整天数 (向下取整)，已过期时为负数
+	DaysUntilExpiry int `json:"days_until_expiry"`
+}
+
+// CertInfo 返回当前对外提供的证书的元数据 (手动或自签名证书；使用 ACME 时只返回来源与域名)。
+// 只读取原子状态，可以与证书重载并发调用。
+func (m *Manager) CertInfo() CertInfo {
+	if m.useACME.Load() {
+		domains := append([]string(nil), m.cfg.ACME.Domains...)
+		for _, dc := range m.cfg.ACME.Challenges {
+			domains = append(domains, dc.Domain)
+		}
+		return CertInfo{Source: SourceACME, UsingACME: true, ACMEDomains: domains}
+	}
+
+	cert := m.manualCert.Load()
+	if cert == nil || cert.Leaf == nil {
+		return CertInfo{Source: SourceNone}
+	}
+	leaf := cert.Leaf
+	info := CertInfo{
+		Source:          SourceManual,
+		Subject:         leaf.Subject.String(),
+		Issuer:          leaf.Issuer.String(),
+		DNSNames:        slices.Clone(leaf.DNSNames),
+		NotBefore:       leaf.NotBefore,
+		NotAfter:        leaf.NotAfter,
+		DaysUntilExpiry: int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24)),
+	}
+	if m.cfg.Mode == ModeSelfSigned {
+		info.Source = SourceSelfSigned
+	}
+	for _, ip := range leaf.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	return info
+}
+
+// CertInfoHandler 以 JSON 返回 CertInfo，可挂载到监控服务：
+//
+//	appx.MonitorOptions{ExtraHandlers: map[string]http.Handler{"/debug/cert": certMgr.CertInfoHandler()}}
+func (m *Manager) CertInfoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := sonic.Marshal(m.CertInfo())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
@@ -3,11 +3,16 @@ package cert
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 	"golang.org/x/crypto/acme/autocert"
@@ -22,46 +27,126 @@ type Manager struct {
 	logger *zerolog.Logger
 
 	// 内部状态
-	manualCert  atomic.Pointer[tls.Certificate]
+	manualCert atomic.Pointer[tls.Certificate]
+	// manualLoadedAt 记录手动证书的加载时刻 (含单调时钟读数)，用于检测墙上时钟跳变，检测到后重新锚定为当时的时刻
+	manualLoadedAt atomic.Pointer[time.Time]
+	// reloadMu 串行化证书文件的重载，sniPairs 是 Config.Certificates 中每一对最近一次加载成功的证书 (按配置顺序)
+	reloadMu sync.Mutex
+	sniPairs []*tls.Certificate
+	// sniCerts 是 Config.Certificates 的 SNI 索引，为 nil 表示未配置
+	sniCerts    atomic.Pointer[sniIndex]
 	acmeManager *autocert.Manager
+	// dns01Domains 是通过 DNS-01 签发的域名，dns01Certs 只在 New 与 runDNS01 中访问，
+	// 握手路径读取的是其 copy-on-write 索引 dnsCerts
+	dns01Domains []string
+	dns01Certs   map[string]*tls.Certificate
+	dnsCerts     atomic.Pointer[sniIndex]
+	// acmeFailures 记录签发失败的域名及其重试时间
+	acmeMu       sync.Mutex
+	acmeFailures map[string]acmeFailure
 
-	// 状态位：0=使用手动证书, 1=使用 ACME
+	// ocsp 按叶子证书的 DER 编码缓存 OCSP 响应，ocspKick 通知 watcher 协程有新登记的证书
+	ocspMu   sync.RWMutex
+	ocsp     map[string]*ocspEntry
+	ocspKick chan struct{}
+	// ocspStaples 是握手路径读取的已登记证书表 (copy-on-write)，由 publishOCSP 在 ocspMu 下重建
+	ocspStaples atomic.Pointer[map[*x509.Certificate]*stapledCert]
+
+	// 状态位：0=使用手动证书, 1=使用 ACME，通过 setUseACME 修改以同步 appx_cert_acme_active
 	useACME atomic.Bool
+	metrics *managerMetrics
 
 	// 确保 Start 只执行一次
 	startOnce sync.Once
 }
 
 // New 创建证书管理器。
+// 根据 cfg.Mode 选择证书来源；Mode 为 ModeOff 时返回 (nil, nil)，
+// 此时 HttpService.WithTLS(nil) 等同于不开启 TLS。
 func New(cfg Config, logger *zerolog.Logger) (*Manager, error) {
+	switch cfg.Mode {
+	case "":
+		// 兼容旧配置：手动证书优先，ACME.Enabled 时自动降级
+	case ModeOff:
+		return nil, nil
+	case ModeAuto:
+		cfg.ACME.Enabled = true
+	case ModeManual:
+		cfg.ACME.Enabled = false
+	case ModeACME:
+		cfg.ACME.Enabled = true
+		cfg.CertFile, cfg.KeyFile, cfg.Certificates = "", "", nil
+	case ModeSelfSigned:
+		cfg.ACME.Enabled = false
+		cfg.CertFile, cfg.KeyFile, cfg.Certificates = "", "", nil
+	default:
+		return nil, fmt.Errorf("cert manager: unknown mode %q", cfg.Mode)
+	}
+	switch cfg.WatchMode {
+	case "", WatchPoll, WatchFSNotify:
+	default:
+		return nil, fmt.Errorf("cert manager: unknown watch mode %q", cfg.WatchMode)
+	}
+
 	m := &Manager{
-		cfg:    cfg,
-		logger: logger,
+		cfg:      cfg,
+		logger:   logger,
+		ocsp:     make(map[string]*ocspEntry),
+		ocspKick: make(chan struct{}, 1),
+		metrics:  newManagerMetrics(cfg.Registerer),
+	}
+
+	if cfg.Mode == ModeSelfSigned {
+		if err := m.loadSelfSigned(); err != nil {
+			return nil, err
+		}
+		return m, nil
 	}
 
 	// 1. 初始化 ACME (如果启用)
 	if cfg.ACME.Enabled {
+		if _, _, err := splitChallenges(cfg.ACME); err != nil {
+			return nil, fmt.Errorf("cert manager: %w", err)
+		}
 		m.initACME()
 	}
+	if cfg.Mode == ModeACME {
+		m.setUseACME(true)
+		return m, nil
+	}
 
 	// 2. 尝试初始加载手动证书
 	if err := m.reloadFileCert(); err != nil {
+		if cfg.Mode == ModeManual {
+			return nil, fmt.Errorf("cert manager: load manual certificate: %w", err)
+		}
 		m.logger.Warn().Err(err).Msg("Failed to load manual certificate on startup")
 		if cfg.ACME.Enabled {
 			m.logger.Info().Msg("Falling back to ACME immediately")
-			m.useACME.Store(true)
+			m.setUseACME(true)
 		}
 	}
 
 	return m, nil
 }
 
+// setUseACME 切换证书来源，并更新 appx_cert_acme_active
+func (m *Manager) setUseACME(v bool) {
+	m.useACME.Store(v)
+	if v {
+		m.metrics.acmeActive.Set(1)
+	} else {
+		m.metrics.acmeActive.Set(0)
+	}
+}
+
 // Start 启动后台监听（Watcher）。
 func (m *Manager) Start(ctx context.Context) error {
 	m.startOnce.Do(func() {
-		// 只有配置了文件路径才启动文件监听
-		if m.cfg.CertFile != "" && m.cfg.KeyFile != "" {
-			go m.watchFileChanges(ctx)
+		// 文件监听与 OCSP 刷新共用一个 watcher 协程
+		m.startWatch(ctx)
+		if len(m.dns01Domains) > 0 && m.acmeManager.Client != nil {
+			go m.runDNS01(ctx)
 		}
 	})
 	return nil
@@ -78,29 +163,110 @@ func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate,
 	// 1. 优先检查是否启用了 ACME
 	if m.useACME.Load() {
 		if m.acmeManager != nil {
-			return m.acmeManager.GetCertificate(hello)
+			return m.acmeGetCertificate(hello)
 		}
 		m.logger.Warn().Msg("acme manager not init, falling back to manual certificate")
 	}
 
-	// 2. 否则使用手动加载的证书 (Lock-free Atomic Load)
+	// 2. 否则使用手动加载的证书 (Lock-free Atomic Load)，按 SNI 命中的证书优先
+	if idx := m.sniCerts.Load(); idx != nil {
+		if cert := idx.lookup(hello.ServerName); cert != nil {
+			return m.stapleOCSP(cert), nil
+		}
+	}
 	cert := m.manualCert.Load()
 
 	// 3. 双重保险：如果手动证书不可用，尝试降级到 ACME
 	if cert == nil {
 		if m.acmeManager != nil {
-			return m.acmeManager.GetCertificate(hello)
+			return m.acmeGetCertificate(hello)
 		}
 		return nil, fmt.Errorf("cert manager: %w for %s", ErrNoCertificateAvailable, hello.ServerName)
 	}
 
-	return cert, nil
+	return m.stapleOCSP(cert), nil
+}
+
+// CurrentCert 返回当前使用的手动 (或自签名) 证书，Leaf 已解析。
+// 正在使用 ACME 或没有可用的手动证书时返回 nil (ACME 证书按域名签发并由 autocert 自动续期)。
+func (m *Manager) CurrentCert() *tls.Certificate {
+	if m.useACME.Load() {
+		return nil
+	}
+	return m.manualCert.Load()
 }
 
-// HTTPHandler ACME 挑战处理器
+// acmeChallengePrefix 是 HTTP-01 挑战的路径前缀 (RFC 8555 8.3)，区分大小写
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// HTTPHandler ACME 挑战处理器。
+// 只有路径严格为 "/.well-known/acme-challenge/<token>" 的请求交给 ACME 应答，其余请求 (包括大小写不同、
+// 缺少 token 或带有多余路径段的请求) 一律交给 fallback；fallback 为 nil 时重定向到 HTTPS。
 func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
-	if m.acmeManager != nil {
-		return m.acmeManager.HTTPHandler(fallback)
+	if m.acmeManager == nil {
+		return fallback
+	}
+	if fallback == nil {
+		fallback = http.HandlerFunc(redirectToHTTPS)
+	}
+	challenge := m.acmeManager.HTTPHandler(nil)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isACMEChallenge(r.URL.Path) {
+			challenge.ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+// isACMEChallenge 判断路径是否为 HTTP-01 挑战：前缀精确匹配，token 非空且只包含 base64url 字符
+func isACMEChallenge(path string) bool {
+	token, ok := strings.CutPrefix(path, acmeChallengePrefix)
+	if !ok || token == "" {
+		return false
+	}
+	for _, c := range token {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// RedirectHandler 返回适用于 80 端口的 Handler：处理 ACME HTTP-01 挑战，其余请求重定向到 HTTPS。
+// GET/HEAD 使用 301，其他方法使用 308 以保留请求方法与请求体。
+// healthPaths 中的路径 (精确匹配) 直接返回 200 而不重定向，供只能探测 80 端口的负载均衡器做健康检查。
+//
+//	app.Add(appx.NewHttpService("http-redirect", ":80", certMgr.RedirectHandler("/healthz")))
+func (m *Manager) RedirectHandler(healthPaths ...string) http.Handler {
+	if len(healthPaths) == 0 {
+		return m.HTTPHandler(http.HandlerFunc(redirectToHTTPS))
+	}
+	return m.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(healthPaths, r.URL.Path) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ok"))
+			return
+		}
+		redirectToHTTPS(w, r)
+	}))
+}
+
+// redirectToHTTPS 将请求重定向到同一主机的 HTTPS 默认端口
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 字面量
+		}
+	}
+
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
 	}
-	return fallback
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
 }
@@ -9,17 +9,25 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
+	"io"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/ocsp"
 )
 
 // Benchmark_GetCertificate 验证无锁化后的性能
@@ -32,28 +40,71 @@ func Benchmark_GetCertificate(b *testing.B) {
 	cfg := Config{
 		CertFile: certFile,
 		KeyFile:  keyFile,
-		ACME: struct {
-			Enabled  bool     `mapstructure:"enabled" yaml:"enabled"`
-			Email    string   `mapstructure:"email" yaml:"email"`
-			Domains  []string `mapstructure:"domains" yaml:"domains"`
-			CacheDir string   `mapstructure:"cache_dir" yaml:"cache_dir"`
-		}{
+		ACME: ACME{
 			Enabled: false,
 		},
 	}
 
-	// 2. 初始化 Manager
-	mgr, err := New(cfg, &log.Logger)
+	b.Run("Single", func(b *testing.B) {
+		benchmarkGetCertificate(b, newBenchmarkManager(b, cfg), &tls.ClientHelloInfo{ServerName: "example.com"})
+	})
+
+	// 附加 OCSP 响应同样必须无锁、零分配
+	b.Run("OCSPStapled", func(b *testing.B) {
+		ca, caKey := generateTestCA(b)
+		c, k := generateOCSPTestCert(b, b.TempDir(), ca, caKey, "http://127.0.0.1:1/ocsp")
+		mgr := newBenchmarkManager(b, Config{CertFile: c, KeyFile: k})
+		// 注入已获取的响应，不启动后台刷新
+		mgr.ocspMu.Lock()
+		for _, e := range mgr.ocsp {
+			e.staple = []byte("staple")
+			e.nextUpdate = time.Now().Add(time.Hour)
+		}
+		mgr.publishOCSP()
+		mgr.ocspMu.Unlock()
+
+		hello := &tls.ClientHelloInfo{ServerName: "ocsp.example.com"}
+		cert, err := mgr.GetCertificate(hello)
+		require.NoError(b, err)
+		require.NotNil(b, cert.OCSPStaple)
+		benchmarkGetCertificate(b, mgr, hello)
+	})
+
+	// 多域名场景：SNI 索引查找同样必须保持零分配
+	for i := range 8 {
+		name := fmt.Sprintf("site%d", i)
+		c, k := generateNamedTestCert(b, tempDir, name, name+".example.com", "*."+name+".example.org")
+		cfg.Certificates = append(cfg.Certificates, KeyPair{CertFile: c, KeyFile: k})
+	}
+	b.Run("SNI", func(b *testing.B) {
+		benchmarkGetCertificate(b, newBenchmarkManager(b, cfg), &tls.ClientHelloInfo{ServerName: "site7.example.com"})
+	})
+	b.Run("SNIWildcard", func(b *testing.B) {
+		benchmarkGetCertificate(b, newBenchmarkManager(b, cfg), &tls.ClientHelloInfo{ServerName: "www.site7.example.org"})
+	})
+	b.Run("SNIMiss", func(b *testing.B) {
+		benchmarkGetCertificate(b, newBenchmarkManager(b, cfg), &tls.ClientHelloInfo{ServerName: "unknown.example.net"})
+	})
+}
+
+func newBenchmarkManager(b *testing.B, cfg Config) *Manager {
+	quietLogger := zerolog.Nop()
+	mgr, err := New(cfg, &quietLogger)
 	if err != nil {
 		b.Fatalf("Failed to init manager: %v", err)
 	}
+	return mgr
+}
 
-	hello := &tls.ClientHelloInfo{ServerName: "example.com"}
+func benchmarkGetCertificate(b *testing.B, mgr *Manager, hello *tls.ClientHelloInfo) {
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = mgr.GetCertificate(hello) }); allocs != 0 {
+		b.Fatalf("GetCertificate allocates %.1f times per call, want 0", allocs)
+	}
 
 	b.ResetTimer()
 	b.ReportAllocs()
 
-	// 3. 并发基准测试
+	// 并发基准测试
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
 			_, _ = mgr.GetCertificate(hello)
@@ -69,12 +120,7 @@ func TestManager_ReloadConcurrency(t *testing.T) {
 	cfg := Config{
 		CertFile: certFile,
 		KeyFile:  keyFile,
-		ACME: struct {
-			Enabled  bool     `mapstructure:"enabled" yaml:"enabled"`
-			Email    string   `mapstructure:"email" yaml:"email"`
-			Domains  []string `mapstructure:"domains" yaml:"domains"`
-			CacheDir string   `mapstructure:"cache_dir" yaml:"cache_dir"`
-		}{
+		ACME: ACME{
 			Enabled: false,
 		},
 	}
@@ -187,6 +233,82 @@ func generateTestCert(t *testing.T, dir string, validDuration time.Duration) (ce
 	return certPath, keyPath
 }
 
+// generateNamedTestCert 生成带 DNS SAN 的证书，文件以 name 命名
+func generateNamedTestCert(tb testing.TB, dir, name string, dnsNames ...string) (certPath, keyPath string) {
+	tb.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(tb, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(tb, err)
+	privBytes, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(tb, err)
+
+	certPath = filepath.Join(dir, name+".crt")
+	keyPath = filepath.Join(dir, name+".key")
+	require.NoError(tb, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}), 0o644))
+	require.NoError(tb, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privBytes}), 0o600))
+	return certPath, keyPath
+}
+
+func TestManager_SNICertificates(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := generateTestCert(t, dir, time.Hour)
+	aCert, aKey := generateNamedTestCert(t, dir, "a", "a.example.com")
+	wCert, wKey := generateNamedTestCert(t, dir, "wildcard", "*.example.com")
+
+	mgr, err := New(Config{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		Certificates: []KeyPair{
+			{CertFile: aCert, KeyFile: aKey},
+			{CertFile: wCert, KeyFile: wKey},
+		},
+	}, &log.Logger)
+	require.NoError(t, err)
+
+	subject := func(serverName string) string {
+		c, err := mgr.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		require.NoError(t, err)
+		return c.Leaf.Subject.CommonName
+	}
+	assert.Equal(t, "a.example.com", subject("a.example.com"))
+	assert.Equal(t, "a.example.com", subject("A.Example.COM."), "SNI matching is case-insensitive")
+	assert.Equal(t, "*.example.com", subject("b.example.com"))
+	assert.Equal(t, "", subject("x.b.example.com"), "wildcard covers a single label only")
+	assert.Equal(t, "", subject("other.org"), "unmatched names use the default certificate")
+
+	// 每一对证书独立重载：损坏的证书继续使用旧证书，其余证书照常更新
+	require.NoError(t, os.WriteFile(aCert, []byte("broken"), 0o644))
+	generateNamedTestCert(t, dir, "wildcard", "*.example.com", "c.example.org")
+	err = mgr.reloadFileCert()
+	assert.ErrorContains(t, err, aCert)
+	assert.Equal(t, "a.example.com", subject("a.example.com"))
+	assert.Equal(t, "*.example.com", subject("c.example.org"))
+
+	// 默认证书损坏时同样保留旧证书
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o644))
+	assert.Error(t, mgr.reloadFileCert())
+	c, err := mgr.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.org"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Test Org"}, c.Leaf.Subject.Organization)
+
+	// 首次加载时没有旧证书可用，整体失败
+	_, err = New(Config{Mode: ModeManual, CertFile: certFile, KeyFile: keyFile}, &log.Logger)
+	assert.Error(t, err)
+	_, err = New(Config{Mode: ModeManual, CertFile: wCert, KeyFile: wKey, Certificates: []KeyPair{{CertFile: aCert, KeyFile: aKey}}}, &log.Logger)
+	assert.ErrorContains(t, err, aCert)
+}
+
 func TestManager_ManualCert_HappyPath(t *testing.T) {
 	tempDir := t.TempDir()
 	certFile, keyFile := generateTestCert(t, tempDir, 1*time.Hour)
@@ -194,12 +316,7 @@ func TestManager_ManualCert_HappyPath(t *testing.T) {
 	cfg := Config{
 		CertFile: certFile,
 		KeyFile:  keyFile,
-		ACME: struct {
-			Enabled  bool     `mapstructure:"enabled" yaml:"enabled"`
-			Email    string   `mapstructure:"email" yaml:"email"`
-			Domains  []string `mapstructure:"domains" yaml:"domains"`
-			CacheDir string   `mapstructure:"cache_dir" yaml:"cache_dir"`
-		}{
+		ACME: ACME{
 			Enabled: false,
 		},
 	}
@@ -222,12 +339,7 @@ func TestManager_StartupFallback(t *testing.T) {
 	cfg := Config{
 		CertFile: filepath.Join(tempDir, "missing.pem"),
 		KeyFile:  filepath.Join(tempDir, "missing.key"),
-		ACME: struct {
-			Enabled  bool     `mapstructure:"enabled" yaml:"enabled"`
-			Email    string   `mapstructure:"email" yaml:"email"`
-			Domains  []string `mapstructure:"domains" yaml:"domains"`
-			CacheDir string   `mapstructure:"cache_dir" yaml:"cache_dir"`
-		}{
+		ACME: ACME{
 			Enabled:  true,
 			CacheDir: tempDir,
 		},
@@ -249,12 +361,7 @@ func TestManager_ExpirationCheck(t *testing.T) {
 		CertFile:              certFile,
 		KeyFile:               keyFile,
 		FallbackThresholdDays: 30,
-		ACME: struct {
-			Enabled  bool     `mapstructure:"enabled" yaml:"enabled"`
-			Email    string   `mapstructure:"email" yaml:"email"`
-			Domains  []string `mapstructure:"domains" yaml:"domains"`
-			CacheDir string   `mapstructure:"cache_dir" yaml:"cache_dir"`
-		}{
+		ACME: ACME{
 			Enabled:  true,
 			CacheDir: tempDir,
 		},
@@ -276,12 +383,7 @@ func TestManager_ReloadAndRecover(t *testing.T) {
 	cfg := Config{
 		CertFile: certFile,
 		KeyFile:  keyFile,
-		ACME: struct {
-			Enabled  bool     `mapstructure:"enabled" yaml:"enabled"`
-			Email    string   `mapstructure:"email" yaml:"email"`
-			Domains  []string `mapstructure:"domains" yaml:"domains"`
-			CacheDir string   `mapstructure:"cache_dir" yaml:"cache_dir"`
-		}{
+		ACME: ACME{
 			Enabled:  true,
 			CacheDir: tempDir,
 		},
@@ -389,6 +491,9 @@ func TestManager_GetCertificate_Fallback(t *testing.T) {
 }
 
 func TestManager_Config_ACME_Init(t *testing.T) {
+	// 默认 CacheDir 是相对路径，切换到临时目录避免在仓库中生成账户私钥
+	t.Chdir(t.TempDir())
+
 	// 测试 initACME 的配置逻辑
 	cfg := Config{
 		ACME: ACME{
@@ -406,3 +511,660 @@ func TestManager_Config_ACME_Init(t *testing.T) {
 	h := mgr.HTTPHandler(nil)
 	assert.NotNil(t, h, "ACME manager should be initialized")
 }
+
+func TestManager_ACME_AccountKeyReuse(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "persistent", "account.key")
+	cfg := Config{
+		ACME: ACME{Enabled: true, CacheDir: t.TempDir(), AccountKeyFile: keyFile},
+	}
+
+	// 第一次启动：生成并保存账户私钥
+	mgr1, err := New(cfg, &log.Logger)
+	require.NoError(t, err)
+	require.NotNil(t, mgr1.acmeManager.Client)
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// 第二次启动 (CacheDir 已更换，模拟全新容器)：复用同一个账户私钥
+	cfg.ACME.CacheDir = t.TempDir()
+	mgr2, err := New(cfg, &log.Logger)
+	require.NoError(t, err)
+
+	tp1, err := acme.JWKThumbprint(mgr1.acmeManager.Client.Key.Public())
+	require.NoError(t, err)
+	tp2, err := acme.JWKThumbprint(mgr2.acmeManager.Client.Key.Public())
+	require.NoError(t, err)
+	assert.Equal(t, tp1, tp2)
+}
+
+func TestManager_ACME_IssuanceBackoff(t *testing.T) {
+	// 模拟不可用的 ACME 目录，签发会立即失败
+	ca := httptest.NewServer(http.NotFoundHandler())
+	defer ca.Close()
+
+	cfg := Config{
+		ACME: ACME{Enabled: true, CacheDir: t.TempDir(), Domains: []string{"example.com"}},
+	}
+	mgr, err := New(cfg, &log.Logger)
+	require.NoError(t, err)
+	require.NotNil(t, mgr.acmeManager.Client)
+	mgr.acmeManager.Client.DirectoryURL = ca.URL
+
+	failures := testutil.ToFloat64(acmeIssuanceTotal.WithLabelValues(issuanceFailure))
+	hello := &tls.ClientHelloInfo{ServerName: "example.com"}
+
+	_, err = mgr.acmeGetCertificate(hello)
+	require.Error(t, err)
+	assert.Equal(t, failures+1, testutil.ToFloat64(acmeIssuanceTotal.WithLabelValues(issuanceFailure)))
+
+	// 退避期间不再触发签发，也不重复计数
+	_, err = mgr.acmeGetCertificate(hello)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "backing off")
+	assert.Equal(t, failures+1, testutil.ToFloat64(acmeIssuanceTotal.WithLabelValues(issuanceFailure)))
+
+	// 不在白名单中的域名不计入失败
+	_, err = mgr.acmeGetCertificate(&tls.ClientHelloInfo{ServerName: "other.com"})
+	require.Error(t, err)
+	assert.Equal(t, failures+1, testutil.ToFloat64(acmeIssuanceTotal.WithLabelValues(issuanceFailure)))
+
+	// 证书写入 Cache 视为签发成功，并清除退避状态
+	successes := testutil.ToFloat64(acmeIssuanceTotal.WithLabelValues(issuanceSuccess))
+	require.NoError(t, mgr.acmeManager.Cache.Put(context.Background(), "example.com+token", []byte("x")))
+	require.NoError(t, mgr.acmeManager.Cache.Put(context.Background(), "example.com", []byte("x")))
+	assert.Equal(t, successes+1, testutil.ToFloat64(acmeIssuanceTotal.WithLabelValues(issuanceSuccess)))
+	assert.NotContains(t, mgr.acmeFailures, "example.com")
+}
+
+// stubDNSProvider 是不做任何事的 DNSProvider
+type stubDNSProvider struct{}
+
+func (stubDNSProvider) Present(context.Context, string, string) error { return nil }
+func (stubDNSProvider) CleanUp(context.Context, string, string) error { return nil }
+
+func TestManager_ACME_Challenges(t *testing.T) {
+	newACME := func(acmeCfg ACME) (*Manager, error) {
+		acmeCfg.CacheDir = t.TempDir()
+		return New(Config{Mode: ModeACME, ACME: acmeCfg}, &log.Logger)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := newACME(ACME{Challenges: []DomainChallenge{{Domain: "*.example.com", Challenge: ChallengeHTTP01}}})
+		assert.ErrorContains(t, err, `wildcard domain "*.example.com" requires the dns-01 challenge`)
+
+		_, err = newACME(ACME{Domains: []string{"*.example.com"}})
+		assert.ErrorContains(t, err, "requires the dns-01 challenge")
+
+		_, err = newACME(ACME{Challenges: []DomainChallenge{{Domain: "*.example.com", Challenge: ChallengeDNS01}}})
+		assert.ErrorContains(t, err, "ACME.DNSProvider is required")
+
+		_, err = newACME(ACME{Challenges: []DomainChallenge{{Domain: "example.com", Challenge: "tls-sni-01"}}})
+		assert.ErrorContains(t, err, `unknown ACME challenge "tls-sni-01"`)
+
+		_, err = newACME(ACME{Challenge: "tls-sni-01"})
+		assert.ErrorContains(t, err, `unknown ACME challenge "tls-sni-01"`)
+
+		_, err = newACME(ACME{Challenge: ChallengeDNS01, Domains: []string{"*.example.com"}})
+		assert.ErrorContains(t, err, "ACME.DNSProvider is required")
+	})
+
+	t.Run("Default DNS-01", func(t *testing.T) {
+		mgr, err := newACME(ACME{
+			Challenge:   ChallengeDNS01,
+			Domains:     []string{"example.com", "*.example.com"},
+			Challenges:  []DomainChallenge{{Domain: "www.example.org", Challenge: ChallengeHTTP01}, {Domain: "api.example.org"}},
+			DNSProvider: stubDNSProvider{},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"example.com", "*.example.com", "api.example.org"}, mgr.dns01Domains)
+		assert.NoError(t, mgr.acmeManager.HostPolicy(context.Background(), "www.example.org"))
+		assert.Error(t, mgr.acmeManager.HostPolicy(context.Background(), "example.com"))
+	})
+
+	t.Run("Apex HTTP-01 With Wildcard DNS-01", func(t *testing.T) {
+		mgr, err := newACME(ACME{
+			Challenges: []DomainChallenge{
+				{Domain: "example.com", Challenge: ChallengeHTTP01},
+				{Domain: "*.example.com", Challenge: ChallengeDNS01},
+			},
+			DNSProvider: stubDNSProvider{},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"*.example.com"}, mgr.dns01Domains)
+		assert.NoError(t, mgr.acmeManager.HostPolicy(context.Background(), "example.com"))
+		assert.Error(t, mgr.acmeManager.HostPolicy(context.Background(), "api.example.com"),
+			"wildcard names must not be issued on demand via HTTP-01")
+	})
+
+	t.Run("Cached DNS-01 Certificate", func(t *testing.T) {
+		// 模拟上次运行签发并缓存的通配符证书，重启后无需访问 CA 即可使用
+		cacheDir := t.TempDir()
+		issued, err := selfSignedCertificate([]string{"*.example.com"}, time.Now())
+		require.NoError(t, err)
+		data, err := encodeCertPEM(issued.Certificate, issued.PrivateKey.(*ecdsa.PrivateKey))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "*.example.com"+dns01CacheSuffix), data, 0o600))
+
+		mgr, err := New(Config{Mode: ModeACME, ACME: ACME{
+			CacheDir:    cacheDir,
+			Challenges:  []DomainChallenge{{Domain: "*.example.com", Challenge: ChallengeDNS01}},
+			DNSProvider: stubDNSProvider{},
+		}}, &log.Logger)
+		require.NoError(t, err)
+
+		c, err := mgr.GetCertificate(&tls.ClientHelloInfo{ServerName: "api.example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, issued.Certificate[0], c.Certificate[0])
+	})
+}
+
+func TestManager_RetryBackoff(t *testing.T) {
+	mgr := &Manager{cfg: Config{ACME: ACME{RetryBackoff: 2 * time.Minute, MaxRetryBackoff: 5 * time.Minute}}}
+	assert.Equal(t, 2*time.Minute, mgr.retryBackoff(1))
+	assert.Equal(t, 4*time.Minute, mgr.retryBackoff(2))
+	assert.Equal(t, 5*time.Minute, mgr.retryBackoff(3))
+
+	// 不允许小于 autocert 内置的冷却时间
+	mgr.cfg.ACME = ACME{RetryBackoff: time.Second}
+	assert.Equal(t, time.Minute, mgr.retryBackoff(1))
+	assert.Equal(t, time.Hour, mgr.retryBackoff(100))
+}
+
+func TestManager_Mode(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := generateTestCert(t, dir, time.Hour)
+
+	t.Run("off", func(t *testing.T) {
+		mgr, err := New(Config{Mode: ModeOff, CertFile: certFile, KeyFile: keyFile}, &log.Logger)
+		require.NoError(t, err)
+		assert.Nil(t, mgr)
+	})
+
+	t.Run("self-signed", func(t *testing.T) {
+		mgr, err := New(Config{Mode: ModeSelfSigned}, &log.Logger)
+		require.NoError(t, err)
+		cert, err := mgr.GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+		require.NoError(t, err)
+		assert.NoError(t, cert.Leaf.VerifyHostname("localhost"))
+		assert.NoError(t, cert.Leaf.VerifyHostname("127.0.0.1"))
+		assert.Nil(t, mgr.acmeManager)
+	})
+
+	t.Run("manual requires files", func(t *testing.T) {
+		mgr, err := New(Config{Mode: ModeManual, CertFile: certFile, KeyFile: keyFile, ACME: ACME{Enabled: true}}, &log.Logger)
+		require.NoError(t, err)
+		assert.Nil(t, mgr.acmeManager, "manual mode never uses ACME")
+
+		_, err = New(Config{Mode: ModeManual, CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}, &log.Logger)
+		assert.Error(t, err)
+	})
+
+	t.Run("acme", func(t *testing.T) {
+		mgr, err := New(Config{Mode: ModeACME, CertFile: certFile, KeyFile: keyFile, ACME: ACME{CacheDir: t.TempDir()}}, &log.Logger)
+		require.NoError(t, err)
+		assert.NotNil(t, mgr.acmeManager)
+		assert.True(t, mgr.useACME.Load())
+		assert.Nil(t, mgr.manualCert.Load())
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		mgr, err := New(Config{Mode: ModeAuto, CertFile: certFile, KeyFile: keyFile, ACME: ACME{CacheDir: t.TempDir()}}, &log.Logger)
+		require.NoError(t, err)
+		assert.False(t, mgr.useACME.Load(), "files exist, use manual certificate")
+
+		mgr, err = New(Config{Mode: ModeAuto, ACME: ACME{CacheDir: t.TempDir()}}, &log.Logger)
+		require.NoError(t, err)
+		assert.True(t, mgr.useACME.Load(), "no files, use ACME")
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := New(Config{Mode: "bogus"}, &log.Logger)
+		assert.ErrorContains(t, err, "unknown mode")
+	})
+}
+
+func TestManager_RedirectHandler(t *testing.T) {
+	cfg := Config{ACME: ACME{Enabled: true, CacheDir: t.TempDir(), Domains: []string{"example.com"}}}
+	mgr, err := New(cfg, &log.Logger)
+	require.NoError(t, err)
+	h := mgr.RedirectHandler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com:80/path?q=1", nil))
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com/path?q=1", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "http://[::1]:80/submit", nil))
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://[::1]/submit", w.Header().Get("Location"))
+
+	// ACME 挑战路径由 autocert 处理，不会被重定向
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/.well-known/acme-challenge/token", nil))
+	assert.NotEqual(t, http.StatusMovedPermanently, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+func TestManager_HTTPHandler_ChallengePath(t *testing.T) {
+	cfg := Config{ACME: ACME{Enabled: true, CacheDir: t.TempDir(), Domains: []string{"example.com"}}}
+	mgr, err := New(cfg, &log.Logger)
+	require.NoError(t, err)
+	h := mgr.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		path     string
+		fallback bool
+	}{
+		{"/.well-known/acme-challenge/abc-DEF_123", false},
+		{"/.well-known/acme-challenge/", true},
+		{"/.well-known/acme-challenge", true},
+		{"/.well-known/acme-challenge/token/", true},
+		{"/.well-known/acme-challenge/a/b", true},
+		{"/.well-known/ACME-CHALLENGE/token", true},
+		{"/.WELL-KNOWN/acme-challenge/token", true},
+		{"/prefix/.well-known/acme-challenge/token", true},
+		{"/", true},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+tt.path, nil))
+		if tt.fallback {
+			assert.Equal(t, http.StatusTeapot, w.Code, tt.path)
+		} else {
+			// 未知 token 由 autocert 应答 404
+			assert.Equal(t, http.StatusNotFound, w.Code, tt.path)
+		}
+	}
+
+	// fallback 为 nil 时重定向到 HTTPS
+	w := httptest.NewRecorder()
+	mgr.HTTPHandler(nil).ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/x", nil))
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+}
+
+func TestManager_RedirectHandler_HealthPath(t *testing.T) {
+	cfg := Config{ACME: ACME{Enabled: true, CacheDir: t.TempDir(), Domains: []string{"example.com"}}}
+	mgr, err := New(cfg, &log.Logger)
+	require.NoError(t, err)
+	h := mgr.RedirectHandler("/healthz")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://10.0.0.1/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+
+	// 只精确匹配，子路径仍然重定向
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/healthz/x", nil))
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/.well-known/acme-challenge/token", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// generateTestCA 生成用于签发测试证书的 CA
+func generateTestCA(tb testing.TB) (*x509.Certificate, *ecdsa.PrivateKey) {
+	tb.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(tb, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(tb, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(tb, err)
+	return ca, caKey
+}
+
+// generateOCSPTestCert 生成由 ca 签发、声明了 OCSP 服务器的 ocsp.example.com 证书，证书文件包含完整的链
+func generateOCSPTestCert(tb testing.TB, dir string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, ocspURL string) (certFile, keyFile string) {
+	tb.Helper()
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(tb, err)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "ocsp.example.com"},
+		DNSNames:     []string{"ocsp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:   []string{ocspURL},
+	}, ca, &leafKey.PublicKey, caKey)
+	require.NoError(tb, err)
+	leafKeyDER, err := x509.MarshalECPrivateKey(leafKey)
+	require.NoError(tb, err)
+
+	certFile, keyFile = filepath.Join(dir, "chain.pem"), filepath.Join(dir, "key.pem")
+	chain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	require.NoError(tb, os.WriteFile(certFile, chain, 0o644))
+	require.NoError(tb, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: leafKeyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestManager_OCSPStapling(t *testing.T) {
+	ca, caKey := generateTestCA(t)
+
+	// 模拟 CA 的 OCSP 服务器
+	var mu sync.Mutex
+	failing := false
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, caKey)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+	defer responder.Close()
+
+	certFile, keyFile := generateOCSPTestCert(t, t.TempDir(), ca, caKey, responder.URL)
+
+	logger := zerolog.Nop()
+	mgr, err := New(Config{Mode: ModeManual, CertFile: certFile, KeyFile: keyFile}, &logger)
+	require.NoError(t, err)
+	// 加载时登记，尚未获取响应
+	require.Len(t, mgr.OCSPStatus(), 1)
+	assert.False(t, mgr.OCSPStatus()[0].Stapled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, mgr.Start(ctx))
+	require.Eventually(t, func() bool { return mgr.OCSPStatus()[0].Stapled }, 5*time.Second, 10*time.Millisecond)
+
+	status := mgr.OCSPStatus()[0]
+	assert.Equal(t, "CN=ocsp.example.com", status.Subject)
+	assert.Equal(t, "good", status.Status)
+	assert.False(t, status.NextUpdate.IsZero())
+
+	cert, err := mgr.GetCertificate(&tls.ClientHelloInfo{ServerName: "ocsp.example.com"})
+	require.NoError(t, err)
+	resp, err := ocsp.ParseResponseForCert(cert.OCSPStaple, cert.Leaf, ca)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.Good, resp.Status)
+	// 共享的证书不会被修改
+	assert.Nil(t, mgr.CurrentCert().OCSPStaple)
+
+	// 刷新失败时保留仍然有效的旧响应
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	mgr.ocspMu.Lock()
+	for _, e := range mgr.ocsp {
+		e.refreshAt = time.Time{}
+	}
+	mgr.ocspMu.Unlock()
+	mgr.refreshOCSP(ctx)
+	status = mgr.OCSPStatus()[0]
+	assert.True(t, status.Stapled)
+	assert.Contains(t, status.LastError, "503")
+
+	// 关闭 Stapling 后不登记证书
+	disabled, err := New(Config{Mode: ModeManual, CertFile: certFile, KeyFile: keyFile, DisableOCSPStapling: true}, &logger)
+	require.NoError(t, err)
+	assert.Empty(t, disabled.OCSPStatus())
+	cert, err = disabled.GetCertificate(&tls.ClientHelloInfo{ServerName: "ocsp.example.com"})
+	require.NoError(t, err)
+	assert.Nil(t, cert.OCSPStaple)
+}
+
+func TestManager_OCSPStaplingACME(t *testing.T) {
+	ca, caKey := generateTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := generateOCSPTestCert(t, dir, ca, caKey, "http://127.0.0.1:1/ocsp")
+
+	logger := zerolog.Nop()
+	mgr, err := New(Config{Mode: ModeACME, ACME: ACME{CacheDir: t.TempDir(), Domains: []string{"ocsp.example.com"}}}, &logger)
+	require.NoError(t, err)
+	// 证书已在 autocert 的缓存中 (私钥在前，随后是证书链)，测试证书只有 1 小时有效期，避免触发续期
+	mgr.acmeManager.RenewBefore = time.Minute
+	keyPEM, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	chainPEM, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	require.NoError(t, mgr.acmeManager.Cache.Put(context.Background(), "ocsp.example.com", append(keyPEM, chainPEM...)))
+
+	hello := &tls.ClientHelloInfo{
+		ServerName:       "ocsp.example.com",
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+	}
+	first, err := mgr.GetCertificate(hello)
+	require.NoError(t, err)
+	second, err := mgr.GetCertificate(hello)
+	require.NoError(t, err)
+	require.NotSame(t, first, second, "autocert returns a new *tls.Certificate on every call")
+
+	// 首次握手时登记，注入已获取的响应
+	mgr.ocspMu.Lock()
+	require.Len(t, mgr.ocsp, 1)
+	for _, e := range mgr.ocsp {
+		e.staple = []byte("staple")
+		e.nextUpdate = time.Now().Add(time.Hour)
+	}
+	mgr.publishOCSP()
+	mgr.ocspMu.Unlock()
+
+	for range 10 {
+		cert, err := mgr.GetCertificate(hello)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("staple"), cert.OCSPStaple)
+	}
+	mgr.ocspMu.RLock()
+	defer mgr.ocspMu.RUnlock()
+	for _, e := range mgr.ocsp {
+		assert.Len(t, e.certs, 1, "handshakes must not register a new entry per returned wrapper")
+	}
+}
+
+func TestManager_OCSPRotation(t *testing.T) {
+	ca, caKey := generateTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := generateOCSPTestCert(t, dir, ca, caKey, "http://127.0.0.1:1/ocsp")
+
+	logger := zerolog.Nop()
+	mgr, err := New(Config{Mode: ModeManual, CertFile: certFile, KeyFile: keyFile}, &logger)
+	require.NoError(t, err)
+	hello := &tls.ClientHelloInfo{ServerName: "ocsp.example.com"}
+
+	// 已过 NextUpdate 的响应不再附加
+	mgr.ocspMu.Lock()
+	for _, e := range mgr.ocsp {
+		e.staple = []byte("staple")
+		e.nextUpdate = time.Now().Add(-time.Second)
+	}
+	mgr.publishOCSP()
+	mgr.ocspMu.Unlock()
+	cert, err := mgr.GetCertificate(hello)
+	require.NoError(t, err)
+	assert.Nil(t, cert.OCSPStaple)
+
+	// 证书轮换后不再为旧证书刷新响应
+	old := mgr.CurrentCert()
+	generateOCSPTestCert(t, dir, ca, caKey, "http://127.0.0.1:1/ocsp")
+	require.NoError(t, mgr.Reload())
+	require.NotSame(t, old, mgr.CurrentCert())
+	mgr.ocspMu.RLock()
+	defer mgr.ocspMu.RUnlock()
+	require.Len(t, mgr.ocsp, 1)
+	assert.Contains(t, mgr.ocsp, string(mgr.CurrentCert().Leaf.Raw))
+	assert.NotContains(t, *mgr.ocspStaples.Load(), old.Leaf)
+}
+
+func TestManager_CertInfo(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := generateNamedTestCert(t, dir, "info", "info.example.com", "www.info.example.com")
+
+	mgr, err := New(Config{Mode: ModeManual, CertFile: certFile, KeyFile: keyFile}, &log.Logger)
+	require.NoError(t, err)
+	info := mgr.CertInfo()
+	assert.Equal(t, SourceManual, info.Source)
+	assert.False(t, info.UsingACME)
+	assert.Equal(t, "CN=info.example.com", info.Subject)
+	assert.Equal(t, "CN=info.example.com", info.Issuer)
+	assert.Equal(t, []string{"info.example.com", "www.info.example.com"}, info.DNSNames)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), info.NotAfter, time.Minute)
+	assert.Equal(t, 0, info.DaysUntilExpiry)
+
+	self, err := New(Config{Mode: ModeSelfSigned}, &log.Logger)
+	require.NoError(t, err)
+	info = self.CertInfo()
+	assert.Equal(t, SourceSelfSigned, info.Source)
+	assert.Contains(t, info.IPAddresses, "127.0.0.1")
+	assert.Equal(t, int(selfSignedValidity/(24*time.Hour))-1, info.DaysUntilExpiry)
+
+	acmeMgr, err := New(Config{Mode: ModeACME, ACME: ACME{CacheDir: t.TempDir(), Domains: []string{"example.com"}}}, &log.Logger)
+	require.NoError(t, err)
+	assert.Equal(t, CertInfo{Source: SourceACME, UsingACME: true, ACMEDomains: []string{"example.com"}}, acmeMgr.CertInfo())
+
+	// 与重载并发调用
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 20 {
+			assert.NoError(t, mgr.reloadFileCert())
+		}
+	}()
+	for range 100 {
+		assert.Equal(t, "CN=info.example.com", mgr.CertInfo().Subject)
+	}
+	wg.Wait()
+
+	w := httptest.NewRecorder()
+	mgr.CertInfoHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/cert", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"days_until_expiry":0`)
+	assert.Contains(t, w.Body.String(), `"source":"manual"`)
+}
+
+func TestManager_WatchMode(t *testing.T) {
+	_, err := New(Config{WatchMode: "inotify"}, &log.Logger)
+	assert.ErrorContains(t, err, `unknown watch mode "inotify"`)
+
+	// rotate 模拟 certbot/cert-manager 的原子替换：写入临时文件后重命名，文件的 inode 发生变化
+	rotate := func(t *testing.T, certFile, keyFile, host string) {
+		c, k := generateNamedTestCert(t, t.TempDir(), "next", host)
+		require.NoError(t, os.Rename(c, certFile))
+		require.NoError(t, os.Rename(k, keyFile))
+	}
+
+	for _, tc := range []struct {
+		name     string
+		mode     string
+		interval time.Duration
+	}{
+		{"Poll", WatchPoll, 20 * time.Millisecond},
+		// 轮询周期足够长，只有 fsnotify 能及时发现变化
+		{"FSNotify", WatchFSNotify, time.Hour},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+			rotate(t, certFile, keyFile, "old.example.com")
+
+			mgr, err := New(Config{Mode: ModeManual, CertFile: certFile, KeyFile: keyFile, WatchMode: tc.mode, WatchInterval: tc.interval}, &log.Logger)
+			require.NoError(t, err)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			require.NoError(t, mgr.Start(ctx))
+			assert.Equal(t, "CN=old.example.com", mgr.CertInfo().Subject)
+
+			rotate(t, certFile, keyFile, "new.example.com")
+			assert.Eventually(t, func() bool { return mgr.CertInfo().Subject == "CN=new.example.com" },
+				3*time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestManager_Metrics(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := generateTestCert(t, dir, 10*time.Hour)
+
+	reg := prometheus.NewRegistry()
+	mgr, err := New(Config{
+		CertFile:              certFile,
+		KeyFile:               keyFile,
+		FallbackThresholdDays: 30,
+		ACME:                  ACME{Enabled: true, CacheDir: dir},
+		Registerer:            reg,
+	}, &log.Logger)
+	require.NoError(t, err)
+
+	leaf := mgr.CurrentCert().Leaf
+	assert.Equal(t, float64(leaf.NotAfter.Unix()), testutil.ToFloat64(mgr.metrics.expiry))
+	assert.Equal(t, 1.0, testutil.ToFloat64(mgr.metrics.reloads))
+	assert.Equal(t, 0.0, testutil.ToFloat64(mgr.metrics.reloadErrors))
+	assert.Equal(t, 0.0, testutil.ToFloat64(mgr.metrics.acmeActive))
+
+	// 即将过期，切换到 ACME
+	mgr.checkExpiration()
+	assert.Equal(t, 1.0, testutil.ToFloat64(mgr.metrics.acmeActive))
+
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0600))
+	require.Error(t, mgr.reloadFileCert())
+	assert.Equal(t, 2.0, testutil.ToFloat64(mgr.metrics.reloads))
+	assert.Equal(t, 1.0, testutil.ToFloat64(mgr.metrics.reloadErrors))
+
+	// 同一 Registerer 上的 Manager 共享指标
+	other, err := New(Config{Mode: ModeSelfSigned, Registerer: reg}, &log.Logger)
+	require.NoError(t, err)
+	assert.Same(t, mgr.metrics.reloads, other.metrics.reloads)
+	n, err := testutil.GatherAndCount(reg, "appx_cert_expiry_timestamp_seconds", "appx_cert_reload_total",
+		"appx_cert_reload_errors_total", "appx_cert_acme_active")
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+}
+
+func TestManager_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := generateNamedTestCert(t, dir, "server", "old.example.com")
+	mgr, err := New(Config{CertFile: certFile, KeyFile: keyFile, ACME: ACME{Enabled: true, CacheDir: dir}}, &log.Logger)
+	require.NoError(t, err)
+
+	// 证书损坏时返回错误并继续使用旧证书
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o644))
+	assert.Error(t, mgr.Reload())
+	assert.Equal(t, "CN=old.example.com", mgr.CertInfo().Subject)
+
+	// 正在使用 ACME 降级时，重载成功后切换回手动证书
+	mgr.setUseACME(true)
+	generateNamedTestCert(t, dir, "server", "new.example.com")
+	require.NoError(t, mgr.Reload())
+	info := mgr.CertInfo()
+	assert.Equal(t, SourceManual, info.Source)
+	assert.Equal(t, "CN=new.example.com", info.Subject)
+
+	// 没有证书文件时无事可做
+	self, err := New(Config{Mode: ModeSelfSigned}, &log.Logger)
+	require.NoError(t, err)
+	assert.NoError(t, self.Reload())
+}
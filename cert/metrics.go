@@ -0,0 +1,77 @@
+package cert
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// acmeIssuanceTotal 统计 ACME 证书签发 (含续期) 的结果，注册在 Prometheus 默认 Registry 上
+var acmeIssuanceTotal = registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "appx",
+	Name:      "acme_issuance_total",
+	Help:      "Number of ACME certificate issuance attempts by result.",
+}, []string{"result"}))
+
+// appx_acme_issuance_total 的 result 标签
+const (
+	issuanceSuccess = "success"
+	issuanceFailure = "failure"
+)
+
+// managerMetrics 是 Manager 的证书指标，由 New 注册在 Config.Registerer (默认为 Prometheus 默认 Registry) 上。
+// 同一 Registerer 上的多个 Manager 共享同一组指标；需要区分时可为每个 Manager 传入
+// prometheus.WrapRegistererWith 附加了标签的 Registerer。
+type managerMetrics struct {
+	expiry       prometheus.Gauge   // 当前手动 (或自签名) 证书的过期时间
+	reloads      prometheus.Counter // 证书文件重载次数 (含失败)
+	reloadErrors prometheus.Counter // 证书文件重载失败次数
+	acmeActive   prometheus.Gauge   // 正在使用 ACME 时为 1
+}
+
+func newManagerMetrics(reg prometheus.Registerer) *managerMetrics {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return &managerMetrics{
+		expiry: registerCollectorOn(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "appx",
+			Name:      "cert_expiry_timestamp_seconds",
+			Help:      "Expiry time of the manually loaded or self-signed certificate in unix seconds.",
+		})),
+		reloads: registerCollectorOn(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "appx",
+			Name:      "cert_reload_total",
+			Help:      "Number of certificate file reload attempts, including failures.",
+		})),
+		reloadErrors: registerCollectorOn(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "appx",
+			Name:      "cert_reload_errors_total",
+			Help:      "Number of failed certificate file reloads.",
+		})),
+		acmeActive: registerCollectorOn(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "appx",
+			Name:      "cert_acme_active",
+			Help:      "Whether certificates are currently served by ACME (1) or from files (0).",
+		})),
+	}
+}
+
+// registerCollector 注册指标到默认 Registry，同名指标已存在时复用已存在的实例
+func registerCollector[T prometheus.Collector](c T) T {
+	return registerCollectorOn(prometheus.DefaultRegisterer, c)
+}
+
+// registerCollectorOn 注册指标到 reg，同名指标已存在时复用已存在的实例
+func registerCollectorOn[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,333 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspCheckInterval 是检查 OCSP 响应是否需要刷新的周期
+	ocspCheckInterval = time.Minute
+	// ocspRetryInterval 获取 OCSP 响应失败后的重试间隔
+	ocspRetryInterval = 10 * time.Minute
+	// ocspFetchTimeout 单次请求 OCSP 服务器的超时时间
+	ocspFetchTimeout = 10 * time.Second
+	// ocspMaxResponseSize OCSP 响应的最大字节数
+	ocspMaxResponseSize = 1 << 20
+)
+
+// OCSPStatus 是一张证书的 OCSP Stapling 状态，可通过 Manager.OCSPStatus 暴露给运维端点
+type OCSPStatus struct {
+	Subject    string    `json:"subject"`
+	NotAfter   time.Time `json:"not_after"`
+	Stapled    bool      `json:"stapled"`              // 当前握手是否附带 OCSP 响应
+	Status     string    `json:"status,omitempty"`     // CA 返回的证书状态: good | revoked | unknown
+	ThisUpdate time.Time `json:"this_update,omitzero"` // 当前 OCSP 响应的生成时间
+	NextUpdate time.Time `json:"next_update,omitzero"` // 当前 OCSP 响应的过期时间
+	LastError  string    `json:"last_error,omitempty"` // 最近一次获取失败的原因
+}
+
+// ocspEntry 是一张证书的 OCSP 缓存，除 leaf/issuer 外的字段由 Manager.ocspMu 保护
+type ocspEntry struct {
+	leaf, issuer *x509.Certificate
+	// certs 是使用该叶子证书、已登记的 *tls.Certificate，每个解析出的 Leaf 只登记一次
+	// (同一证书重新加载后是新的 Leaf；autocert 每次握手返回新的 *tls.Certificate，但复用同一个 Leaf)
+	certs []*tls.Certificate
+
+	staple     []byte // 状态为 good 且未过期的原始 OCSP 响应
+	status     string
+	thisUpdate time.Time
+	nextUpdate time.Time
+	refreshAt  time.Time
+	lastErr    error
+}
+
+// stapledCert 是一张已登记证书当前可用的 OCSP 响应
+type stapledCert struct {
+	src        *tls.Certificate // 登记时的证书
+	cert       *tls.Certificate // src 附加了响应的浅拷贝 (共享的原证书不被修改)
+	staple     []byte
+	nextUpdate time.Time // 响应的过期时间
+}
+
+// stapleOCSP 为握手返回的证书附加缓存的 OCSP 响应。
+// 该方法位于 TLS 握手热路径，必须保持无锁：握手路径读取的表由 publishOCSP 预先构建并整体发布，按 Leaf 指针查找。
+// 手动与 DNS-01 证书直接返回预先构建的拷贝，不产生分配；autocert 每次返回新的 *tls.Certificate，只能在此浅拷贝一次。
+// 响应已过 NextUpdate 时不再附加 (要求 must-staple 的客户端会拒绝过期的响应)。
+// 首次见到的 Leaf (如 autocert 新签发的证书) 登记后交给 watcher 协程获取响应。
+func (m *Manager) stapleOCSP(cert *tls.Certificate) *tls.Certificate {
+	if !m.ocspEligible(cert) {
+		return cert
+	}
+	staples := m.ocspStaples.Load()
+	if staples == nil {
+		m.registerOCSP(cert)
+		return cert
+	}
+	stapled, ok := (*staples)[cert.Leaf]
+	if !ok {
+		m.registerOCSP(cert)
+		return cert
+	}
+	if stapled == nil || !time.Now().Before(stapled.nextUpdate) {
+		return cert
+	}
+	if cert == stapled.src {
+		return stapled.cert
+	}
+	c := *cert
+	c.OCSPStaple = stapled.staple
+	return &c
+}
+
+// publishOCSP 重建握手路径使用的证书表 (copy-on-write)：已登记证书的 Leaf 映射到当前可用的响应，
+// 没有可用响应时映射到 nil。调用方必须持有 ocspMu 写锁。
+func (m *Manager) publishOCSP() {
+	staples := make(map[*x509.Certificate]*stapledCert)
+	for _, e := range m.ocsp {
+		for _, cert := range e.certs {
+			var stapled *stapledCert
+			if e.staple != nil {
+				c := *cert
+				c.OCSPStaple = e.staple
+				stapled = &stapledCert{src: cert, cert: &c, staple: e.staple, nextUpdate: e.nextUpdate}
+			}
+			staples[cert.Leaf] = stapled
+		}
+	}
+	m.ocspStaples.Store(&staples)
+}
+
+// indexLeaf 返回 certs 中与 cert 使用同一个 Leaf 的证书下标，不存在时返回 -1
+func indexLeaf(certs []*tls.Certificate, cert *tls.Certificate) int {
+	return slices.IndexFunc(certs, func(c *tls.Certificate) bool { return c.Leaf == cert.Leaf })
+}
+
+// ocspEligible 判断证书是否可以 Stapling：声明了 OCSP 服务器，且链中带有签发者证书
+func (m *Manager) ocspEligible(cert *tls.Certificate) bool {
+	return !m.cfg.DisableOCSPStapling && cert != nil && cert.Leaf != nil &&
+		len(cert.Leaf.OCSPServer) > 0 && len(cert.Certificate) >= 2
+}
+
+// registerOCSP 登记需要 Stapling 的证书，并唤醒 watcher 协程立即获取。
+// 手动与 DNS-01 证书在加载时登记，autocert 签发的证书在首次握手时登记。
+func (m *Manager) registerOCSP(cert *tls.Certificate) {
+	if !m.ocspEligible(cert) {
+		return
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return
+	}
+
+	m.ocspMu.Lock()
+	key := string(cert.Leaf.Raw)
+	e, exists := m.ocsp[key]
+	if !exists {
+		e = &ocspEntry{leaf: cert.Leaf, issuer: issuer}
+		m.ocsp[key] = e
+	}
+	if indexLeaf(e.certs, cert) < 0 {
+		e.certs = append(e.certs, cert)
+		m.publishOCSP()
+	}
+	m.ocspMu.Unlock()
+
+	if !exists {
+		select {
+		case m.ocspKick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// unregisterOCSP 注销不再对外提供的证书 (重载后被替换的旧证书)，叶子证书不再被任何已登记的证书使用时删除其缓存，
+// 不再为其刷新响应
+func (m *Manager) unregisterOCSP(certs ...*tls.Certificate) {
+	m.ocspMu.Lock()
+	defer m.ocspMu.Unlock()
+	changed := false
+	for _, cert := range certs {
+		if cert == nil || cert.Leaf == nil {
+			continue
+		}
+		key := string(cert.Leaf.Raw)
+		e, ok := m.ocsp[key]
+		if !ok {
+			continue
+		}
+		i := indexLeaf(e.certs, cert)
+		if i < 0 {
+			continue
+		}
+		e.certs = slices.Delete(e.certs, i, i+1)
+		if len(e.certs) == 0 {
+			delete(m.ocsp, key)
+		}
+		changed = true
+	}
+	if changed {
+		m.publishOCSP()
+	}
+}
+
+// refreshOCSP 由 watcher 协程调用，在响应有效期过半时刷新，失败时保留仍然有效的旧响应，并清理已过期证书的缓存
+func (m *Manager) refreshOCSP(ctx context.Context) {
+	now := time.Now()
+	var due []*ocspEntry
+	m.ocspMu.Lock()
+	expired := false
+	for key, e := range m.ocsp {
+		if now.After(e.leaf.NotAfter) {
+			delete(m.ocsp, key)
+			expired = true
+			continue
+		}
+		if !now.Before(e.refreshAt) {
+			due = append(due, e)
+		}
+	}
+	if expired {
+		m.publishOCSP()
+	}
+	m.ocspMu.Unlock()
+
+	for _, e := range due {
+		raw, resp, err := fetchOCSP(ctx, e.leaf, e.issuer)
+		if ctx.Err() != nil {
+			return
+		}
+
+		m.ocspMu.Lock()
+		e.lastErr = err
+		if err == nil {
+			e.status = ocspStatusName(resp.Status)
+			e.thisUpdate, e.nextUpdate = resp.ThisUpdate, resp.NextUpdate
+			e.staple = nil
+			if resp.Status == ocsp.Good {
+				e.staple = raw
+			}
+			e.refreshAt = resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+		} else {
+			// 保留旧响应直到过期
+			if !e.nextUpdate.IsZero() && now.After(e.nextUpdate) {
+				e.staple = nil
+			}
+			e.refreshAt = now.Add(ocspRetryInterval)
+		}
+		m.publishOCSP()
+		m.ocspMu.Unlock()
+
+		switch {
+		case err != nil:
+			m.logger.Warn().Err(err).Str("subject", e.leaf.Subject.String()).Msg("Failed to fetch OCSP response")
+		case resp.Status == ocsp.Revoked:
+			m.logger.Error().Str("subject", e.leaf.Subject.String()).Time("revoked_at", resp.RevokedAt).
+				Msg("Certificate has been revoked according to OCSP")
+		default:
+			m.logger.Debug().Str("subject", e.leaf.Subject.String()).Str("status", ocspStatusName(resp.Status)).
+				Time("next_update", resp.NextUpdate).Msg("OCSP response refreshed")
+		}
+	}
+}
+
+// fetchOCSP 向证书的 OCSP 服务器查询状态，并校验响应的签名与有效期
+func fetchOCSP(ctx context.Context, leaf, issuer *x509.Certificate) ([]byte, *ocsp.Response, error) {
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ocspFetchTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	httpReq.Header.Set("Accept", "application/ocsp-response")
+
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP server %s returned %s", leaf.OCSPServer[0], httpResp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(httpResp.Body, ocspMaxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.NextUpdate.IsZero() {
+		return nil, nil, errors.New("OCSP response has no NextUpdate")
+	}
+	if time.Now().After(resp.NextUpdate) {
+		return nil, nil, fmt.Errorf("OCSP response expired at %s", resp.NextUpdate.Format(time.RFC3339))
+	}
+	return raw, resp, nil
+}
+
+func ocspStatusName(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// OCSPStatus 返回已登记证书的 OCSP Stapling 状态，按主题排序。
+// 证书没有 OCSP 服务器地址或链中缺少签发者时不会登记，也不会出现在结果中。
+func (m *Manager) OCSPStatus() []OCSPStatus {
+	m.ocspMu.RLock()
+	defer m.ocspMu.RUnlock()
+
+	statuses := make([]OCSPStatus, 0, len(m.ocsp))
+	for _, e := range m.ocsp {
+		s := OCSPStatus{
+			Subject:    e.leaf.Subject.String(),
+			NotAfter:   e.leaf.NotAfter,
+			Stapled:    e.staple != nil,
+			Status:     e.status,
+			ThisUpdate: e.thisUpdate,
+			NextUpdate: e.nextUpdate,
+		}
+		if e.lastErr != nil {
+			s.LastError = e.lastErr.Error()
+		}
+		statuses = append(statuses, s)
+	}
+	slices.SortFunc(statuses, func(a, b OCSPStatus) int { return strings.Compare(a.Subject, b.Subject) })
+	return statuses
+}
+
+// OCSPStatusHandler 以 JSON 返回 OCSPStatus，可挂载到监控服务：
+//
+//	appx.MonitorOptions{ExtraHandlers: map[string]http.Handler{"/debug/ocsp": certMgr.OCSPStatusHandler()}}
+func (m *Manager) OCSPStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := sonic.Marshal(m.OCSPStatus())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
@@ -0,0 +1,80 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// selfSignedValidity 是临时自签名证书的有效期，进程重启后重新生成
+const selfSignedValidity = 30 * 24 * time.Hour
+
+// loadSelfSigned 生成仅存在于内存中的自签名证书。
+// 证书覆盖 ACME.Domains 中的域名，未配置时覆盖 localhost 与回环地址。
+func (m *Manager) loadSelfSigned() error {
+	hosts := m.cfg.ACME.Domains
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+
+	cert, err := selfSignedCertificate(hosts, time.Now())
+	if err != nil {
+		return fmt.Errorf("cert manager: generate self-signed certificate: %w", err)
+	}
+	loadedAt := time.Now()
+	m.manualCert.Store(cert)
+	m.manualLoadedAt.Store(&loadedAt)
+	m.metrics.expiry.Set(float64(cert.Leaf.NotAfter.Unix()))
+
+	m.logger.Warn().
+		Strs("hosts", hosts).
+		Time("expires", cert.Leaf.NotAfter).
+		Msg("Using ephemeral self-signed certificate, do not use in production")
+	return nil
+}
+
+// selfSignedCertificate 为 hosts 生成 ECDSA P-256 自签名证书
+func selfSignedCertificate(hosts []string, now time.Time) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"appx self-signed"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
@@ -0,0 +1,120 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sniIndex 是按 SNI 域名索引的证书表。
+// 构建完成后只读，重载时整体替换 (copy-on-write)，因此握手路径上的查找无需加锁。
+type sniIndex struct {
+	exact map[string]*tls.Certificate
+	// wildcard 的 key 为通配符证书去掉 "*." 后的父域名，查找时直接截取子串，避免拼接字符串产生分配
+	wildcard map[string]*tls.Certificate
+}
+
+// lookup 按 SNI 查找证书，精确匹配优先于通配符匹配，未命中返回 nil。
+// 该方法位于 TLS 握手热路径，必须保持零分配。
+func (idx *sniIndex) lookup(serverName string) *tls.Certificate {
+	// strings.ToLower 在输入已是小写 ASCII 时直接返回原串，不产生分配
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if cert, ok := idx.exact[name]; ok {
+		return cert
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		return idx.wildcard[name[i+1:]]
+	}
+	return nil
+}
+
+// loadSNIPairs 逐对加载 Config.Certificates 中的证书，各对互不影响：
+// 某一对加载失败时沿用 prev 中同位置的旧证书，err 汇总所有失败的证书；
+// 失败的证书没有旧证书可沿用 (如首次加载) 时 complete 为 false，调用方应放弃本次加载。
+func loadSNIPairs(pairs []KeyPair, prev []*tls.Certificate) (certs []*tls.Certificate, complete bool, err error) {
+	certs = make([]*tls.Certificate, len(pairs))
+	var errs []error
+	complete = true
+	for i, pair := range pairs {
+		cert, err := loadSNIPair(pair)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("load %s: %w", pair.CertFile, err))
+			if i >= len(prev) || prev[i] == nil {
+				complete = false
+				continue
+			}
+			cert = prev[i]
+		}
+		certs[i] = cert
+	}
+	return certs, complete, errors.Join(errs...)
+}
+
+// loadSNIPair 加载一对证书，叶子证书必须带有可用于 SNI 匹配的域名
+func loadSNIPair(pair KeyPair) (*tls.Certificate, error) {
+	cert, err := loadKeyPair(pair.CertFile, pair.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(sniNames(cert.Leaf)) == 0 {
+		return nil, errors.New("no DNS names found in certificate")
+	}
+	return cert, nil
+}
+
+// loadKeyPair 加载证书与私钥并解析叶子证书
+func loadKeyPair(certFile, keyFile string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", certFile)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &cert, nil
+}
+
+// sniNames 返回用于 SNI 匹配的域名：DNS SAN，没有时使用 CommonName
+func sniNames(leaf *x509.Certificate) []string {
+	if len(leaf.DNSNames) == 0 && leaf.Subject.CommonName != "" {
+		return []string{leaf.Subject.CommonName}
+	}
+	return leaf.DNSNames
+}
+
+// buildSNIIndex 按叶子证书的域名建立索引，多张证书覆盖同一域名时靠前的优先
+func buildSNIIndex(certs []*tls.Certificate) *sniIndex {
+	idx := newSNIIndex()
+	for _, cert := range certs {
+		idx.add(cert, sniNames(cert.Leaf))
+	}
+	return idx
+}
+
+func newSNIIndex() *sniIndex {
+	return &sniIndex{
+		exact:    make(map[string]*tls.Certificate),
+		wildcard: make(map[string]*tls.Certificate),
+	}
+}
+
+// add 以 names (可含 "*." 通配符) 索引证书，已被先加入的证书占用的域名保持不变
+func (idx *sniIndex) add(cert *tls.Certificate, names []string) {
+	for _, name := range names {
+		name = strings.ToLower(name)
+		table := idx.exact
+		if parent, ok := strings.CutPrefix(name, "*."); ok {
+			name, table = parent, idx.wildcard
+		}
+		if _, exists := table[name]; !exists {
+			table[name] = cert
+		}
+	}
+}
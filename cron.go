@@ -0,0 +1,200 @@
+package appx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule 计算下一次触发时间
+type cronSchedule interface {
+	next(t time.Time) time.Time
+}
+
+// cronSpec 是解析后的标准 5 字段 cron 表达式 (分 时 日 月 周)，每个字段是允许取值的位图
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// 日与周同时受限时二者满足其一即可 (与 Vixie cron 一致)，否则必须同时满足
+	domStar, dowStar bool
+	loc              *time.Location
+}
+
+// everySchedule 对应 "@every <duration>"，按固定间隔触发
+type everySchedule time.Duration
+
+func (e everySchedule) next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cronField 描述一个字段的取值范围与可用的名称
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 周字段允许 7 表示周日，解析后折叠为 0
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronDescriptors 是预定义的表达式别名
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron 解析标准 5 字段 cron 表达式，支持 *、范围 (1-5)、步长 (*/15, 1-30/5)、列表 (1,15)、
+// 月份与星期的英文缩写 (jan, mon)、预定义别名 (@daily 等) 以及 "@every 90s"。
+// 时间按 loc 计算。
+func parseCron(spec string, loc *time.Location) (cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", spec, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("cron %q: interval must be positive", spec)
+		}
+		return everySchedule(interval), nil
+	}
+	if expanded, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields (minute hour day-of-month month day-of-week), got %d", spec, len(fields))
+	}
+
+	s := &cronSpec{loc: loc}
+	var err error
+	if s.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", spec, err)
+	}
+	if s.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", spec, err)
+	}
+	if s.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", spec, err)
+	}
+	if s.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", spec, err)
+	}
+	if s.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", spec, err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+// parse 将字段解析为取值位图
+func (f cronField) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, stepExpr)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rangeExpr != "*" && rangeExpr != "?" {
+			loExpr, hiExpr, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if lo, err = f.value(loExpr); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiExpr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/15" 等价于 "5-max/15"
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid %s range %q", f.name, rangeExpr)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value 解析字段中的单个值 (数字或名称)，并检查取值范围
+func (f cronField) value(expr string) (int, error) {
+	if v, ok := f.names[strings.ToLower(expr)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(expr)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q (allowed %d-%d)", f.name, expr, f.min, f.max)
+	}
+	return v, nil
+}
+
+// next 返回严格晚于 t 的下一次触发时间，表达式永远无法满足 (如 2 月 30 日) 时返回零值
+func (s *cronSpec) next(t time.Time) time.Time {
+	origLoc := t.Location()
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+
+	// 最多向后搜索 5 年，覆盖闰年 2 月 29 日等稀疏的表达式
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t.In(origLoc)
+	}
+	return time.Time{}
+}
+
+func (s *cronSpec) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
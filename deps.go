@@ -0,0 +1,162 @@
+package appx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddWithDeps 注册服务并声明其依赖的服务名称 (Name())。
+// Run 会按依赖关系的拓扑顺序启动服务 (依赖先于依赖方启动，无依赖关系的服务保持注册顺序)，
+// 并以相反的顺序停止，避免入口服务在其依赖 (如连接池、缓存预热) 就绪之前接收流量。
+// 依赖的服务可以稍后再注册；依赖不存在或存在循环依赖时 Run 在启动任何服务之前返回错误。
+// 与 Add 相同，在 Run 之后调用或服务重名时 panic。
+func (s *Appx) AddWithDeps(svc Service, deps ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		panic(fmt.Sprintf("appx: AddWithDeps(%q) called after Run", svc.Name()))
+	}
+	if err := s.checkDuplicate(svc); err != nil {
+		panic(err.Error())
+	}
+	if len(deps) > 0 {
+		if s.deps == nil {
+			s.deps = make(map[string][]string)
+		}
+		s.deps[svc.Name()] = append([]string(nil), deps...)
+	}
+	s.enroll(svc)
+}
+
+// ShutdownOrder 返回 Run 关闭时停止服务的顺序 (服务名)，即按依赖关系排好的启动顺序的逆序。
+// 关闭流程逐个同步调用 Stop，上一个服务的 Stop 返回后才会停止下一个，因此该顺序是确定的，
+// 可用于在测试中断言关闭顺序，而不必依赖 sleep。依赖声明有误时返回与 Run 相同的错误。
+// 开启 WithConcurrentStop 时为 ShutdownPhases 依次展开的结果，同一阶段内的服务实际是并发停止的。
+func (s *Appx) ShutdownOrder() ([]string, error) {
+	phases, err := s.ShutdownPhases()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, phase := range phases {
+		names = append(names, phase...)
+	}
+	return names, nil
+}
+
+// ShutdownPhases 返回 Run 关闭时的停止阶段 (服务名)，阶段之间按顺序执行，上一阶段的服务全部停止后才进入下一阶段。
+// 默认每个阶段只有一个服务，与 ShutdownOrder 一致；开启 WithConcurrentStop 时同一阶段内的服务并发停止。
+// 依赖声明有误时返回与 Run 相同的错误。
+func (s *Appx) ShutdownPhases() ([][]string, error) {
+	s.mu.Lock()
+	ordered, err := orderServices(append([]Service(nil), s.services...), s.deps)
+	var phases [][]Service
+	if err == nil {
+		phases = shutdownPhases(ordered, s.deps, s.concurrentStop)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([][]string, len(phases))
+	for i, phase := range phases {
+		names[i] = make([]string, len(phase))
+		for j, svc := range phase {
+			names[i][j] = svc.Name()
+		}
+	}
+	return names, nil
+}
+
+// shutdownPhases 将按启动顺序排好的服务划分为关闭阶段。
+// concurrent 为 false 时按启动顺序的逆序每个服务单独一个阶段；
+// 为 true 时按依赖的高度分组：没有依赖方的服务 (通常是入口服务) 在第一阶段，
+// 其余服务在其所有依赖方停止之后的下一阶段，阶段内保持启动顺序的逆序。
+func shutdownPhases(ordered []Service, deps map[string][]string, concurrent bool) [][]Service {
+	if !concurrent {
+		phases := make([][]Service, len(ordered))
+		for i, svc := range ordered {
+			phases[len(ordered)-1-i] = []Service{svc}
+		}
+		return phases
+	}
+
+	dependents := make(map[string][]string)
+	for _, svc := range ordered {
+		for _, dep := range deps[svc.Name()] {
+			dependents[dep] = append(dependents[dep], svc.Name())
+		}
+	}
+
+	// 依赖方一定排在被依赖的服务之后，逆序遍历时其高度已经确定
+	height := make(map[string]int, len(ordered))
+	var phases [][]Service
+	for i := len(ordered) - 1; i >= 0; i-- {
+		svc := ordered[i]
+		h := 0
+		for _, d := range dependents[svc.Name()] {
+			h = max(h, height[d]+1)
+		}
+		height[svc.Name()] = h
+		for len(phases) <= h {
+			phases = append(phases, nil)
+		}
+		phases[h] = append(phases[h], svc)
+	}
+	return phases
+}
+
+// orderServices 按依赖关系对服务做稳定的拓扑排序：
+// 每一轮选出注册顺序最靠前、且依赖均已排好的服务。
+func orderServices(services []Service, deps map[string][]string) ([]Service, error) {
+	if len(deps) == 0 {
+		return services, nil
+	}
+
+	known := make(map[string]bool, len(services))
+	for _, svc := range services {
+		known[svc.Name()] = true
+	}
+	for _, svc := range services {
+		for _, dep := range deps[svc.Name()] {
+			if !known[dep] {
+				return nil, fmt.Errorf("appx: service %q depends on unknown service %q", svc.Name(), dep)
+			}
+		}
+	}
+
+	ordered := make([]Service, 0, len(services))
+	placed := make(map[string]bool, len(services))
+	remaining := append([]Service(nil), services...)
+	for len(remaining) > 0 {
+		next := -1
+		for i, svc := range remaining {
+			ready := true
+			for _, dep := range deps[svc.Name()] {
+				if !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			names := make([]string, len(remaining))
+			for i, svc := range remaining {
+				names[i] = svc.Name()
+			}
+			return nil, fmt.Errorf("appx: dependency cycle among services: %s", strings.Join(names, ", "))
+		}
+		svc := remaining[next]
+		ordered = append(ordered, svc)
+		placed[svc.Name()] = true
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+	return ordered, nil
+}
@@ -0,0 +1,189 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppx_AddWithDeps_Order(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+	newSvc := func(name string) *MockService {
+		return &MockService{
+			name:      name,
+			startFunc: func(context.Context) error { record("start " + name); return nil },
+			stopFunc:  func(context.Context) error { record("stop " + name); return nil },
+		}
+	}
+
+	grpcSvc := newSvc("grpc")
+	app.AddWithDeps(grpcSvc, "warmup")
+	app.Add(newSvc("metrics"))
+	// 依赖可以晚于依赖方注册
+	app.AddWithDeps(newSvc("warmup"), "db")
+	app.Add(newSvc("db"))
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+	grpcSvc.errHandler(errors.New("stop"))
+	require.EqualError(t, <-runErr, "stop")
+
+	assert.Equal(t, []string{
+		"start metrics", "start db", "start warmup", "start grpc",
+		"stop grpc", "stop warmup", "stop db", "stop metrics",
+	}, events)
+}
+
+func TestAppx_AddWithDeps_Invalid(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("UnknownDependency", func(t *testing.T) {
+		app := New(WithLogger(&logger))
+		started := false
+		app.AddWithDeps(&MockService{name: "api", startFunc: func(context.Context) error {
+			started = true
+			return nil
+		}}, "pool")
+
+		err := app.Run()
+		assert.EqualError(t, err, `appx: service "api" depends on unknown service "pool"`)
+		assert.False(t, started)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		app := New(WithLogger(&logger))
+		app.Add(&MockService{name: "standalone"})
+		app.AddWithDeps(&MockService{name: "a"}, "b")
+		app.AddWithDeps(&MockService{name: "b"}, "a")
+
+		err := app.Run()
+		assert.EqualError(t, err, "appx: dependency cycle among services: a, b")
+	})
+}
+
+func TestAppx_ShutdownOrder(t *testing.T) {
+	logger := zerolog.Nop()
+
+	var mu sync.Mutex
+	var stopped, observed []string
+	app := New(WithLogger(&logger), WithLifecycleObserver(func(e LifecycleEvent) {
+		if e.Phase == PhaseServiceStopped {
+			observed = append(observed, e.Service)
+		}
+	}))
+	newSvc := func(name string) *MockService {
+		return &MockService{name: name, stopFunc: func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			stopped = append(stopped, name)
+			return nil
+		}}
+	}
+
+	app.AddWithDeps(newSvc("http"), "cache", "db")
+	app.Add(newSvc("metrics"))
+	app.AddWithDeps(newSvc("cache"), "db")
+	app.Add(newSvc("db"))
+
+	order, err := app.ShutdownOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http", "cache", "db", "metrics"}, order)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-runErr)
+
+	assert.Equal(t, order, stopped)
+	assert.Equal(t, order, observed)
+
+	// 依赖声明有误时与 Run 返回相同的错误
+	bad := New(WithLogger(&logger))
+	bad.AddWithDeps(&MockService{name: "api"}, "pool")
+	_, err = bad.ShutdownOrder()
+	assert.EqualError(t, err, `appx: service "api" depends on unknown service "pool"`)
+}
+
+func TestAppx_ConcurrentStop(t *testing.T) {
+	logger := zerolog.Nop()
+
+	var mu sync.Mutex
+	var observed []string
+	app := New(WithLogger(&logger), WithConcurrentStop(), WithShutdownTimeout(5*time.Second), WithLifecycleObserver(func(e LifecycleEvent) {
+		if e.Phase == PhaseServiceStopped {
+			observed = append(observed, e.Service)
+		}
+	}))
+
+	// 两个入口服务互相等待对方进入 Stop，只有并发停止时才能在超时前完成
+	var entered sync.WaitGroup
+	entered.Add(2)
+	var stopped []string
+	newSvc := func(name string, barrier bool) *MockService {
+		return &MockService{name: name, stopFunc: func(ctx context.Context) error {
+			if barrier {
+				entered.Done()
+				done := make(chan struct{})
+				go func() { entered.Wait(); close(done) }()
+				select {
+				case <-done:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			stopped = append(stopped, name)
+			return nil
+		}}
+	}
+
+	app.Add(newSvc("db", false))
+	app.AddWithDeps(newSvc("cache", false), "db")
+	app.AddWithDeps(newSvc("public", true), "cache")
+	app.AddWithDeps(newSvc("admin", true), "db")
+
+	phases, err := app.ShutdownPhases()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"admin", "public"}, {"cache"}, {"db"}}, phases)
+	order, err := app.ShutdownOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "public", "cache", "db"}, order)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-runErr)
+
+	require.Len(t, stopped, 4)
+	assert.ElementsMatch(t, []string{"admin", "public"}, stopped[:2])
+	assert.Equal(t, []string{"cache", "db"}, stopped[2:])
+	// 事件在阶段结束后按阶段内的顺序同步发出
+	assert.Equal(t, order, observed)
+
+	// 默认每个阶段只有一个服务
+	seq := New(WithLogger(&logger))
+	seq.Add(&MockService{name: "a"})
+	seq.Add(&MockService{name: "b"})
+	phases, err = seq.ShutdownPhases()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"b"}, {"a"}}, phases)
+}
@@ -151,13 +151,10 @@ func main() {
 
 	// 3. 初始化基础组件
 
-	// 3.1 证书管理器
-	var certMgr *cert.Manager
-	if cfg.Cert.CertFile != "" || cfg.Cert.ACME.Enabled {
-		certMgr, err = cert.New(cfg.Cert, &log.Logger)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to initialize cert manager")
-		}
+	// 3.1 证书管理器 (由 cert.mode 决定证书来源，mode 为 off 时返回 nil)
+	certMgr, err := cert.New(cfg.Cert, &log.Logger)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize cert manager")
 	}
 
 	// 3.2 安全管理器
@@ -206,7 +203,11 @@ func main() {
 
 		return nil, fmt.Errorf("invalid credentials")
 	}
-	app.Add(appx.NewMonitorService(cfg.Monitor.Addr, app.HealthHandler(), httpx.Auth(httpx.FromHeader("Basic", monitorAuth))))
+	app.Add(appx.NewMonitorServiceWithOptions(cfg.Monitor.Addr, appx.MonitorOptions{
+		HealthHandler:    app.HealthHandler(),
+		ReadinessHandler: app.ReadinessHandler(),
+		LivenessHandler:  app.LivenessHandler(),
+	}, httpx.Auth(httpx.FromHeader("Basic", monitorAuth))))
 
 	// 5.3 Main HTTP Service
 	mux := http.NewServeMux()
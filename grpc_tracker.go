@@ -0,0 +1,97 @@
+package appx
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+)
+
+// RPCTracker 通过拦截器统计进行中的 gRPC 调用数。
+// 由于拦截器只能在创建 grpc.Server 时注入，使用方式为:
+//
+//	tracker := appx.NewRPCTracker()
+//	srv := grpc.NewServer(tracker.ServerOptions()...)
+//	app.Add(appx.NewGrpcService("grpc", ":9000", srv).WithRPCTracker(tracker))
+type RPCTracker struct {
+	// unary 的最高有效位之下是计数，unaryAborted 位表示 abortUnary 已把当时进行中的调用移出统计
+	unary  atomic.Int64
+	stream atomic.Int64
+
+	// abort 被取消时，进行中的 unary 调用的 Context 随之取消 (见 GrpcService.WithDrainWindows)
+	abort       context.Context
+	cancelAbort context.CancelFunc
+}
+
+// unaryAborted 置位后，在此之前开始的 unary 调用已不在计数中，返回时不再递减
+const unaryAborted = int64(1) << 62
+
+func NewRPCTracker() *RPCTracker {
+	abort, cancel := context.WithCancel(context.Background())
+	return &RPCTracker{abort: abort, cancelAbort: cancel}
+}
+
+// ServerOptions 返回注册统计拦截器的 ServerOption，可与其他拦截器链共存
+func (t *RPCTracker) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(t.unaryInterceptor),
+		grpc.ChainStreamInterceptor(t.streamInterceptor),
+	}
+}
+
+// Active 返回当前进行中的 unary 与 streaming 调用数，已被 abortUnary 取消的 unary 调用不再计入
+func (t *RPCTracker) Active() (unary, stream int64) {
+	return t.unary.Load() &^ unaryAborted, t.stream.Load()
+}
+
+// abortUnary 取消所有进行中 unary 调用的 Context 并将它们移出统计，返回被取消的调用数。
+// 被取消的调用即使没有及时返回，之后强制停止时也不会被重复计入。只有第一次调用生效，streaming 调用不受影响。
+func (t *RPCTracker) abortUnary() int64 {
+	var n int64
+	for {
+		v := t.unary.Load()
+		if v&unaryAborted != 0 {
+			return 0
+		}
+		if t.unary.CompareAndSwap(v, unaryAborted) {
+			n = v
+			break
+		}
+	}
+	if t.cancelAbort != nil {
+		t.cancelAbort()
+	}
+	return n
+}
+
+func (t *RPCTracker) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	defer t.unaryDone(t.unary.Add(1)&unaryAborted != 0)
+	if t.abort != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(t.abort, cancel)
+		defer stop()
+	}
+	return handler(ctx, req)
+}
+
+// unaryDone 在 unary 调用返回时递减计数。afterAbort 表示调用在 abortUnary 之后才开始；
+// 之前开始的调用若已被 abortUnary 移出统计则不再递减
+func (t *RPCTracker) unaryDone(afterAbort bool) {
+	for {
+		v := t.unary.Load()
+		if !afterAbort && v&unaryAborted != 0 {
+			return
+		}
+		if t.unary.CompareAndSwap(v, v-1) {
+			return
+		}
+	}
+}
+
+func (t *RPCTracker) streamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	t.stream.Add(1)
+	defer t.stream.Add(-1)
+	return handler(srv, ss)
+}
@@ -0,0 +1,113 @@
+package appx
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// handshakeLimitListener 限制同时进行中的 TLS 握手数量，防止握手洪峰耗尽 CPU 而拖慢已建立的连接。
+// 握手在 Accept 返回之前完成：后台协程从内层 tls.Listener 接收连接，每个连接取得信号量后才开始握手，
+// 握手成功的连接再交给 http.Server (其后续的 Handshake 调用为空操作)。
+// 排队与握手共享 timeout 的时间预算，排队超时的连接被直接关闭，并计入 appx_http_tls_handshake_rejected_total。
+type handshakeLimitListener struct {
+	net.Listener
+	service string
+	timeout time.Duration
+	sem     chan struct{}
+
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newHandshakeLimitListener(ln net.Listener, service string, limit int, timeout time.Duration) *handshakeLimitListener {
+	l := &handshakeLimitListener{
+		Listener: ln,
+		service:  service,
+		timeout:  timeout,
+		sem:      make(chan struct{}, limit),
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+// acceptLoop 持续接收连接并在独立的 goroutine 中握手，Accept 的错误原样转交给上层
+func (l *handshakeLimitListener) acceptLoop() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			// 与 http.Server 一致：临时错误由上层退避，监听器继续工作
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go l.handshake(c)
+	}
+}
+
+func (l *handshakeLimitListener) handshake(c net.Conn) {
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		l.deliver(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		tlsHandshakeRejectedTotal.WithLabelValues(l.service).Inc()
+		c.Close()
+		return
+	case <-l.done:
+		c.Close()
+		return
+	}
+	err := tc.HandshakeContext(ctx)
+	<-l.sem
+	if err != nil {
+		c.Close()
+		return
+	}
+	l.deliver(c)
+}
+
+// deliver 将已完成握手的连接交给 Accept，监听器关闭后直接关闭连接
+func (l *handshakeLimitListener) deliver(c net.Conn) {
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		c.Close()
+	}
+}
+
+func (l *handshakeLimitListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *handshakeLimitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
@@ -0,0 +1,413 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/oy3o/httpx"
+	"golang.org/x/sync/errgroup"
+)
+
+// 健康检查的默认超时
+const (
+	defaultHealthTimeoutTotal    = 3 * time.Second
+	defaultHealthTimeoutPerCheck = 2 * time.Second
+)
+
+// healthEntry 是注册的健康检查器及其分组
+type healthEntry struct {
+	checker HealthChecker
+	groups  []string
+	// timeout 大于 0 时覆盖全局的单检查器超时
+	timeout time.Duration
+}
+
+// kind 返回检查器参与的探针类型
+func (e healthEntry) kind() HealthCheckerKind {
+	if k, ok := e.checker.(KindedHealthChecker); ok {
+		return k.Kind()
+	}
+	return HealthReadiness
+}
+
+// inGroup 判断检查器是否属于 group
+func (e healthEntry) inGroup(group string) bool {
+	for _, g := range e.groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// healthSnapshot 是后台健康检查模式下缓存的最近一次聚合结果
+type healthSnapshot struct {
+	err       error
+	checkedAt time.Time
+}
+
+// healthReport 是后台模式下 /healthz 返回的 JSON 结构
+type healthReport struct {
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+}
+
+// serviceReadiness 将实现了 Readiness 的服务适配为 HealthChecker
+type serviceReadiness struct {
+	name string
+	r    Readiness
+}
+
+func (c serviceReadiness) Name() string { return "service:" + c.name }
+
+func (c serviceReadiness) Check(ctx context.Context) error {
+	if !c.r.Ready(ctx) {
+		return errors.New("service not ready")
+	}
+	return nil
+}
+
+// HealthHandler 返回一个标准的 http.Handler 用于 /healthz
+func (s *Appx) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 按分组查询：只执行该分组的检查 (即使处于后台模式，也实时执行，便于故障排查)
+		entries := s.allHealthEntries()
+		var group string
+		if r.URL.RawQuery != "" { // 避免在无参数的高频探针上解析 Query
+			group = r.URL.Query().Get("group")
+		}
+		if group != "" {
+			entries = s.healthGroup(group)
+			if len(entries) == 0 {
+				httpx.Error(w, r, &httpx.HttpError{
+					HttpCode: http.StatusBadRequest,
+					BizCode:  "Bad Request",
+					Msg:      fmt.Sprintf("unknown health check group: %q", group),
+				})
+				return
+			}
+		} else if s.healthInterval > 0 {
+			// 后台模式：直接返回缓存结果，探针请求不会对依赖产生任何负载
+			s.serveCachedHealth(w)
+			return
+		}
+
+		probe := ProbeHealth
+		if group != "" {
+			probe = "" // 分组查询只用于排查，不计入健康历史
+		}
+		s.serveHealthChecks(w, r, probe, entries)
+	})
+}
+
+// ReadinessHandler 返回就绪探针 (/readyz) 的 http.Handler。
+// 执行类型为 HealthReadiness/HealthBoth 的检查器以及实现了 Readiness 的服务；
+// 关闭流程开始后直接返回不健康，使负载均衡器尽快摘除流量；
+// 设置了 WithMinUptimeBeforeReady 时，启动后的观察窗口内同样返回不健康。
+// 与 /healthz 的后台模式无关，每次探针都实时执行检查。
+func (s *Appx) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.serveShuttingDown(w, r) || s.serveWarmingUp(w, r) {
+			return
+		}
+		entries := s.probeEntries(HealthReadiness)
+		for _, svc := range s.snapshotServices() {
+			if rd, ok := svc.(Readiness); ok {
+				entries = append(entries, healthEntry{checker: serviceReadiness{name: svc.Name(), r: rd}})
+			}
+		}
+		s.serveHealthChecks(w, r, ProbeReadiness, entries)
+	})
+}
+
+// LivenessHandler 返回存活探针 (/livez) 的 http.Handler。
+// 只执行类型为 HealthLiveness/HealthBoth 的检查器 (默认没有，即进程存活即返回 200)，
+// 依赖故障 (如数据库不可用) 不应导致编排系统重启进程；关闭流程开始后返回不健康。
+func (s *Appx) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.serveShuttingDown(w, r) {
+			return
+		}
+		s.serveHealthChecks(w, r, ProbeLiveness, s.probeEntries(HealthLiveness))
+	})
+}
+
+// probeEntries 返回参与指定探针的检查器
+func (s *Appx) probeEntries(probe HealthCheckerKind) []healthEntry {
+	var entries []healthEntry
+	for _, e := range s.healthCheckers {
+		if k := e.kind(); k == probe || k == HealthBoth {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// serveShuttingDown 在关闭流程中返回不健康，返回值表示是否已写入响应
+func (s *Appx) serveShuttingDown(w http.ResponseWriter, r *http.Request) bool {
+	if !s.inShutdown.Load() {
+		return false
+	}
+	httpx.Error(w, r, &httpx.HttpError{
+		HttpCode: s.unhealthyCode,
+		BizCode:  "Service Unavailable",
+		Msg:      "shutting down",
+	})
+	return true
+}
+
+// serveWarmingUp 在所有服务启动后的运行时间未达到 WithMinUptimeBeforeReady 时写入不健康响应，返回是否已处理。
+// 服务尚未全部启动时按完整的窗口计算。Retry-After 为剩余的秒数。
+func (s *Appx) serveWarmingUp(w http.ResponseWriter, r *http.Request) bool {
+	if s.minUptime <= 0 {
+		return false
+	}
+	remaining := s.minUptime
+	if readyAt := s.readyAt.Load(); readyAt != nil {
+		remaining -= time.Since(*readyAt)
+	}
+	if remaining <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+	httpx.Error(w, r, &httpx.HttpError{
+		HttpCode: s.unhealthyCode,
+		BizCode:  "Service Unavailable",
+		Msg:      "warming up",
+	})
+	return true
+}
+
+// serveHealthChecks 实时执行检查器并写入响应。
+// 默认返回纯文本 (便于 curl/k8s)，Accept 为 application/json 时返回每个检查器的状态与耗时。
+// probe 为记录健康历史使用的探针名称，为空时不记录。
+func (s *Appx) serveHealthChecks(w http.ResponseWriter, r *http.Request, probe string, entries []healthEntry) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		s.serveHealthJSON(w, r, probe, entries)
+		return
+	}
+
+	// Performance optimization: Fast-path for the common case where no health checkers are registered.
+	// Avoids context and errgroup allocation overhead on frequent /healthz probes.
+	if len(entries) == 0 && s.healthHistory == nil {
+		w.WriteHeader(s.healthyCode)
+		w.Write([]byte("OK"))
+		return
+	}
+
+	if err := s.checkHealth(r.Context(), probe, entries, nil); err != nil {
+		s.logger.Warn().Err(err).Msg("Health check failed")
+
+		// 返回 503 和具体的错误信息
+		httpx.Error(w, r, &httpx.HttpError{
+			HttpCode: s.unhealthyCode,
+			BizCode:  "Service Unavailable",
+			Msg:      fmt.Sprintf("Health check failed: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(s.healthyCode)
+	w.Write([]byte("OK"))
+}
+
+// checkResult 是 JSON 模式下单个检查器的结果
+type checkResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	TookMs int64  `json:"took_ms"`
+}
+
+// checksReport 是 JSON 模式下实时检查返回的结构
+type checksReport struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+// serveHealthJSON 执行所有检查器并以 JSON 返回每个检查器的结果
+func (s *Appx) serveHealthJSON(w http.ResponseWriter, r *http.Request, probe string, entries []healthEntry) {
+	report := checksReport{Status: "ok", Checks: make([]checkResult, len(entries))}
+	code := s.healthyCode
+	if err := s.checkHealth(r.Context(), probe, entries, report.Checks); err != nil {
+		s.logger.Warn().Err(err).Msg("Health check failed")
+		report.Status = "degraded"
+		code = s.unhealthyCode
+	}
+
+	b, _ := sonic.Marshal(report)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// allHealthEntries 返回注册的检查器以及实现了 Readiness 的服务 (服务不属于任何分组)
+func (s *Appx) allHealthEntries() []healthEntry {
+	entries := s.healthCheckers
+	for _, svc := range s.snapshotServices() {
+		if r, ok := svc.(Readiness); ok {
+			entries = append(entries[:len(entries):len(entries)], healthEntry{checker: serviceReadiness{name: svc.Name(), r: r}})
+		}
+	}
+	return entries
+}
+
+// healthGroup 返回属于 group 的检查器
+func (s *Appx) healthGroup(group string) []healthEntry {
+	var entries []healthEntry
+	for _, e := range s.healthCheckers {
+		if e.inGroup(group) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// runHealthChecks 并发执行给定的健康检查器，返回第一个失败的错误。
+// results 非 nil 时 (长度与 entries 相同) 记录每个检查器的结果，且单个失败不会取消其他检查，
+// 以保证每个检查器都报告真实的状态。
+func (s *Appx) runHealthChecks(ctx context.Context, entries []healthEntry, results []checkResult) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// 1. 创建一个带有超时的上下文，防止整个健康检查请求耗时过长
+	// 使用配置的超时时间
+	ctx, cancel := context.WithTimeout(ctx, s.healthTimeoutTotal)
+	defer cancel()
+
+	// 2. 创建 errgroup
+	g, ctx := errgroup.WithContext(ctx)
+	if s.healthConcurrency > 0 {
+		// 分批执行，避免每次探针同时冲击所有依赖
+		g.SetLimit(s.healthConcurrency)
+	}
+
+	// 3. 遍历所有检查器，并发执行
+	var errs []error
+	if results != nil {
+		errs = make([]error, len(entries))
+	}
+	for i, e := range entries {
+		c := e.checker
+		g.Go(func() error {
+			took, err := s.runHealthCheck(ctx, e)
+			if results == nil {
+				return err
+			}
+			results[i] = checkResult{Name: c.Name(), OK: err == nil, TookMs: took.Milliseconds()}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			errs[i] = err
+			return nil
+		})
+	}
+
+	// 4. 等待结果
+	// errgroup 会返回第一个出现的错误，且一旦有错误，ctx 会被 cancel，
+	// 其他正在进行的检查如果监听了 ctx 也会尽快退出。
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHealthCheck 执行单个检查器，返回 Check 调用本身的耗时 (不含排队等待)
+func (s *Appx) runHealthCheck(ctx context.Context, e healthEntry) (time.Duration, error) {
+	c := e.checker
+	if s.healthSem != nil {
+		// 全局并发上限：排队等待空闲槽位，直到总超时
+		select {
+		case s.healthSem <- struct{}{}:
+			defer func() { <-s.healthSem }()
+		case <-ctx.Done():
+			return 0, fmt.Errorf("[%s] waiting for health check slot: %w", c.Name(), ctx.Err())
+		}
+	}
+
+	timeout := s.healthTimeoutPerCheck
+	if e.timeout > 0 {
+		timeout = e.timeout
+	}
+	checkCtx, checkCancel := context.WithTimeout(ctx, timeout)
+	defer checkCancel()
+
+	start := time.Now()
+	err := c.Check(checkCtx)
+	took := time.Since(start)
+	if err != nil {
+		return took, fmt.Errorf("[%s] %w", c.Name(), err)
+	}
+	return took, nil
+}
+
+// runHealthLoop 在后台按固定周期执行健康检查并缓存结果，直到 ctx 结束
+func (s *Appx) runHealthLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshHealth(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshHealth 执行一次健康检查并更新缓存
+func (s *Appx) refreshHealth(ctx context.Context) {
+	err := s.checkHealth(ctx, ProbeHealth, s.allHealthEntries(), nil)
+	if err != nil && ctx.Err() != nil {
+		// 关闭过程中被取消的检查不代表依赖异常，保留上一次的结果
+		return
+	}
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("Background health check failed")
+	}
+	s.healthCache.Store(&healthSnapshot{err: err, checkedAt: time.Now()})
+}
+
+// serveCachedHealth 以 JSON 形式返回缓存的健康检查结果。
+// 首次检查完成之前返回 503 (status=pending)，避免在依赖未确认时接收流量。
+func (s *Appx) serveCachedHealth(w http.ResponseWriter) {
+	report := healthReport{Status: "ok"}
+	code := s.healthyCode
+
+	snap := s.healthCache.Load()
+	switch {
+	case snap == nil:
+		report.Status = "pending"
+		code = s.unhealthyCode
+	case snap.err != nil:
+		report.Status = "unavailable"
+		report.Error = snap.err.Error()
+		report.LastChecked = snap.checkedAt
+		code = s.unhealthyCode
+	default:
+		report.LastChecked = snap.checkedAt
+	}
+
+	b, _ := sonic.Marshal(report)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write(b)
+}
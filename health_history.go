@@ -0,0 +1,137 @@
+package appx
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// 健康历史中的探针名称 (HealthTransition.Probe)
+const (
+	ProbeHealth    = "healthz"
+	ProbeReadiness = "readyz"
+	ProbeLiveness  = "livez"
+)
+
+// HealthTransition 是一次健康状态的变化，由 Appx.HealthHistory 返回。
+// 探针从健康变为不健康、从不健康恢复，或不健康期间失败的检查器集合发生变化时各记录一条。
+type HealthTransition struct {
+	Probe   string    `json:"probe"` // healthz | readyz | livez
+	At      time.Time `json:"at"`
+	Healthy bool      `json:"healthy"`
+	// Failed 是失败的检查器名称，Error 是第一个失败的错误
+	Failed []string `json:"failed,omitempty"`
+	Error  string   `json:"error,omitempty"`
+	// Duration 是上一个状态持续的时间 (纳秒)，探针第一次的记录为 0。
+	// 恢复健康的记录中即为本次故障的持续时间。
+	Duration time.Duration `json:"duration"`
+}
+
+// probeState 是探针最近一次的状态
+type probeState struct {
+	healthy bool
+	failed  []string
+	since   time.Time
+}
+
+// healthHistory 是固定容量的健康状态变化记录
+type healthHistory struct {
+	mu      sync.Mutex
+	entries *ring[HealthTransition]
+	probes  map[string]*probeState
+}
+
+func newHealthHistory(n int) *healthHistory {
+	return &healthHistory{
+		entries: newRing[HealthTransition](n),
+		probes:  make(map[string]*probeState),
+	}
+}
+
+// record 记录探针的一次检查结果，只有状态或失败的检查器发生变化时才写入缓冲区
+func (h *healthHistory) record(probe string, failed []string, err error) {
+	now := time.Now()
+	healthy := err == nil
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.probes[probe]
+	if ok && st.healthy == healthy && slices.Equal(st.failed, failed) {
+		return
+	}
+	if !ok && healthy {
+		// 第一次检查即健康不算状态变化
+		h.probes[probe] = &probeState{healthy: true, since: now}
+		return
+	}
+
+	t := HealthTransition{Probe: probe, At: now, Healthy: healthy, Failed: failed}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if ok {
+		t.Duration = now.Sub(st.since)
+	}
+	h.entries.push(t)
+	h.probes[probe] = &probeState{healthy: healthy, failed: failed, since: now}
+}
+
+// snapshot 按时间顺序 (从旧到新) 返回缓冲区中的记录
+func (h *healthHistory) snapshot() []HealthTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.entries.items()
+}
+
+// HealthHistory 返回最近的健康状态变化 (从旧到新)，未开启 WithHealthHistory 时返回 nil。
+// 用于排查探针间歇性失败：某一时刻的探针结果无法反映依赖的抖动历史。
+func (s *Appx) HealthHistory() []HealthTransition {
+	if s.healthHistory == nil {
+		return nil
+	}
+	return s.healthHistory.snapshot()
+}
+
+// HealthHistoryHandler 以 JSON 返回 HealthHistory，可挂载到监控服务：
+//
+//	appx.MonitorOptions{ExtraHandlers: map[string]http.Handler{"/debug/health/history": app.HealthHistoryHandler()}}
+func (s *Appx) HealthHistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		history := s.HealthHistory()
+		if history == nil {
+			history = []HealthTransition{}
+		}
+		b, _ := sonic.Marshal(history)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(b)
+	})
+}
+
+// checkHealth 执行检查器；开启了健康历史且 probe 非空时，收集每个检查器的结果并记录状态变化。
+// 记录时单个失败不会取消其他检查，以便得到完整的失败列表。
+func (s *Appx) checkHealth(ctx context.Context, probe string, entries []healthEntry, results []checkResult) error {
+	if s.healthHistory == nil || probe == "" {
+		return s.runHealthChecks(ctx, entries, results)
+	}
+	if results == nil {
+		results = make([]checkResult, len(entries))
+	}
+	err := s.runHealthChecks(ctx, entries, results)
+	if err != nil && ctx.Err() != nil {
+		// 探针请求被取消或应用正在关闭，结果不代表依赖的状态
+		return err
+	}
+	var failed []string
+	for _, r := range results {
+		if !r.OK {
+			failed = append(failed, r.Name)
+		}
+	}
+	s.healthHistory.record(probe, failed, err)
+	return err
+}
@@ -0,0 +1,434 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingHealthChecker 记录被调用的次数
+type countingHealthChecker struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (c *countingHealthChecker) Name() string { return "counter" }
+func (c *countingHealthChecker) Check(ctx context.Context) error {
+	c.calls.Add(1)
+	return c.err
+}
+
+func TestAppx_BackgroundHealth(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("Pending Before First Check", func(t *testing.T) {
+		app := New(WithLogger(&logger), WithBackgroundHealth(time.Hour))
+
+		w := httptest.NewRecorder()
+		app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"pending"`)
+	})
+
+	t.Run("Serves Cached Result", func(t *testing.T) {
+		app := New(WithLogger(&logger), WithBackgroundHealth(time.Hour))
+		checker := &countingHealthChecker{}
+		app.AddHealthChecker(checker)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go app.runHealthLoop(ctx)
+		require.Eventually(t, func() bool { return app.healthCache.Load() != nil }, time.Second, 10*time.Millisecond)
+
+		for range 5 {
+			w := httptest.NewRecorder()
+			app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var report healthReport
+			require.NoError(t, sonic.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, "ok", report.Status)
+			assert.False(t, report.LastChecked.IsZero())
+		}
+		// 探针请求不会触发额外的检查
+		assert.Equal(t, int32(1), checker.calls.Load())
+	})
+
+	t.Run("Cached Failure", func(t *testing.T) {
+		app := New(WithLogger(&logger), WithBackgroundHealth(time.Hour))
+		app.AddHealthChecker(&countingHealthChecker{err: errors.New("db down")})
+		app.refreshHealth(context.Background())
+
+		w := httptest.NewRecorder()
+		app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "db down")
+		assert.Contains(t, w.Body.String(), "last_checked")
+	})
+}
+
+// concurrencyChecker 记录同时执行的最大检查数
+type concurrencyChecker struct {
+	current, peak *atomic.Int32
+}
+
+func (c *concurrencyChecker) Name() string { return "concurrency" }
+func (c *concurrencyChecker) Check(ctx context.Context) error {
+	n := c.current.Add(1)
+	defer c.current.Add(-1)
+	for {
+		p := c.peak.Load()
+		if n <= p || c.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return nil
+}
+
+func TestAppx_HealthConcurrency(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithHealthConcurrency(2))
+
+	var current, peak atomic.Int32
+	for range 6 {
+		app.AddHealthChecker(&concurrencyChecker{current: &current, peak: &peak})
+	}
+
+	w := httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+}
+
+func TestAppx_HealthMaxInFlight(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithHealthMaxInFlight(3))
+
+	var current, peak atomic.Int32
+	for range 4 {
+		app.AddHealthChecker(&concurrencyChecker{current: &current, peak: &peak})
+	}
+
+	// 多个探针同时到达，检查器总并发不超过全局上限
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+			codes[i] = w.Code
+		}()
+	}
+	wg.Wait()
+
+	for _, code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+}
+
+// warmingService 在 warm 被置为 true 之前报告未就绪
+type warmingService struct {
+	MockService
+	warm atomic.Bool
+}
+
+func (s *warmingService) Ready(ctx context.Context) bool { return s.warm.Load() }
+
+func TestAppx_ServiceReadiness(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	svc := &warmingService{MockService: MockService{name: "cache"}}
+	app.Add(svc)
+	app.AddHealthChecker(&countingHealthChecker{})
+
+	w := httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "service:cache")
+
+	svc.warm.Store(true)
+	w = httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// kindChecker 是声明了探针类型的检查器
+type kindChecker struct {
+	name string
+	kind HealthCheckerKind
+	err  error
+}
+
+func (c *kindChecker) Name() string                    { return c.name }
+func (c *kindChecker) Kind() HealthCheckerKind         { return c.kind }
+func (c *kindChecker) Check(ctx context.Context) error { return c.err }
+
+func TestAppx_ReadinessLiveness(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	db := &countingHealthChecker{err: errors.New("db down")}
+	deadlock := &kindChecker{name: "deadlock", kind: HealthLiveness}
+	both := &kindChecker{name: "both", kind: HealthBoth}
+	app.AddHealthChecker(db)
+	app.AddHealthChecker(deadlock)
+	app.AddHealthChecker(both)
+
+	probe := func(h http.Handler) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code
+	}
+
+	// 数据库故障只影响就绪探针，不会触发重启
+	assert.Equal(t, http.StatusServiceUnavailable, probe(app.ReadinessHandler()))
+	assert.Equal(t, http.StatusOK, probe(app.LivenessHandler()))
+	assert.Equal(t, http.StatusServiceUnavailable, probe(app.HealthHandler()))
+
+	db.err = nil
+	assert.Equal(t, http.StatusOK, probe(app.ReadinessHandler()))
+
+	both.err = errors.New("broken")
+	assert.Equal(t, http.StatusServiceUnavailable, probe(app.ReadinessHandler()))
+	assert.Equal(t, http.StatusServiceUnavailable, probe(app.LivenessHandler()))
+	both.err = nil
+
+	// 关闭流程中两种探针都返回不健康
+	app.inShutdown.Store(true)
+	assert.Equal(t, http.StatusServiceUnavailable, probe(app.ReadinessHandler()))
+	assert.Equal(t, http.StatusServiceUnavailable, probe(app.LivenessHandler()))
+}
+
+func TestAppx_MinUptimeBeforeReady(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithMinUptimeBeforeReady(time.Minute))
+
+	w := httptest.NewRecorder()
+	app.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "warming up")
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// 存活探针不受影响
+	w = httptest.NewRecorder()
+	app.LivenessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 观察窗口结束后恢复正常的就绪判断
+	readyAt := time.Now().Add(-time.Minute)
+	app.readyAt.Store(&readyAt)
+	w = httptest.NewRecorder()
+	app.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAppx_MinUptimeStartsAfterStartup(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithMinUptimeBeforeReady(200*time.Millisecond))
+	// 耗时的启动钩子不消耗观察窗口
+	app.AddStartupHook(func(ctx context.Context) error {
+		time.Sleep(300 * time.Millisecond)
+		return nil
+	})
+	app.Add(&MockService{name: "svc"})
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	probe := func() int {
+		w := httptest.NewRecorder()
+		app.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, probe())
+	require.Eventually(t, func() bool { return probe() == http.StatusOK }, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-runErr)
+}
+
+func TestAppx_HealthJSON(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	app.AddHealthChecker(&kindChecker{name: "redis", err: errors.New("connection refused")})
+	app.AddHealthChecker(&kindChecker{name: "db"})
+
+	// 未声明 Accept 时保持原有格式
+	w := httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotContains(t, w.Body.String(), `"checks"`)
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var report checksReport
+	require.NoError(t, sonic.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "degraded", report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "redis", report.Checks[0].Name)
+	assert.False(t, report.Checks[0].OK)
+	assert.Contains(t, report.Checks[0].Error, "connection refused")
+	// 单个失败不会取消其他检查
+	assert.Equal(t, "db", report.Checks[1].Name)
+	assert.True(t, report.Checks[1].OK)
+	assert.Empty(t, report.Checks[1].Error)
+}
+
+func TestAppx_HealthTimeouts(t *testing.T) {
+	logger := zerolog.Nop()
+
+	// 0 回退到默认值，而不是立即超时
+	app := New(WithLogger(&logger), WithHealthCheckTimeout(0, 0))
+	assert.Equal(t, defaultHealthTimeoutTotal, app.healthTimeoutTotal)
+	assert.Equal(t, defaultHealthTimeoutPerCheck, app.healthTimeoutPerCheck)
+
+	app = New(WithLogger(&logger), WithHealthCheckTimeout(time.Second, 20*time.Millisecond))
+	// 单独放宽超时的检查器可以通过，其余检查器仍使用全局的 perCheck
+	app.AddHealthCheckerWithTimeout(&mockHealthChecker{name: "db", delay: 50 * time.Millisecond}, 500*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	app.AddHealthChecker(&mockHealthChecker{name: "cache", delay: 50 * time.Millisecond})
+	w = httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "[cache] context deadline exceeded")
+
+	// 总超时同样可单独设置
+	app = New(WithLogger(&logger), WithHealthTimeout(30*time.Millisecond))
+	app.AddHealthCheckerWithTimeout(&mockHealthChecker{name: "db", delay: 200 * time.Millisecond}, time.Second)
+	w = httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, defaultHealthTimeoutPerCheck, app.healthTimeoutPerCheck)
+}
+
+func TestAppx_HealthGroup(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	app.AddHealthChecker(&mockHealthChecker{name: "postgres"}, "database")
+	app.AddHealthChecker(&mockHealthChecker{name: "redis", err: errors.New("connection refused")}, "cache")
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	// 不指定分组时执行全部检查
+	assert.Equal(t, http.StatusServiceUnavailable, serve("/healthz").Code)
+
+	// 只检查 database 分组
+	assert.Equal(t, http.StatusOK, serve("/healthz?group=database").Code)
+
+	w := serve("/healthz?group=cache")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "redis")
+
+	w = serve("/healthz?group=queue")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown health check group")
+}
+
+func TestAppx_HealthStatusCodes(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithHealthStatusCodes(http.StatusNoContent, http.StatusOK))
+	checker := &countingHealthChecker{}
+	app.AddHealthChecker(checker)
+
+	w := httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// 不健康时返回自定义状态码，响应体中仍包含失败原因
+	checker.err = errors.New("db down")
+	w = httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "db down")
+
+	// 后台模式同样生效
+	app = New(WithLogger(&logger), WithBackgroundHealth(time.Hour), WithHealthStatusCodes(http.StatusOK, http.StatusInternalServerError))
+	w = httptest.NewRecorder()
+	app.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestAppx_HealthHistory(t *testing.T) {
+	logger := zerolog.Nop()
+	assert.Nil(t, New(WithLogger(&logger)).HealthHistory())
+
+	app := New(WithLogger(&logger), WithHealthHistory(3))
+	db := &countingHealthChecker{}
+	app.AddHealthChecker(db)
+	app.AddHealthChecker(&mockHealthChecker{name: "cache"})
+
+	probe := func() int {
+		w := httptest.NewRecorder()
+		app.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code
+	}
+
+	// 首次即健康、以及状态不变的检查都不产生记录
+	assert.Equal(t, http.StatusOK, probe())
+	assert.Equal(t, http.StatusOK, probe())
+	assert.Empty(t, app.HealthHistory())
+
+	db.err = errors.New("connection refused")
+	assert.Equal(t, http.StatusServiceUnavailable, probe())
+	assert.Equal(t, http.StatusServiceUnavailable, probe())
+	db.err = nil
+	assert.Equal(t, http.StatusOK, probe())
+
+	history := app.HealthHistory()
+	require.Len(t, history, 2)
+	assert.Equal(t, ProbeReadiness, history[0].Probe)
+	assert.False(t, history[0].Healthy)
+	assert.Equal(t, []string{"counter"}, history[0].Failed)
+	assert.Contains(t, history[0].Error, "connection refused")
+	assert.True(t, history[1].Healthy)
+	assert.Empty(t, history[1].Failed)
+	assert.Positive(t, history[1].Duration)
+	assert.False(t, history[1].At.Before(history[0].At))
+
+	// 超过容量时丢弃最旧的记录
+	db.err = errors.New("timeout")
+	probe()
+	db.err = nil
+	probe()
+	history = app.HealthHistory()
+	require.Len(t, history, 3)
+	assert.True(t, history[0].Healthy)
+	assert.Equal(t, "[counter] timeout", history[1].Error)
+	assert.True(t, history[2].Healthy)
+
+	w := httptest.NewRecorder()
+	app.HealthHistoryHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/health/history", nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var got []HealthTransition
+	require.NoError(t, sonic.Unmarshal(w.Body.Bytes(), &got))
+	assert.Len(t, got, 3)
+}
@@ -0,0 +1,67 @@
+package appx
+
+import "time"
+
+// LifecyclePhase 是 Appx 生命周期阶段
+type LifecyclePhase string
+
+const (
+	// PhaseStarting Run 开始执行，尚未启动任何服务
+	PhaseStarting LifecyclePhase = "starting"
+	// PhaseSecurityCheck 开始执行安全自检 (仅在设置了 WithSecurityManager 时)
+	PhaseSecurityCheck LifecyclePhase = "security_check"
+	// PhaseServiceStarted 某个服务启动成功，Service 为服务名称
+	PhaseServiceStarted LifecyclePhase = "service_started"
+	// PhaseReady 所有服务启动成功 (与 Ready() 关闭同时)
+	PhaseReady LifecyclePhase = "ready"
+	// PhaseDraining 进入关闭流程，即将停止服务并排空连接
+	PhaseDraining LifecyclePhase = "draining"
+	// PhaseServiceStopped 某个服务已停止 (关闭流程或启动失败回滚)，Service 为服务名称，Err 为 Stop 的返回值
+	PhaseServiceStopped LifecyclePhase = "service_stopped"
+	// PhaseStopped Run 即将返回 (正常关闭或启动失败)
+	PhaseStopped LifecyclePhase = "stopped"
+)
+
+// LifecycleEvent 是一次生命周期状态变化
+type LifecycleEvent struct {
+	Phase LifecyclePhase
+	// Time 为状态变化发生的时刻。事件在独立的 goroutine 中投递，到达顺序不保证，需要排序时以它为准
+	Time time.Time
+	// Service 为服务名称，仅 PhaseServiceStarted/PhaseServiceStopped 时非空
+	Service string
+	// Reason 为进入关闭流程的原因，仅 PhaseDraining 时非空
+	Reason string
+	// Err 为 Run 的返回值 (PhaseStopped) 或服务 Stop 的返回值 (PhaseServiceStopped)
+	Err error
+}
+
+// EventSink 接收生命周期事件，可将其转发到 NATS/Kafka/本地 socket 等外部系统
+type EventSink func(event LifecycleEvent)
+
+// emit 在状态变化处同步构造事件。observer 在当前 goroutine 中同步调用，保证事件顺序；
+// sink 在独立的 goroutine 中调用，避免慢速或阻塞的 sink 拖慢启动与关闭。
+// 两者的 panic 都会被捕获并记录，不影响应用。
+func (s *Appx) emit(event LifecycleEvent) {
+	if s.eventSink == nil && s.observer == nil {
+		return
+	}
+	event.Time = time.Now()
+	if s.observer != nil {
+		s.deliverEvent(s.observer, event)
+	}
+	if s.eventSink != nil {
+		go s.deliverEvent(s.eventSink, event)
+	}
+}
+
+func (s *Appx) deliverEvent(fn func(LifecycleEvent), event LifecycleEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error().
+				Interface("panic", r).
+				Str("phase", string(event.Phase)).
+				Msg("Lifecycle event handler panicked")
+		}
+	}()
+	fn(event)
+}
@@ -0,0 +1,129 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oy3o/appx/security"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectEvents 等待 n 个事件，并按发生时刻排序
+func collectEvents(t *testing.T, events <-chan LifecycleEvent, n int) []LifecycleEvent {
+	t.Helper()
+	var got []LifecycleEvent
+	for range n {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d of %d lifecycle events", len(got), n)
+		}
+	}
+	slices.SortStableFunc(got, func(a, b LifecycleEvent) int { return a.Time.Compare(b.Time) })
+	return got
+}
+
+func TestAppx_EventSink(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		events := make(chan LifecycleEvent, 8)
+		app := New(WithLogger(&logger), WithEventSink(func(ev LifecycleEvent) { events <- ev }))
+		app.Add(&MockService{name: "svc"})
+
+		runErr := make(chan error, 1)
+		go func() { runErr <- app.Run() }()
+		<-app.Ready()
+		require.NoError(t, app.Shutdown(context.Background()))
+		require.NoError(t, <-runErr)
+
+		got := collectEvents(t, events, 6)
+		var phases []LifecyclePhase
+		for _, ev := range got {
+			phases = append(phases, ev.Phase)
+		}
+		assert.Equal(t, []LifecyclePhase{
+			PhaseStarting, PhaseServiceStarted, PhaseReady, PhaseDraining, PhaseServiceStopped, PhaseStopped,
+		}, phases)
+		assert.Equal(t, "Shutdown called", got[3].Reason)
+		assert.NoError(t, got[5].Err)
+	})
+
+	t.Run("StartFailure", func(t *testing.T) {
+		events := make(chan LifecycleEvent, 8)
+		app := New(WithLogger(&logger), WithEventSink(func(ev LifecycleEvent) { events <- ev }))
+		app.Add(&MockService{name: "svc", startFunc: func(context.Context) error { return errors.New("boom") }})
+		require.Error(t, app.Run())
+
+		got := collectEvents(t, events, 2)
+		assert.Equal(t, PhaseStarting, got[0].Phase)
+		assert.Equal(t, PhaseStopped, got[1].Phase)
+		assert.ErrorContains(t, got[1].Err, "boom")
+	})
+
+	t.Run("SlowAndPanickingSink", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+		app := New(WithLogger(&logger), WithEventSink(func(ev LifecycleEvent) {
+			if ev.Phase == PhaseReady {
+				panic("sink failure")
+			}
+			<-block
+		}))
+		app.Add(&MockService{name: "svc"})
+
+		runErr := make(chan error, 1)
+		go func() { runErr <- app.Run() }()
+		<-app.Ready()
+		require.NoError(t, app.Shutdown(context.Background()))
+		assert.NoError(t, <-runErr)
+	})
+}
+
+func TestAppx_LifecycleObserver(t *testing.T) {
+	logger := zerolog.Nop()
+	var events []LifecycleEvent
+	stopErr := errors.New("stop failed")
+	app := New(
+		WithLogger(&logger),
+		WithSecurityManager(security.New(&logger)),
+		WithLifecycleObserver(func(ev LifecycleEvent) { events = append(events, ev) }),
+	)
+	app.Add(&MockService{name: "db"})
+	app.Add(&MockService{name: "api", stopFunc: func(context.Context) error { return stopErr }})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-runErr)
+
+	type step struct {
+		Phase   LifecyclePhase
+		Service string
+	}
+	var steps []step
+	for _, ev := range events {
+		steps = append(steps, step{ev.Phase, ev.Service})
+	}
+	assert.Equal(t, []step{
+		{PhaseStarting, ""},
+		{PhaseSecurityCheck, ""},
+		{PhaseServiceStarted, "db"},
+		{PhaseServiceStarted, "api"},
+		{PhaseReady, ""},
+		{PhaseDraining, ""},
+		{PhaseServiceStopped, "api"},
+		{PhaseServiceStopped, "db"},
+		{PhaseStopped, ""},
+	}, steps)
+	assert.ErrorIs(t, events[6].Err, stopErr)
+	assert.NoError(t, events[7].Err)
+	assert.False(t, events[0].Time.After(events[8].Time))
+}
@@ -0,0 +1,51 @@
+package appx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixScheme 是 Unix Domain Socket 地址的前缀，例如 "unix:///run/monitor.sock"
+const unixScheme = "unix://"
+
+// defaultUnixSocketMode 默认只允许属主访问，避免同一主机上的其他用户读取指标或 pprof
+const defaultUnixSocketMode os.FileMode = 0o600
+
+// unixSocketPath 解析 unix:// 地址，返回套接字文件路径
+func unixSocketPath(addr string) (string, bool) {
+	path, ok := strings.CutPrefix(addr, unixScheme)
+	return path, ok && path != ""
+}
+
+// listenUnix 在 path 上创建 Unix 监听器并设置文件权限。
+// 上次进程异常退出遗留的套接字文件会被清理；如果仍有进程在监听则返回错误。
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen unix %s: file exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen unix %s: address already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
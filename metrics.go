@@ -0,0 +1,105 @@
+package appx
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Appx 内置指标，注册在 Prometheus 默认 Registry 上，由 MonitorService 的 /metrics 暴露
+var (
+	taskQueueDepth = registerCollector(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "appx",
+		Name:      "task_queue_depth",
+		Help:      "Number of tasks waiting in the TaskService priority queues.",
+	}, []string{"service", "priority"}))
+
+	taskShedTotal = registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "appx",
+		Name:      "task_shed_total",
+		Help:      "Number of task submissions rejected by the TaskService.",
+	}, []string{"service", "priority"}))
+
+	shutdownDroppedTotal = registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "appx",
+		Name:      "shutdown_dropped_total",
+		Help:      "Number of connections, tasks and hooks that could not complete within the shutdown timeout.",
+	}, []string{"kind"}))
+
+	servicePanicTotal = registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "appx",
+		Name:      "service_panic_total",
+		Help:      "Number of panics recovered in service goroutines.",
+	}, []string{"service"}))
+
+	serviceRestartTotal = registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "appx",
+		Name:      "service_restart_total",
+		Help:      "Number of supervised service restarts after a failure.",
+	}, []string{"service"}))
+
+	slowClientAbortTotal = registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "appx",
+		Name:      "http_slow_client_abort_total",
+		Help:      "Number of HTTP connections aborted because the client read responses slower than the configured floor.",
+	}, []string{"service"}))
+
+	tlsHandshakeRejectedTotal = registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "appx",
+		Name:      "http_tls_handshake_rejected_total",
+		Help:      "Number of TLS connections closed because they waited too long for a handshake slot.",
+	}, []string{"service"}))
+)
+
+// appx_http_request_duration_seconds 的 buckets 由首个启用该指标的 HttpService 决定，因此延迟到首次使用时创建
+var (
+	requestDurationMu      sync.Mutex
+	requestDuration        *prometheus.HistogramVec
+	requestDurationBuckets []float64
+)
+
+// requestDurationHistogram 返回请求耗时直方图，首次调用时按 buckets 创建并注册。
+// 同一指标在一个进程内只能有一套 buckets，matched 为 false 表示已按其他 buckets 创建。
+func requestDurationHistogram(buckets []float64) (h *prometheus.HistogramVec, matched bool) {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	requestDurationMu.Lock()
+	defer requestDurationMu.Unlock()
+	if requestDuration == nil {
+		requestDurationBuckets = buckets
+		requestDuration = registerCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "appx",
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests handled by HttpService.",
+			Buckets:   buckets,
+		}, []string{"service", "code"}))
+	}
+	return requestDuration, slices.Equal(requestDurationBuckets, buckets)
+}
+
+// 优雅关闭超时被丢弃的对象类型 (appx_shutdown_dropped_total 的 kind 标签)
+const (
+	droppedConnection = "connection"
+	droppedTask       = "task"
+	droppedHook       = "hook"
+	droppedGrpcRPC    = "grpc_rpc"
+)
+
+// registerCollector 注册指标到默认 Registry。
+// 如果同名指标已存在 (如被其他组件提前注册)，复用已存在的实例而不是 panic。
+func registerCollector[T prometheus.Collector](c T) T {
+	if err := prometheus.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,117 @@
+package appx
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/oy3o/httpx"
+)
+
+type clientCertKey struct{}
+
+// ClientCertAuth 返回一个基于 TLS 客户端证书的授权中间件。
+// 证书的合法性由 TLS 层 (HttpService.WithClientCAs) 校验，这里只负责授权：
+// 未提供证书返回 401，allowed 返回 false 时返回 403。
+// 授权通过后，客户端证书会注入到请求 Context 中，可通过 ClientCertFromContext 获取。
+//
+// 示例 - 按 SAN 白名单授权:
+//
+//	auth := appx.ClientCertAuth(func(c *x509.Certificate) bool {
+//	  return slices.Contains(c.DNSNames, "billing.internal")
+//	})
+func ClientCertAuth(allowed func(cert *x509.Certificate) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				httpx.Error(w, r, &httpx.HttpError{
+					HttpCode: http.StatusUnauthorized,
+					BizCode:  "Unauthorized",
+					Msg:      "client certificate required",
+				})
+				return
+			}
+
+			cert := r.TLS.PeerCertificates[0]
+			if !allowed(cert) {
+				httpx.Error(w, r, &httpx.HttpError{
+					HttpCode: http.StatusForbidden,
+					BizCode:  "Forbidden",
+					Msg:      "client certificate not allowed",
+				})
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), clientCertKey{}, cert)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientCertFromContext 返回 ClientCertAuth 注入的客户端证书，不存在时返回 nil
+func ClientCertFromContext(ctx context.Context) *x509.Certificate {
+	cert, _ := ctx.Value(clientCertKey{}).(*x509.Certificate)
+	return cert
+}
+
+// clientAuthType 返回 mTLS 的客户端证书校验方式，未通过 WithClientAuth 指定时要求并校验客户端证书
+func (s *HttpService) clientAuthType() tls.ClientAuthType {
+	if s.clientAuth == tls.NoClientCert {
+		return tls.RequireAndVerifyClientCert
+	}
+	return s.clientAuth
+}
+
+type clientIdentityKey struct{}
+
+// ClientIdentity 是 TLS 层校验通过的客户端证书身份，由开启了 mTLS 的 HttpService 注入请求 Context
+type ClientIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string // 如 SPIFFE ID (spiffe://cluster.local/ns/default/sa/billing)
+	// Certificate 是客户端的叶子证书
+	Certificate *x509.Certificate
+}
+
+// ClientIdentityFromContext 返回校验通过的客户端身份。
+// 未开启 mTLS、客户端未提供证书 (tls.VerifyClientCertIfGiven) 或非 TLS 请求时 ok 为 false。
+//
+// 示例 - 在 Handler 中按服务身份授权:
+//
+//	id, ok := appx.ClientIdentityFromContext(r.Context())
+//	if !ok || !slices.Contains(id.DNSNames, "billing.internal") {
+//	  http.Error(w, "forbidden", http.StatusForbidden)
+//	  return
+//	}
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	id, ok := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	if !ok {
+		return ClientIdentity{}, false
+	}
+	return *id, true
+}
+
+// clientIdentityMiddleware 将校验通过的客户端证书身份注入请求 Context。
+// 只读取 VerifiedChains，未经校验的证书 (PeerCertificates) 不会被当作身份。
+func clientIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		leaf := r.TLS.VerifiedChains[0][0]
+		id := &ClientIdentity{
+			CommonName:     leaf.Subject.CommonName,
+			DNSNames:       leaf.DNSNames,
+			EmailAddresses: leaf.EmailAddresses,
+			Certificate:    leaf,
+		}
+		for _, u := range leaf.URIs {
+			id.URIs = append(id.URIs, u.String())
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIdentityKey{}, id)))
+	})
+}
@@ -0,0 +1,218 @@
+package appx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oy3o/appx/cert"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCertAuth(t *testing.T) {
+	handler := ClientCertAuth(func(c *x509.Certificate) bool {
+		return c.Subject.CommonName == "billing"
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(ClientCertFromContext(r.Context()).Subject.CommonName))
+	}))
+
+	request := func(cn string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		if cn != "" {
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}}},
+			}
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("No Certificate", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, request("").Code)
+	})
+
+	t.Run("Not Allowed", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, request("intruder").Code)
+	})
+
+	t.Run("Allowed", func(t *testing.T) {
+		w := request("billing")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "billing", w.Body.String())
+	})
+}
+
+// newTestCA 生成一个自签名 CA 及由其签发的客户端证书，返回 CA 的 PEM 与客户端证书
+func newTestCA(t *testing.T, name string) ([]byte, tls.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name + "-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestHttpService_ClientCADir(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	oldCA, oldClient := newTestCA(t, "old")
+	newCA, newClient := newTestCA(t, "new")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.pem"), oldCA, 0o644))
+
+	logger := zerolog.Nop()
+	svc := NewHttpService("mtls-dir", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	})).WithTLS(certMgr).WithClientCADir(dir).WithLogger(&logger)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	get := func(clientCert tls.Certificate) (int, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{clientCert},
+		}}}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get("https://" + svc.listener.Addr().String())
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	code, err := get(oldClient)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	_, err = get(newClient)
+	assert.Error(t, err, "client signed by an unknown CA should be rejected")
+
+	// CA 轮换：新 CA 写入目录后无需重启即可生效
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.pem"), newCA, 0o644))
+	require.NoError(t, svc.clientCAPool.Reload())
+	code, err = get(newClient)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestHttpService_ClientAuth(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	trustedCA, trusted := newTestCA(t, "billing")
+	_, untrusted := newTestCA(t, "intruder")
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(trustedCA))
+
+	newSvc := func(authType tls.ClientAuthType) *HttpService {
+		logger := zerolog.Nop()
+		svc := NewHttpService("mtls", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := ClientIdentityFromContext(r.Context())
+			if !ok {
+				w.Write([]byte("anonymous"))
+				return
+			}
+			w.Write([]byte(id.CommonName))
+		})).WithTLS(certMgr).WithClientAuth(pool, authType).WithLogger(&logger)
+		require.NoError(t, svc.Start(context.Background()))
+		t.Cleanup(func() { svc.Stop(context.Background()) })
+		return svc
+	}
+	get := func(svc *HttpService, clientCerts ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			// 无论服务端接受哪些 CA 都发送证书，否则不受信任的证书不会被发送
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if len(clientCerts) == 0 {
+					return &tls.Certificate{}, nil
+				}
+				return &clientCerts[0], nil
+			},
+		}}}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get("https://" + svc.listener.Addr().String())
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	t.Run("RequireAndVerify", func(t *testing.T) {
+		svc := newSvc(tls.RequireAndVerifyClientCert)
+		body, err := get(svc, trusted)
+		require.NoError(t, err)
+		assert.Equal(t, "billing", body)
+
+		_, err = get(svc, untrusted)
+		assert.Error(t, err, "client signed by an untrusted CA should be rejected")
+		_, err = get(svc)
+		assert.Error(t, err, "client without certificate should be rejected")
+	})
+
+	t.Run("VerifyIfGiven", func(t *testing.T) {
+		svc := newSvc(tls.VerifyClientCertIfGiven)
+		body, err := get(svc)
+		require.NoError(t, err)
+		assert.Equal(t, "anonymous", body)
+		body, err = get(svc, trusted)
+		require.NoError(t, err)
+		assert.Equal(t, "billing", body)
+		_, err = get(svc, untrusted)
+		assert.Error(t, err)
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		svc := NewHttpService("mtls", "127.0.0.1:0", nil).WithTLS(certMgr)
+		assert.ErrorContains(t, svc.WithClientAuth(pool, tls.RequireAnyClientCert).Validate(context.Background()), "does not verify")
+		svc = NewHttpService("mtls", "127.0.0.1:0", nil).WithTLS(certMgr)
+		assert.ErrorContains(t, svc.WithClientAuth(nil, tls.RequireAndVerifyClientCert).Validate(context.Background()), "requires a CA pool")
+	})
+}
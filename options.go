@@ -1,8 +1,11 @@
 package appx
 
 import (
+	"context"
+	"os"
 	"time"
 
+	"github.com/oy3o/appx/cert"
 	"github.com/oy3o/appx/security"
 	"github.com/rs/zerolog"
 )
@@ -23,6 +26,70 @@ func WithShutdownTimeout(d time.Duration) Option {
 	}
 }
 
+// WithBaseContext 使用外部 Context 作为应用的根 Context (Context() 及传给 Service.Start 的 ctx 均由它派生)。
+// ctx 被取消时 Run 进入优雅关闭流程，与收到 SIGINT/SIGTERM 的效果相同；信号依然有效，两者任一触发即可。
+// 适合在测试或嵌入更大的程序时以编程方式关闭应用，而无需向整个进程发送信号。
+func WithBaseContext(ctx context.Context) Option {
+	return func(x *Appx) {
+		x.baseCtx = ctx
+	}
+}
+
+// WithEventSink 设置生命周期事件 (starting/ready/draining/stopped) 的接收方。
+// 事件在状态变化处同步生成，sink 在独立的 goroutine 中调用并捕获 panic，不会阻塞启动与关闭流程。
+// 可用于通知服务网格或编排系统，例如在 draining 时告知网格摘除流量。
+func WithEventSink(sink EventSink) Option {
+	return func(x *Appx) {
+		x.eventSink = sink
+	}
+}
+
+// WithSignals 覆盖触发优雅关闭的信号 (默认 SIGINT、SIGTERM)。
+// 不传入任何信号时不再因信号关闭，只能通过 Shutdown、WithBaseContext 或致命错误退出。
+func WithSignals(sigs ...os.Signal) Option {
+	return func(x *Appx) {
+		x.shutdownSignals = append([]os.Signal{}, sigs...)
+	}
+}
+
+// WithReloadSignal 注册收到 sig 时执行的回调，应用不会因此关闭 (如 SIGQUIT 打印 goroutine 堆栈)。
+// 回调在独立的 goroutine 中串行执行，执行期间重复收到的信号会被合并；返回的错误只记录日志。
+// 同一信号重复注册时以最后一次为准，且优先于 WithSignals 与 WithReload 中的同名信号。
+func WithReloadSignal(sig os.Signal, fn func() error) Option {
+	return func(x *Appx) {
+		if x.signalHandlers == nil {
+			x.signalHandlers = make(map[os.Signal]func() error)
+		}
+		x.signalHandlers[sig] = fn
+	}
+}
+
+// WithLifecycleObserver 设置生命周期事件的同步观察者，包括安全自检开始、每个服务的启动/停止、进入关闭与退出。
+// 与 WithEventSink 不同，observer 在 Run 的 goroutine 中按发生顺序同步调用，适合测试断言或就绪门控；
+// 它会阻塞启动与关闭流程，因此必须快速返回，且不能调用 Shutdown 等需要等待 Run 的方法。
+func WithLifecycleObserver(observer func(evt LifecycleEvent)) Option {
+	return func(x *Appx) {
+		x.observer = observer
+	}
+}
+
+// WithCertExpiryCheck 将证书管理器的有效期接入安全自检：每次自检 (启动、配置重载) 都会检查 mgr.CurrentCert()，
+// 剩余有效期少于 warnBefore 时报告 Warn，少于 fatalBefore 时报告 Fatal (传 0 使用默认的 30 天 / 7 天)。
+// 未设置 WithSecurityManager 时会自动创建一个。mgr 为 nil (如 cert.ModeOff) 时忽略。
+// 注意 security.Manager.Replace 会移除该检查器，重载时重建检查器需要自行加回。
+func WithCertExpiryCheck(mgr *cert.Manager, warnBefore, fatalBefore time.Duration) Option {
+	return func(x *Appx) {
+		if mgr == nil {
+			return
+		}
+		x.bridgeCheckers = append(x.bridgeCheckers, &security.CertExpiryChecker{
+			Certificate: mgr.CurrentCert,
+			WarnBefore:  warnBefore,
+			FatalBefore: fatalBefore,
+		})
+	}
+}
+
 // WithSecurityManager 注入安全检查管理器
 func WithSecurityManager(mgr *security.Manager) Option {
 	return func(x *Appx) {
@@ -30,19 +97,227 @@ func WithSecurityManager(mgr *security.Manager) Option {
 	}
 }
 
-// WithConfig 注入配置对象，Appx 启动时会打印脱敏后的配置快照
-func WithConfig(cfg any) Option {
+// WithConfig 注入配置对象，Appx 启动时会打印脱敏后的配置快照。
+// 可多次调用 (或一次传入多个)，多个配置会以类型名作为分段名合并到同一个快照中。
+func WithConfig(cfgs ...any) Option {
+	return func(x *Appx) {
+		for _, cfg := range cfgs {
+			if cfg == nil {
+				continue
+			}
+			x.configs = append(x.configs, configSection{name: configSectionName(cfg, x.configs), value: cfg})
+		}
+	}
+}
+
+// WithNamedConfig 以指定的分段名注入配置对象，例如 WithNamedConfig("feature_flags", flags)
+func WithNamedConfig(name string, cfg any) Option {
+	return func(x *Appx) {
+		x.configs = append(x.configs, configSection{name: name, value: cfg, named: true})
+	}
+}
+
+// WithConfigRedactPaths 按点分路径整体脱敏配置快照中的子树，不论其中的字段名是否包含敏感词。
+// "*" 匹配任意 Map 键或切片下标，例如 "providers.*.credentials"。
+// 注入多个配置时，路径需以分段名开头 (如 "flags.providers.*")。
+func WithConfigRedactPaths(paths ...string) Option {
+	return func(x *Appx) {
+		for _, p := range paths {
+			x.configRedactPaths = append(x.configRedactPaths, parseRedactPath(p))
+		}
+	}
+}
+
+// WithConfigSnapshotDedup 只在配置快照发生变化时打印，减少频繁重启或重载时的日志噪音。
+// 以脱敏后快照的哈希判断是否变化：首次运行或哈希变化时打印完整快照，否则只输出一行 debug 日志 "Config unchanged"。
+// stateFile 用于跨进程持久化上一次的哈希 (如 "/var/lib/myapp/config.sha256")；为空时只在同一进程的多次重载之间比较。
+func WithConfigSnapshotDedup(stateFile string) Option {
 	return func(x *Appx) {
-		x.config = cfg
+		x.configDedup = true
+		x.configStateFile = stateFile
+	}
+}
+
+// WithConfigSnapshotFile 在启动与配置重载时，将脱敏后的配置快照 (与日志中的内容相同) 写入 path，
+// 供支持工具读取当前生效的配置 (如 "/run/myapp/effective-config.json")，无需从日志中检索。
+// 文件以 0600 权限原子替换：脱敏后的快照仍然暴露了配置结构，不应对其他用户可读。
+func WithConfigSnapshotFile(path string) Option {
+	return func(x *Appx) {
+		x.configSnapshotFile = path
+	}
+}
+
+// WithoutRuntimeInfo 关闭启动时的运行时参数日志 (GOMAXPROCS、GOGC、GOMEMLIMIT 等，默认开启)
+func WithoutRuntimeInfo() Option {
+	return func(x *Appx) {
+		x.hideRuntimeInfo = true
+	}
+}
+
+// WithDryRun 开启演练模式：Run 只打印配置快照、执行安全自检，并对实现了 Validator 的服务调用 Validate，
+// 随后执行清理钩子并返回，不会启动任何服务。可在 CI 中快速验证"该配置下应用能够正常启动"。
+func WithDryRun() Option {
+	return func(x *Appx) {
+		x.dryRun = true
+	}
+}
+
+// WithHealthStatusCodes 设置 /healthz 健康与不健康时返回的状态码 (默认 200/503)，
+// 用于适配不同负载均衡器的约定，例如只检查响应体的代理可设为 (200, 200)，响应体中仍会包含失败原因。
+func WithHealthStatusCodes(healthy, unhealthy int) Option {
+	return func(x *Appx) {
+		x.healthyCode = healthy
+		x.unhealthyCode = unhealthy
 	}
 }
 
 // WithHealthCheckTimeout 设置健康检查的超时时间。
 // total: 整个健康检查接口的总超时。
-// perCheck: 单个检查器的超时时间。
+// perCheck: 单个检查器的超时时间 (可通过 AddHealthCheckerWithTimeout 为单个检查器覆盖)。
+// 传入 0 表示使用默认值 (total 3s, perCheck 2s)。
 func WithHealthCheckTimeout(total, perCheck time.Duration) Option {
 	return func(x *Appx) {
-		x.healthTimeoutTotal = total
-		x.healthTimeoutPerCheck = perCheck
+		x.healthTimeoutTotal = orDefault(total, defaultHealthTimeoutTotal)
+		x.healthTimeoutPerCheck = orDefault(perCheck, defaultHealthTimeoutPerCheck)
+	}
+}
+
+// WithHealthTimeout 只设置整个健康检查接口的总超时，0 表示使用默认值 (3s)
+func WithHealthTimeout(total time.Duration) Option {
+	return func(x *Appx) {
+		x.healthTimeoutTotal = orDefault(total, defaultHealthTimeoutTotal)
+	}
+}
+
+// orDefault 在 d <= 0 时返回 def
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// WithHealthConcurrency 限制健康检查时同时执行的检查器数量。
+// 检查器较多时可避免每次探针瞬间创建大量 goroutine 并同时冲击所有依赖。
+// 默认 (n <= 0) 不限制。
+func WithHealthConcurrency(n int) Option {
+	return func(x *Appx) {
+		x.healthConcurrency = n
+	}
+}
+
+// WithMinUptimeBeforeReady 使 /readyz 在 Run 启动完所有服务后的 d 时间内始终返回不健康，之后恢复正常的就绪判断。
+// 启动钩子、配置校验与服务启动的耗时不计入该窗口。
+// 给内存膨胀、慢初始化等延迟出现的故障留出暴露时间，避免短暂通过就绪检查的崩溃循环
+// 被编排系统判定为发布成功。/livez 不受影响。
+func WithMinUptimeBeforeReady(d time.Duration) Option {
+	return func(x *Appx) {
+		x.minUptime = d
+	}
+}
+
+// WithHealthMaxInFlight 限制全应用范围内同时执行的检查器总数 (跨所有并发的探针请求与后台检查)。
+// 与 WithHealthConcurrency (单次探针内的并发) 不同，它防止大量负载均衡器高频探测时
+// 健康检查本身压垮依赖。超出限制的检查会排队等待，等待时间计入健康检查的总超时。
+// 默认 (n <= 0) 不限制。
+func WithHealthMaxInFlight(n int) Option {
+	return func(x *Appx) {
+		if n > 0 {
+			x.healthSem = make(chan struct{}, n)
+		} else {
+			x.healthSem = nil
+		}
+	}
+}
+
+// WithBackgroundHealth 开启后台健康检查模式。
+// Run 启动后每隔 interval 执行一次所有检查器，HealthHandler 只返回最近一次的缓存结果
+// (JSON 格式，包含 last_checked 时间戳)，探针频率与依赖负载彻底解耦。
+func WithBackgroundHealth(interval time.Duration) Option {
+	return func(x *Appx) {
+		x.healthInterval = interval
+	}
+}
+
+// WithHealthHistory 记录最近 n 次健康状态变化 (时间、失败的检查器、上一状态的持续时间)，
+// 通过 Appx.HealthHistory 或 HealthHistoryHandler 查询，用于排查就绪探针的间歇性抖动。
+// /healthz、/readyz、/livez 与后台健康检查的结果分别按探针记录，超过 n 条时丢弃最旧的记录。
+// 开启后探针中单个检查器失败不再取消其他检查，以得到完整的失败列表。默认 (n <= 0) 不记录。
+func WithHealthHistory(n int) Option {
+	return func(x *Appx) {
+		if n > 0 {
+			x.healthHistory = newHealthHistory(n)
+		} else {
+			x.healthHistory = nil
+		}
+	}
+}
+
+// WithReload 开启配置重载：收到 SIGHUP (或调用 Appx.Reload) 时执行 fn 重新加载配置，
+// 随后重新打印配置快照并重新执行安全自检，使安全检查在配置变更 (如轮换密钥、修改监听地址) 后依然生效。
+// 检查器若持有旧配置的副本，可在 fn 中通过 security.Manager.Replace 按新配置重建。
+// 重载后的 Fatal 默认只记录日志而不退出，见 WithReloadFatalHandler。
+func WithReload(fn ReloadFunc) Option {
+	return func(x *Appx) {
+		x.reloadFn = fn
+	}
+}
+
+// WithCertReload 使收到 SIGHUP (或调用 Appx.Reload) 时立即从磁盘重载 mgrs 的证书文件 (见 cert.Manager.Reload)，
+// 轮换证书后无需等待文件轮询。证书在配置重载与安全自检之前重载，WithCertExpiryCheck 因此检查的是新证书。
+// 可以单独使用，不需要同时设置 WithReload；nil (如 cert.ModeOff) 会被忽略。
+func WithCertReload(mgrs ...*cert.Manager) Option {
+	return func(x *Appx) {
+		for _, mgr := range mgrs {
+			if mgr != nil {
+				x.certReloaders = append(x.certReloaders, mgr)
+			}
+		}
+	}
+}
+
+// WithReloadFatalHandler 设置重载后安全自检出现 Fatal 时的处理策略。
+// fn 返回 true 时应用进入优雅关闭流程，Run 返回该错误；返回 false 则继续运行。
+func WithReloadFatalHandler(fn func(err error) bool) Option {
+	return func(x *Appx) {
+		x.onReloadFatal = fn
+	}
+}
+
+// WithRestartPolicy 开启服务监管：实现了 Restartable 的服务报告错误时，Appx 会在退避后先 Stop 再 Start 该服务，
+// 退避时间从 backoff 开始每次翻倍，连续重启超过 maxRestarts 次后才关闭整个应用。
+// 服务在上次重启后稳定运行超过最长退避时间 (backoff << maxRestarts) 时，重启预算会被重置。
+func WithRestartPolicy(maxRestarts int, backoff time.Duration) Option {
+	return func(x *Appx) {
+		x.restartPolicy = &restartPolicy{maxRestarts: maxRestarts, backoff: backoff}
+	}
+}
+
+// WithConcurrentStop 使关闭流程按阶段停止服务：同一阶段内互不依赖的服务并发调用 Stop，阶段之间仍按顺序执行。
+// 阶段由 AddWithDeps 声明的依赖关系决定 (见 ShutdownPhases)，没有依赖方的服务 (如多个入口 HTTP 服务)
+// 在第一阶段同时排空连接，缩短总的关闭时间，便于在编排系统的宽限期内完成关闭。
+// 开启后注册顺序不再约束关闭顺序，需要先后停止的服务必须通过 AddWithDeps 声明依赖。
+func WithConcurrentStop() Option {
+	return func(x *Appx) {
+		x.concurrentStop = true
+	}
+}
+
+// WithGracefulUpgrade 开启平滑升级 (零停机替换二进制)。
+// 收到 SIGUSR2 后，Appx 会以相同参数启动新的可执行文件，并通过 ExtraFiles 传递
+// 所有实现了 Upgradable 的服务监听器。新进程启动全部服务后通过管道通知当前进程，
+// 当前进程随后进入优雅关闭流程排空存量连接；新进程在就绪前退出或超时 (见 WithUpgradeTimeout)
+// 则放弃本次升级，当前进程继续服务。
+// 仅在 Unix 平台生效。
+func WithGracefulUpgrade() Option {
+	return func(x *Appx) {
+		x.gracefulUpgrade = true
+	}
+}
+
+// WithUpgradeTimeout 设置平滑升级时等待新进程就绪的最长时间 (默认 30 秒)，超时后终止新进程并继续服务
+func WithUpgradeTimeout(d time.Duration) Option {
+	return func(x *Appx) {
+		x.upgradeTimeout = d
 	}
 }
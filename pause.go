@@ -0,0 +1,118 @@
+package appx
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// pauseListener 是一个可暂停 Accept 的 Listener 闸门。
+// 暂停期间 Accept 阻塞，新连接留在内核队列中 (队列满后由内核拒绝)；已建立的连接不受影响。
+// Pause 之前已经阻塞在底层 Accept 中的调用拿到的连接同样保留到恢复后再返回，监听器关闭时才断开。
+type pauseListener struct {
+	net.Listener
+
+	mu     sync.Mutex
+	resume chan struct{} // 非 nil 表示处于暂停状态，恢复时关闭
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newPauseListener(ln net.Listener) *pauseListener {
+	return &pauseListener{Listener: ln, closed: make(chan struct{})}
+}
+
+// pause 暂停接收新连接，返回是否发生了状态变化
+func (l *pauseListener) pause() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.resume != nil {
+		return false
+	}
+	l.resume = make(chan struct{})
+	return true
+}
+
+// unpause 恢复接收新连接，返回是否发生了状态变化
+func (l *pauseListener) unpause() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.resume == nil {
+		return false
+	}
+	close(l.resume)
+	l.resume = nil
+	return true
+}
+
+// gate 返回当前的恢复信号，nil 表示未暂停
+func (l *pauseListener) gate() chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.resume
+}
+
+func (l *pauseListener) paused() bool {
+	return l.gate() != nil
+}
+
+func (l *pauseListener) Accept() (net.Conn, error) {
+	if err := l.wait(); err != nil {
+		return nil, err
+	}
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// Pause 之前已经阻塞在 Accept 中的调用可能拿到新连接，保留到恢复后再交给上层
+	if err := l.wait(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// wait 在暂停期间阻塞直到恢复，监听器关闭时返回 net.ErrClosed
+func (l *pauseListener) wait() error {
+	for {
+		resume := l.gate()
+		if resume == nil {
+			return nil
+		}
+		select {
+		case <-resume:
+		case <-l.closed:
+			return net.ErrClosed
+		}
+	}
+}
+
+func (l *pauseListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+// pauseQUICListener 是 QUIC 监听的暂停闸门。
+// QUIC 连接在握手完成后才由 Accept 返回，无法像 TCP 一样留在内核队列中，因此暂停期间新建立的连接被立即关闭，
+// 客户端回退到 TCP (TCP 同样处于暂停状态，连接在内核队列中等待恢复)。
+type pauseQUICListener struct {
+	*quic.EarlyListener
+	paused func() bool
+}
+
+func (l *pauseQUICListener) Accept(ctx context.Context) (*quic.Conn, error) {
+	for {
+		conn, err := l.EarlyListener.Accept(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if l.paused() {
+			conn.CloseWithError(quic.ApplicationErrorCode(http3.ErrCodeNoError), "server paused")
+			continue
+		}
+		return conn, nil
+	}
+}
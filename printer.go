@@ -1,9 +1,17 @@
 package appx
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/bytedance/sonic"
@@ -28,16 +36,65 @@ func printServiceListening(logger *zerolog.Logger, name, protocol, addr string)
 		Msg("Service listening...")
 }
 
-// printConfigSnapshot 打印脱敏后的配置快照
-func printConfigSnapshot(logger *zerolog.Logger, cfg any) {
-	if cfg == nil || logger == nil {
+// runtimeBuildSettings 是启动日志中展示的构建参数 (不包含可能携带敏感信息的 -ldflags)
+var runtimeBuildSettings = []string{"-race", "-trimpath", "-tags", "CGO_ENABLED", "GOAMD64", "GOARM64", "vcs.revision", "vcs.modified"}
+
+// printRuntimeInfo 打印影响性能与内存的运行时参数，便于从日志直接排查 "为什么慢 / 为什么 OOM"
+func printRuntimeInfo(logger *zerolog.Logger) {
+	if logger == nil {
 		return
 	}
 
-	masked := maskSensitiveData(cfg)
+	gogc := os.Getenv("GOGC")
+	if gogc == "" {
+		gogc = "100"
+	}
+	// 传入负数只读取当前值，不会修改内存限制
+	memLimit := debug.SetMemoryLimit(-1)
+
+	event := logger.Info().
+		Str("go_version", runtime.Version()).
+		Str("os_arch", runtime.GOOS+"/"+runtime.GOARCH).
+		Int("num_cpu", runtime.NumCPU()).
+		Int("gomaxprocs", runtime.GOMAXPROCS(0)).
+		Str("gogc", gogc)
+	if memLimit == math.MaxInt64 {
+		event = event.Str("gomemlimit", "off")
+	} else {
+		event = event.Int64("gomemlimit", memLimit)
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		build := zerolog.Dict()
+		for _, setting := range info.Settings {
+			if slices.Contains(runtimeBuildSettings, setting.Key) {
+				build = build.Str(setting.Key, setting.Value)
+			}
+		}
+		event = event.Dict("build", build)
+	}
+
+	event.Msg("Runtime settings")
+}
+
+// configSection 是配置快照中的一个分段
+type configSection struct {
+	name  string
+	value any
+	// named 表示名称由调用方显式指定
+	named bool
+}
+
+// printConfigSnapshot 打印脱敏后的配置快照。
+// 只有一个未命名的配置时保持原有格式；多个配置时按分段名合并为一个快照，各分段独立脱敏。
+// redactPaths 按快照中的路径整体替换子树 (多分段时路径以分段名开头)。
+func printConfigSnapshot(logger *zerolog.Logger, sections []configSection, redactPaths [][]string) {
+	if len(sections) == 0 || logger == nil {
+		return
+	}
 
 	// 格式化为 JSON
-	b, err := sonic.MarshalIndent(masked, "", "  ")
+	b, err := sonic.MarshalIndent(maskConfigSnapshot(sections, redactPaths), "", "  ")
 	if err != nil {
 		logger.Warn().Err(err).Msg("Failed to marshal config snapshot")
 		return
@@ -46,8 +103,175 @@ func printConfigSnapshot(logger *zerolog.Logger, cfg any) {
 	logger.Info().RawJSON("config_snapshot", b).Msg("Effective Configuration")
 }
 
+// logConfigSnapshot 打印配置快照。开启 WithConfigSnapshotDedup 时，
+// 快照与上一次 (本进程的上一次重载或状态文件中记录的上一次启动) 相同则只输出一行 debug 日志。
+func (s *Appx) logConfigSnapshot() {
+	if s.configSnapshotFile != "" && len(s.configs) > 0 {
+		s.writeConfigSnapshotFile()
+	}
+	if !s.configDedup || len(s.configs) == 0 {
+		printConfigSnapshot(s.logger, s.configs, s.configRedactPaths)
+		return
+	}
+
+	hash, err := configSnapshotHash(s.configs, s.configRedactPaths)
+	if err != nil {
+		printConfigSnapshot(s.logger, s.configs, s.configRedactPaths)
+		return
+	}
+
+	prev := s.configHash
+	if prev == "" && s.configStateFile != "" {
+		if b, err := os.ReadFile(s.configStateFile); err == nil {
+			prev = strings.TrimSpace(string(b))
+		}
+	}
+	s.configHash = hash
+	if hash == prev {
+		s.logger.Debug().Str("config_hash", hash).Msg("Config unchanged")
+		return
+	}
+
+	printConfigSnapshot(s.logger, s.configs, s.configRedactPaths)
+	if s.configStateFile != "" {
+		if err := os.WriteFile(s.configStateFile, []byte(hash+"\n"), 0o600); err != nil {
+			s.logger.Warn().Err(err).Str("path", s.configStateFile).Msg("Failed to persist config snapshot hash")
+		}
+	}
+}
+
+// writeConfigSnapshotFile 将脱敏后的配置快照原子写入 configSnapshotFile：
+// 先写入同目录下的临时文件再重命名，读取方不会看到写了一半的内容
+func (s *Appx) writeConfigSnapshotFile() {
+	path := s.configSnapshotFile
+	b, err := sonic.MarshalIndent(maskConfigSnapshot(s.configs, s.configRedactPaths), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(path, append(b, '\n'), 0o600)
+	}
+	if err != nil && s.logger != nil {
+		s.logger.Warn().Err(err).Str("path", path).Msg("Failed to write config snapshot file")
+	}
+}
+
+// writeFileAtomic 以 perm 权限原子替换 path 的内容
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // 重命名成功后为空操作
+
+	// CreateTemp 创建的文件权限为 0600，仍显式设置以确保与 perm 一致
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// maskConfigSnapshot 返回脱敏后的配置快照
+func maskConfigSnapshot(sections []configSection, redactPaths [][]string) any {
+	if len(sections) == 1 && !sections[0].named {
+		return maskValue(sections[0].value, redactPaths, nil)
+	}
+
+	merged := make(map[string]any, len(sections))
+	for _, sec := range sections {
+		path := []string{sec.name}
+		if matchRedactPath(redactPaths, path) {
+			merged[sec.name] = "******"
+			continue
+		}
+		merged[sec.name] = maskValue(sec.value, redactPaths, path)
+	}
+	return merged
+}
+
+// configSnapshotHash 返回脱敏后配置快照的 SHA-256 (map 按 key 排序，保证结果稳定)
+func configSnapshotHash(sections []configSection, redactPaths [][]string) (string, error) {
+	b, err := sonic.ConfigStd.Marshal(maskConfigSnapshot(sections, redactPaths))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// configSectionName 根据配置的类型名生成分段名，与已有分段重名时追加序号
+func configSectionName(cfg any, existing []configSection) string {
+	name := "config"
+	if t := reflect.TypeOf(cfg); t != nil {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Name() != "" {
+			name = t.Name()
+		}
+	}
+
+	candidate := name
+	for i := 2; ; i++ {
+		taken := false
+		for _, sec := range existing {
+			if sec.name == candidate {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+}
+
+// parseRedactPath 将点分路径 (如 "providers.*.credentials") 拆分为路径段
+func parseRedactPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// matchRedactPath 判断 path 是否与任一脱敏路径完全匹配，"*" 匹配任意一段 (Map 的键或切片下标)
+func matchRedactPath(patterns [][]string, path []string) bool {
+next:
+	for _, p := range patterns {
+		if len(p) != len(path) {
+			continue
+		}
+		for i, seg := range p {
+			if seg != "*" && seg != path[i] {
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// childPath 返回 path 追加 key 后的新路径，不修改 path 的底层数组
+func childPath(path []string, key string) []string {
+	return append(path[:len(path):len(path)], key)
+}
+
 // maskSensitiveData 递归遍历结构体或 Map，对敏感字段进行脱敏
 func maskSensitiveData(v any) any {
+	return maskValue(v, nil, nil)
+}
+
+// maskValue 与 maskSensitiveData 相同，额外将匹配 redactPaths 的子树整体替换为 "******"。
+// path 为当前节点在快照中的路径。
+func maskValue(v any, redactPaths [][]string, path []string) any {
 	if v == nil {
 		return nil
 	}
@@ -81,12 +305,13 @@ func maskSensitiveData(v any) any {
 			}
 
 			fieldVal := val.Field(i).Interface()
+			fieldPath := childPath(path, fieldName)
 
 			// 检查是否是敏感字段
-			if isSensitive(fieldName) {
+			if isSensitive(fieldName) || matchRedactPath(redactPaths, fieldPath) {
 				out[fieldName] = "******"
 			} else {
-				out[fieldName] = maskSensitiveData(fieldVal)
+				out[fieldName] = maskValue(fieldVal, redactPaths, fieldPath)
 			}
 		}
 		return out
@@ -96,11 +321,12 @@ func maskSensitiveData(v any) any {
 		for _, k := range val.MapKeys() {
 			keyStr := fmt.Sprint(k.Interface())
 			mapVal := val.MapIndex(k).Interface()
+			keyPath := childPath(path, keyStr)
 
-			if isSensitive(keyStr) {
+			if isSensitive(keyStr) || matchRedactPath(redactPaths, keyPath) {
 				out[keyStr] = "******"
 			} else {
-				out[keyStr] = maskSensitiveData(mapVal)
+				out[keyStr] = maskValue(mapVal, redactPaths, keyPath)
 			}
 		}
 		return out
@@ -108,7 +334,12 @@ func maskSensitiveData(v any) any {
 	case reflect.Slice, reflect.Array:
 		out := make([]any, val.Len())
 		for i := 0; i < val.Len(); i++ {
-			out[i] = maskSensitiveData(val.Index(i).Interface())
+			elemPath := childPath(path, strconv.Itoa(i))
+			if matchRedactPath(redactPaths, elemPath) {
+				out[i] = "******"
+				continue
+			}
+			out[i] = maskValue(val.Index(i).Interface(), redactPaths, elemPath)
 		}
 		return out
 
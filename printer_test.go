@@ -0,0 +1,185 @@
+package appx
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAppConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+}
+
+type testFlagsConfig struct {
+	NewUI    bool   `json:"new_ui"`
+	APIToken string `json:"api_token"`
+}
+
+func TestPrintConfigSnapshot_Sections(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	app := New(
+		WithLogger(&logger),
+		WithConfig(&testAppConfig{Addr: ":8080", Password: "p@ss"}),
+		WithNamedConfig("flags", testFlagsConfig{NewUI: true, APIToken: "tok"}),
+	)
+	printConfigSnapshot(app.logger, app.configs, app.configRedactPaths)
+
+	var entry struct {
+		Snapshot map[string]map[string]any `json:"config_snapshot"`
+	}
+	require.NoError(t, sonic.Unmarshal(buf.Bytes(), &entry))
+
+	// 各分段独立脱敏
+	assert.Equal(t, ":8080", entry.Snapshot["testAppConfig"]["addr"])
+	assert.Equal(t, "******", entry.Snapshot["testAppConfig"]["password"])
+	assert.Equal(t, true, entry.Snapshot["flags"]["new_ui"])
+	assert.Equal(t, "******", entry.Snapshot["flags"]["api_token"])
+}
+
+func TestPrintConfigSnapshot_Single(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	// 单个配置保持原有的扁平格式
+	printConfigSnapshot(&logger, []configSection{{name: "testAppConfig", value: testAppConfig{Addr: ":8080"}}}, nil)
+
+	var entry struct {
+		Snapshot map[string]any `json:"config_snapshot"`
+	}
+	require.NoError(t, sonic.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, ":8080", entry.Snapshot["addr"])
+}
+
+func TestConfigSnapshotDedup(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "config.sha256")
+	cfg := &testAppConfig{Addr: ":8080", Password: "p@ss"}
+	boot := func() string {
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf).Level(zerolog.DebugLevel)
+		app := New(
+			WithLogger(&logger),
+			WithConfig(cfg),
+			WithNamedConfig("flags", testFlagsConfig{NewUI: true}),
+			WithConfigSnapshotDedup(stateFile),
+		)
+		app.logConfigSnapshot()
+		return buf.String()
+	}
+
+	// 首次启动打印完整快照并记录哈希
+	assert.Contains(t, boot(), "config_snapshot")
+	// 配置未变化的重启只输出 debug 日志
+	out := boot()
+	assert.NotContains(t, out, "config_snapshot")
+	assert.Contains(t, out, "Config unchanged")
+	// 脱敏字段的变化不会改变哈希
+	cfg.Password = "rotated"
+	assert.NotContains(t, boot(), "config_snapshot")
+	// 配置漂移时重新打印
+	cfg.Addr = ":9090"
+	assert.Contains(t, boot(), "config_snapshot")
+}
+
+func TestConfigSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "effective-config.json")
+	cfg := &testAppConfig{Addr: ":8080", Password: "p@ss"}
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithConfig(cfg), WithConfigSnapshotFile(path), WithConfigSnapshotDedup(""))
+
+	read := func() map[string]any {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var snapshot map[string]any
+		require.NoError(t, sonic.Unmarshal(b, &snapshot))
+		return snapshot
+	}
+
+	app.logConfigSnapshot()
+	snapshot := read()
+	assert.Equal(t, ":8080", snapshot["addr"])
+	assert.Equal(t, "******", snapshot["password"])
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// 重载时即使快照去重跳过了日志，文件仍然更新，且不会残留临时文件
+	cfg.Addr = ":9090"
+	app.logConfigSnapshot()
+	app.logConfigSnapshot()
+	assert.Equal(t, ":9090", read()["addr"])
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestConfigSectionName(t *testing.T) {
+	var sections []configSection
+	name := configSectionName(&testAppConfig{}, sections)
+	assert.Equal(t, "testAppConfig", name)
+
+	sections = append(sections, configSection{name: name})
+	assert.Equal(t, "testAppConfig_2", configSectionName(testAppConfig{}, sections))
+	assert.Equal(t, "config", configSectionName(map[string]any{}, sections))
+}
+
+type testProvidersConfig struct {
+	Region    string `json:"region"`
+	Providers map[string]struct {
+		Endpoint    string            `json:"endpoint"`
+		Credentials map[string]string `json:"credentials"`
+	} `json:"providers"`
+}
+
+func TestPrintConfigSnapshot_RedactPaths(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+
+	cfg := testProvidersConfig{Region: "eu"}
+	cfg.Providers = map[string]struct {
+		Endpoint    string            `json:"endpoint"`
+		Credentials map[string]string `json:"credentials"`
+	}{
+		"tenant-a": {Endpoint: "a.example.com", Credentials: map[string]string{"client_id": "id-a"}},
+		"tenant-b": {Endpoint: "b.example.com", Credentials: map[string]string{"client_id": "id-b"}},
+	}
+
+	app := New(WithLogger(&logger), WithConfig(cfg), WithConfigRedactPaths("providers.*.credentials"))
+	printConfigSnapshot(app.logger, app.configs, app.configRedactPaths)
+
+	var entry struct {
+		Snapshot struct {
+			Region    string                    `json:"region"`
+			Providers map[string]map[string]any `json:"providers"`
+		} `json:"config_snapshot"`
+	}
+	require.NoError(t, sonic.Unmarshal(buf.Bytes(), &entry))
+
+	// 整个子树被替换，即使其中的字段名 (client_id) 不含敏感词
+	assert.Equal(t, "eu", entry.Snapshot.Region)
+	for _, tenant := range []string{"tenant-a", "tenant-b"} {
+		assert.Equal(t, "******", entry.Snapshot.Providers[tenant]["credentials"])
+		assert.NotEmpty(t, entry.Snapshot.Providers[tenant]["endpoint"])
+	}
+}
+
+func TestPrintRuntimeInfo(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	printRuntimeInfo(&logger)
+
+	var entry map[string]any
+	require.NoError(t, sonic.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "Runtime settings", entry["message"])
+	for _, key := range []string{"go_version", "num_cpu", "gomaxprocs", "gogc", "gomemlimit"} {
+		assert.Contains(t, entry, key)
+	}
+}
@@ -0,0 +1,71 @@
+package appx
+
+import "context"
+
+// ReloadFunc 重新加载配置 (如重新读取配置文件、轮换密钥)。
+// 返回 error 时放弃本次重载，应用继续使用旧配置运行。
+type ReloadFunc func(ctx context.Context) error
+
+// Reload 触发一次配置重载，效果与收到 SIGHUP 相同 (非 Unix 平台只能通过此方法触发)。
+// 未配置 WithReload 或 WithCertReload 时无效；上一次重载尚未处理时重复调用会被合并。并发安全。
+// 重载在独立的 goroutine 中执行，耗时的重载不会阻塞关闭信号的处理。
+func (s *Appx) Reload() {
+	select {
+	case s.reloadChan <- struct{}{}:
+	default:
+	}
+}
+
+// reloadEnabled 判断是否需要响应 SIGHUP 与 Reload
+func (s *Appx) reloadEnabled() bool {
+	return s.reloadFn != nil || len(s.certReloaders) > 0
+}
+
+// runReload 串行执行重载，直到 ctx 结束 (进入关闭流程) 或 onReloadFatal 决定关闭应用，
+// 后者把错误交给 fatal 由 Run 的等待循环进入关闭流程
+func (s *Appx) runReload(ctx context.Context, fatal chan<- error) {
+	for {
+		select {
+		case <-s.reloadChan:
+			if err := s.reload(ctx); err != nil {
+				fatal <- err
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reload 重载证书与配置，随后重新打印配置快照并重新执行安全自检。
+// 证书重载失败只记录日志，不影响配置重载。
+// 返回非 nil 表示自检出现 Fatal 且 onReloadFatal 决定关闭应用。
+func (s *Appx) reload(ctx context.Context) error {
+	for _, mgr := range s.certReloaders {
+		if err := mgr.Reload(); err != nil {
+			s.logger.Error().Err(err).Msg("Certificate reload failed, keep serving previous certificate")
+		}
+	}
+
+	if s.reloadFn != nil {
+		s.logger.Info().Msg("Reloading config...")
+		if err := s.reloadFn(ctx); err != nil {
+			s.logger.Error().Err(err).Msg("Config reload failed, keep running with previous config")
+			return nil
+		}
+	}
+	s.logConfigSnapshot()
+
+	if s.secMgr != nil {
+		// 已在运行中，Fatal 默认只记录日志，由 onReloadFatal 决定是否退出
+		if err := s.secMgr.Run(ctx); err != nil {
+			s.logger.Error().Err(err).Msg("Security check failed after config reload")
+			if s.onReloadFatal != nil && s.onReloadFatal(err) {
+				return err
+			}
+		}
+	}
+
+	s.logger.Info().Msg("Config reloaded")
+	return nil
+}
@@ -0,0 +1,137 @@
+package appx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/oy3o/appx/cert"
+	"github.com/oy3o/appx/security"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppx_ReloadRerunsSecurityChecks(t *testing.T) {
+	logger := zerolog.Nop()
+	checker := &mockChecker{NameVal: "secret", ResultVal: security.Result{Name: "secret", Passed: true}}
+	mgr := security.New(&logger)
+	mgr.Register(checker)
+
+	reloads := make(chan struct{}, 4)
+	var calls int
+	var fatalErrs []error
+	app := New(
+		WithLogger(&logger),
+		WithSecurityManager(mgr),
+		WithReload(func(ctx context.Context) error {
+			defer func() { reloads <- struct{}{} }()
+			calls++
+			if calls == 1 {
+				return errors.New("config file is invalid")
+			}
+			// 新配置引入了弱密钥
+			checker.ResultVal = security.Result{Name: "secret", Passed: false, Severity: security.SeverityFatal, Message: "weak"}
+			return nil
+		}),
+		WithReloadFatalHandler(func(err error) bool {
+			fatalErrs = append(fatalErrs, err)
+			return len(fatalErrs) > 1
+		}),
+	)
+	app.Add(&MockService{name: "svc"})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	// 1. 重载失败：继续使用旧配置，不重新执行自检
+	app.Reload()
+	<-reloads
+	// 2. 新配置出现 Fatal，回调决定继续运行
+	app.Reload()
+	<-reloads
+	select {
+	case err := <-runErr:
+		t.Fatalf("Run returned unexpectedly: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 3. 回调决定关闭应用
+	app.Reload()
+	select {
+	case err := <-runErr:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "security check failed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after fatal reload finding")
+	}
+	assert.Len(t, fatalErrs, 2)
+	res, ok := mgr.LastResult("secret")
+	require.True(t, ok)
+	assert.False(t, res.Passed)
+}
+
+func TestAppx_CertReload(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{Mode: cert.ModeManual, CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+	old := certMgr.CurrentCert()
+
+	// 只配置 WithCertReload 时同样响应 Reload
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithCertReload(certMgr, nil))
+	app.Add(&MockService{name: "svc"})
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	newCert, newKey := generateTempCert(t)
+	for src, dst := range map[string]string{newCert: cPath, newKey: kPath} {
+		b, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, b, 0o600))
+	}
+	app.Reload()
+	require.Eventually(t, func() bool {
+		return !bytes.Equal(old.Certificate[0], certMgr.CurrentCert().Certificate[0])
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, app.Shutdown(context.Background()))
+	require.NoError(t, <-runErr)
+}
+
+func TestAppx_SlowReloadDoesNotBlockShutdown(t *testing.T) {
+	logger := zerolog.Nop()
+	started := make(chan struct{})
+	app := New(
+		WithLogger(&logger),
+		WithReload(func(ctx context.Context) error {
+			close(started)
+			// 模拟耗时的重载，直到进入关闭流程
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	)
+	app.Add(&MockService{name: "svc"})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	app.Reload()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	require.NoError(t, app.Shutdown(ctx))
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run did not return while a reload was in progress")
+	}
+}
@@ -0,0 +1,176 @@
+package appx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/felixge/httpsnoop"
+	"github.com/oy3o/o11y"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// defaultRequestSamples 是 RequestSampler 默认保留的请求数
+	defaultRequestSamples = 100
+	// maxSampledSpans 是每个请求最多保留的子 Span 数
+	maxSampledSpans = 32
+	// maxPendingTraces 是等待请求结束的 trace 数上限，超过时丢弃全部暂存的 Span
+	maxPendingTraces = 1024
+)
+
+// RequestTrace 是一个被采样的慢请求或出错请求，由 RequestSampler.Traces 返回
+type RequestTrace struct {
+	Service  string        `json:"service"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"` // 不含查询参数，避免记录 token 等敏感信息
+	Status   int           `json:"status"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"` // 纳秒
+	TraceID  string        `json:"trace_id,omitempty"`
+	// Error 是 handler panic 的内容，此时 Status 记为 500
+	Error string `json:"error,omitempty"`
+	// Spans 是请求期间结束的子 Span (按结束顺序)，需要将采样器注册为 SpanProcessor
+	Spans []SpanSummary `json:"spans,omitempty"`
+}
+
+// SpanSummary 是请求中一个子 Span 的摘要
+type SpanSummary struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"` // Span 状态为 Error 时的描述
+}
+
+// RequestSampler 在内存中保留最近 N 个慢请求 (耗时不低于阈值) 或出错请求 (5xx/panic)，
+// 用于在没有外部链路追踪后端的环境中就地排查问题：通过 trace_id 可以在日志中找到对应的完整链路。
+// 内存占用固定为 N 条记录，可以被多个 HttpService 共享。
+//
+// RequestSampler 同时实现了 sdktrace.SpanProcessor，注册到 TracerProvider 后会附带请求中的子 Span (如数据库、下游调用)：
+//
+//	otel.GetTracerProvider().(*sdktrace.TracerProvider).RegisterSpanProcessor(sampler)
+type RequestSampler struct {
+	threshold time.Duration
+
+	mu      sync.Mutex
+	traces  *ring[RequestTrace]
+	pending map[trace.TraceID][]SpanSummary // 进行中请求已结束的子 Span
+}
+
+var _ sdktrace.SpanProcessor = (*RequestSampler)(nil)
+
+// NewRequestSampler 创建保留最近 n 个请求的采样器，n <= 0 时使用默认值 100。
+// threshold <= 0 时只采样出错的请求。
+func NewRequestSampler(n int, threshold time.Duration) *RequestSampler {
+	if n <= 0 {
+		n = defaultRequestSamples
+	}
+	return &RequestSampler{
+		threshold: threshold,
+		traces:    newRing[RequestTrace](n),
+		pending:   make(map[trace.TraceID][]SpanSummary),
+	}
+}
+
+// Traces 按时间顺序 (从旧到新) 返回采样到的请求
+func (rs *RequestSampler) Traces() []RequestTrace {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.traces.items()
+}
+
+// Handler 以 JSON 返回 Traces，可挂载到监控服务：
+//
+//	appx.MonitorOptions{ExtraHandlers: map[string]http.Handler{"/debug/requests": sampler.Handler()}}
+func (rs *RequestSampler) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := sonic.Marshal(rs.Traces())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(b)
+	})
+}
+
+func (rs *RequestSampler) sampled(status int, d time.Duration) bool {
+	return status >= http.StatusInternalServerError || (rs.threshold > 0 && d >= rs.threshold)
+}
+
+// finish 取出请求的子 Span，请求被采样时写入缓冲区
+func (rs *RequestSampler) finish(id trace.TraceID, t RequestTrace, sampled bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if spans, ok := rs.pending[id]; ok {
+		t.Spans = spans
+		delete(rs.pending, id)
+	}
+	if sampled {
+		rs.traces.push(t)
+	}
+}
+
+func (rs *RequestSampler) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd 暂存本地子 Span，直到所属的请求结束。
+// 入口 Span (无父 Span 或父 Span 来自远端) 在请求结束之后才结束，不会被取走，因此不暂存。
+func (rs *RequestSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p := s.Parent(); !p.IsValid() || p.IsRemote() {
+		return
+	}
+	span := SpanSummary{Name: s.Name(), Start: s.StartTime(), Duration: s.EndTime().Sub(s.StartTime())}
+	if st := s.Status(); st.Code == codes.Error {
+		span.Error = st.Description
+	}
+
+	id := s.SpanContext().TraceID()
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	spans, ok := rs.pending[id]
+	if !ok && len(rs.pending) >= maxPendingTraces {
+		// 请求结束后才结束的异步 Span 不会被取走，满了直接清空以限制内存
+		clear(rs.pending)
+	}
+	if len(spans) < maxSampledSpans {
+		rs.pending[id] = append(spans, span)
+	}
+}
+
+func (rs *RequestSampler) Shutdown(context.Context) error   { return nil }
+func (rs *RequestSampler) ForceFlush(context.Context) error { return nil }
+
+// middleware 返回采样中间件，位于 o11y 内层以读取 trace_id。
+// handler panic 时记录后继续向外抛出，交给 recovery 处理。
+func (rs *RequestSampler) middleware(service string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := trace.SpanContextFromContext(r.Context()).TraceID()
+		newTrace := func(status int, d time.Duration) RequestTrace {
+			return RequestTrace{
+				Service:  service,
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Status:   status,
+				Start:    start,
+				Duration: d,
+				TraceID:  o11y.GetTraceID(r.Context()),
+			}
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				if p != http.ErrAbortHandler {
+					t := newTrace(http.StatusInternalServerError, time.Since(start))
+					t.Error = fmt.Sprint(p)
+					rs.finish(id, t, true)
+				}
+				panic(p)
+			}
+		}()
+
+		m := httpsnoop.CaptureMetrics(next, w, r)
+		rs.finish(id, newTrace(m.Code, m.Duration), rs.sampled(m.Code, m.Duration))
+	})
+}
@@ -0,0 +1,28 @@
+package appx
+
+// ring 是固定容量的环形缓冲区，写满后覆盖最旧的元素。非并发安全，由调用方加锁
+type ring[T any] struct {
+	buf  []T
+	next int // 下一个元素写入的位置
+	full bool
+}
+
+func newRing[T any](n int) *ring[T] {
+	return &ring[T]{buf: make([]T, n)}
+}
+
+func (r *ring[T]) push(v T) {
+	r.buf[r.next] = v
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// items 按写入顺序 (从旧到新) 返回缓冲区中元素的副本，为空时返回非 nil 的空切片
+func (r *ring[T]) items() []T {
+	if !r.full {
+		return append([]T{}, r.buf[:r.next]...)
+	}
+	return append(append([]T{}, r.buf[r.next:]...), r.buf[:r.next]...)
+}
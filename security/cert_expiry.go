@@ -0,0 +1,74 @@
+package security
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// 证书有效期检查的默认阈值
+const (
+	defaultCertWarnBefore  = 30 * 24 * time.Hour
+	defaultCertFatalBefore = 7 * 24 * time.Hour
+)
+
+// CertExpiryChecker 检查当前对外提供的证书剩余有效期，严重级别随到期临近逐级升高：
+// 剩余时间充足时通过 (Info)，少于 WarnBefore 时报告 Warn，少于 FatalBefore 或已过期时报告 Fatal。
+// 配合定期重新执行检查，可以把证书健康状况统一纳入安全自检结果，而不需要单独的端点。
+type CertExpiryChecker struct {
+	// NameID 用于区分多个证书来源，为空时检查器名称为 "cert_expiry"
+	NameID string
+	// Certificate 返回当前使用的证书，返回 nil 表示证书不由本地管理 (如 ACME 自动续期)，检查直接通过
+	Certificate func() *tls.Certificate
+	// WarnBefore 默认 30 天，FatalBefore 默认 7 天
+	WarnBefore  time.Duration
+	FatalBefore time.Duration
+}
+
+func (c *CertExpiryChecker) Name() string {
+	if c.NameID != "" {
+		return "cert_expiry:" + c.NameID
+	}
+	return "cert_expiry"
+}
+
+func (c *CertExpiryChecker) Check(ctx context.Context) Result {
+	cert := c.Certificate()
+	if cert == nil || len(cert.Certificate) == 0 {
+		return Result{Name: c.Name(), Passed: true, Message: "No locally managed certificate in use"}
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return Result{
+				Name:     c.Name(),
+				Passed:   false,
+				Severity: SeverityWarn,
+				Message:  "Failed to parse certificate",
+				Error:    err,
+			}
+		}
+	}
+
+	warnBefore, fatalBefore := c.WarnBefore, c.FatalBefore
+	if warnBefore <= 0 {
+		warnBefore = defaultCertWarnBefore
+	}
+	if fatalBefore <= 0 {
+		fatalBefore = defaultCertFatalBefore
+	}
+
+	left := time.Until(leaf.NotAfter)
+	msg := fmt.Sprintf("Certificate for %q expires at %s (in %s)", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339), left.Round(time.Hour))
+	switch {
+	case left < fatalBefore:
+		return Result{Name: c.Name(), Passed: false, Severity: SeverityFatal, Message: msg}
+	case left < warnBefore:
+		return Result{Name: c.Name(), Passed: false, Severity: SeverityWarn, Message: msg}
+	default:
+		return Result{Name: c.Name(), Passed: true, Severity: SeverityInfo, Message: msg}
+	}
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newExpiringCert 生成一张在 validFor 之后过期的证书
+func newExpiringCert(t *testing.T, validFor time.Duration) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCertExpiryChecker(t *testing.T) {
+	ctx := context.Background()
+	day := 24 * time.Hour
+	check := func(cert *tls.Certificate) Result {
+		c := &CertExpiryChecker{Certificate: func() *tls.Certificate { return cert }, WarnBefore: 30 * day, FatalBefore: 7 * day}
+		return c.Check(ctx)
+	}
+
+	res := check(newExpiringCert(t, 90*day))
+	assert.True(t, res.Passed, res.Message)
+	assert.Equal(t, SeverityInfo, res.Severity)
+	assert.Equal(t, "cert_expiry", res.Name)
+
+	res = check(newExpiringCert(t, 20*day))
+	assert.False(t, res.Passed)
+	assert.Equal(t, SeverityWarn, res.Severity)
+
+	res = check(newExpiringCert(t, 3*day))
+	assert.False(t, res.Passed)
+	assert.Equal(t, SeverityFatal, res.Severity)
+
+	res = check(newExpiringCert(t, -time.Hour))
+	assert.False(t, res.Passed)
+	assert.Equal(t, SeverityFatal, res.Severity, "expired certificates are fatal")
+
+	res = check(nil)
+	assert.True(t, res.Passed, "no locally managed certificate")
+}
@@ -0,0 +1,113 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// clockBase 是进程内单调时钟的参考点
+var clockBase = time.Now()
+
+// readClock 同时读取墙上时钟与单调时钟 (相对 clockBase)
+func readClock() (wall time.Time, mono time.Duration) {
+	now := time.Now()
+	return now.Round(0), now.Sub(clockBase)
+}
+
+// ClockMonotonicChecker 检测墙上时钟相对单调时钟的跳变。
+// 虚拟机暂停/恢复或 NTP 步进调整会让墙上时钟突然前跳或回拨，
+// 基于墙上时钟的过期/超时逻辑 (如证书过期判断) 会因此出错。
+//
+// Check 在一个采样窗口内对比两种时钟的流逝时间；
+// Watch 则在后台持续监测，发现跳变时记录日志。
+type ClockMonotonicChecker struct {
+	// Interval 采样间隔，默认 200ms
+	Interval time.Duration
+	// MaxDrift 允许的最大偏差，默认 1s
+	MaxDrift time.Duration
+	Severity Severity
+
+	// read 仅用于测试注入
+	read func() (time.Time, time.Duration)
+}
+
+func (c *ClockMonotonicChecker) Name() string { return "clock_monotonic" }
+
+func (c *ClockMonotonicChecker) Check(ctx context.Context) Result {
+	interval := c.interval()
+	wall0, mono0 := c.readClock()
+
+	select {
+	case <-ctx.Done():
+		return Result{Name: c.Name(), Passed: true, Message: "Skipped: context done"}
+	case <-time.After(interval):
+	}
+
+	wall1, mono1 := c.readClock()
+	drift := c.drift(wall0, mono0, wall1, mono1)
+	if drift > c.maxDrift() || drift < -c.maxDrift() {
+		return Result{
+			Name:     c.Name(),
+			Passed:   false,
+			Severity: c.Severity,
+			Message:  fmt.Sprintf("Wall clock drifted %s from monotonic clock within %s", drift, interval),
+		}
+	}
+	return Result{Name: c.Name(), Passed: true}
+}
+
+// Watch 在后台持续监测时钟跳变，直到 ctx 结束。
+// 墙上时钟回拨以 Error 级别记录 (最容易导致超时与过期逻辑出错)，前跳以 Warn 级别记录。
+func (c *ClockMonotonicChecker) Watch(ctx context.Context, logger *zerolog.Logger) {
+	ticker := time.NewTicker(c.interval())
+	defer ticker.Stop()
+
+	wall0, mono0 := c.readClock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		wall1, mono1 := c.readClock()
+		drift := c.drift(wall0, mono0, wall1, mono1)
+		wall0, mono0 = wall1, mono1
+
+		switch {
+		case drift < -c.maxDrift():
+			logger.Error().Dur("drift", drift).Time("wall", wall1).Msg("Wall clock jumped backwards")
+		case drift > c.maxDrift():
+			logger.Warn().Dur("drift", drift).Time("wall", wall1).Msg("Wall clock jumped forwards")
+		}
+	}
+}
+
+// drift 返回两次采样之间墙上时钟流逝时间与单调时钟流逝时间的差值
+func (c *ClockMonotonicChecker) drift(wall0 time.Time, mono0 time.Duration, wall1 time.Time, mono1 time.Duration) time.Duration {
+	return wall1.Sub(wall0) - (mono1 - mono0)
+}
+
+func (c *ClockMonotonicChecker) readClock() (time.Time, time.Duration) {
+	if c.read != nil {
+		return c.read()
+	}
+	return readClock()
+}
+
+func (c *ClockMonotonicChecker) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return 200 * time.Millisecond
+}
+
+func (c *ClockMonotonicChecker) maxDrift() time.Duration {
+	if c.MaxDrift > 0 {
+		return c.MaxDrift
+	}
+	return time.Second
+}
@@ -0,0 +1,54 @@
+package security
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock 模拟墙上时钟与单调时钟，可手动制造跳变
+type fakeClock struct {
+	mu   sync.Mutex
+	wall time.Time
+	mono time.Duration
+}
+
+func (f *fakeClock) read() (time.Time, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.wall, f.mono
+}
+
+func (f *fakeClock) advance(wall, mono time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.wall = f.wall.Add(wall)
+	f.mono += mono
+}
+
+func TestClockMonotonicChecker(t *testing.T) {
+	t.Run("Should pass on real clock", func(t *testing.T) {
+		c := &ClockMonotonicChecker{Interval: 10 * time.Millisecond}
+		res := c.Check(context.Background())
+		assert.True(t, res.Passed)
+	})
+
+	t.Run("Should fail on backward jump", func(t *testing.T) {
+		clk := &fakeClock{wall: time.Now()}
+		c := &ClockMonotonicChecker{Interval: 10 * time.Millisecond, Severity: SeverityWarn, read: clk.read}
+
+		go func() {
+			time.Sleep(2 * time.Millisecond)
+			// 单调时钟前进 10ms，墙上时钟回拨 1 小时
+			clk.advance(-time.Hour, 10*time.Millisecond)
+		}()
+
+		res := c.Check(context.Background())
+		assert.False(t, res.Passed)
+		assert.Equal(t, SeverityWarn, res.Severity)
+		assert.Contains(t, res.Message, "drifted")
+	})
+}
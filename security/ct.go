@@ -0,0 +1,135 @@
+package security
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// oidSCTList 是证书中内嵌 SCT 列表的扩展 OID (RFC 6962 3.3)
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// CTLogChecker 检查对外提供的证书是否携带 SCT (Signed Certificate Timestamp)。
+// 缺少 SCT 的证书在 curl 中可以正常使用，但会被 Chrome 等浏览器拒绝。
+// ACME 签发的证书通常内嵌 SCT，手动安装的证书则未必，因此检查失败时报告为 Warn。
+// 内嵌在证书扩展中的 SCT 与通过 TLS 扩展下发的 SCT (tls.Certificate.SignedCertificateTimestamps) 都会被计入。
+type CTLogChecker struct {
+	// CertFile 为 PEM 格式的证书文件路径，取第一张证书 (叶子证书)
+	CertFile string
+	// Certificate 为已加载的证书，设置后优先于 CertFile
+	Certificate *tls.Certificate
+}
+
+func (c *CTLogChecker) Name() string {
+	if c.Certificate == nil && c.CertFile != "" {
+		return "ct_sct:" + c.CertFile
+	}
+	return "ct_sct"
+}
+
+func (c *CTLogChecker) Check(ctx context.Context) Result {
+	leaf, stapled, err := c.load()
+	if err != nil {
+		return Result{
+			Name:     c.Name(),
+			Passed:   false,
+			Severity: SeverityWarn,
+			Message:  "Failed to load certificate for SCT inspection",
+			Error:    err,
+		}
+	}
+
+	embedded, err := countEmbeddedSCTs(leaf)
+	if err != nil {
+		return Result{
+			Name:     c.Name(),
+			Passed:   false,
+			Severity: SeverityWarn,
+			Message:  "Malformed SCT list extension",
+			Error:    err,
+		}
+	}
+
+	total := embedded + stapled
+	if total == 0 {
+		return Result{
+			Name:     c.Name(),
+			Passed:   false,
+			Severity: SeverityWarn,
+			Message:  fmt.Sprintf("Certificate for %q has no SCTs, browsers enforcing Certificate Transparency will reject it", leaf.Subject.CommonName),
+		}
+	}
+	return Result{
+		Name:    c.Name(),
+		Passed:  true,
+		Message: fmt.Sprintf("Found %d SCT(s) (%d embedded, %d via TLS extension)", total, embedded, stapled),
+	}
+}
+
+// load 返回叶子证书与通过 TLS 扩展下发的 SCT 数量
+func (c *CTLogChecker) load() (*x509.Certificate, int, error) {
+	if c.Certificate != nil {
+		if len(c.Certificate.Certificate) == 0 {
+			return nil, 0, errors.New("empty certificate chain")
+		}
+		leaf := c.Certificate.Leaf
+		if leaf == nil {
+			var err error
+			if leaf, err = x509.ParseCertificate(c.Certificate.Certificate[0]); err != nil {
+				return nil, 0, err
+			}
+		}
+		return leaf, len(c.Certificate.SignedCertificateTimestamps), nil
+	}
+
+	data, err := os.ReadFile(c.CertFile)
+	if err != nil {
+		return nil, 0, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, 0, fmt.Errorf("no PEM certificate found in %s", c.CertFile)
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	return leaf, 0, err
+}
+
+// countEmbeddedSCTs 统计证书扩展中内嵌的 SCT 数量。
+// 扩展值为 OCTET STRING 包裹的 SignedCertificateTimestampList：
+// 2 字节总长度，随后是若干 2 字节长度前缀的 SCT。
+func countEmbeddedSCTs(cert *x509.Certificate) (int, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSCTList) {
+			continue
+		}
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+			return 0, err
+		}
+		if len(list) < 2 || int(binary.BigEndian.Uint16(list)) != len(list)-2 {
+			return 0, errors.New("invalid SCT list length")
+		}
+		list = list[2:]
+
+		n := 0
+		for len(list) > 0 {
+			if len(list) < 2 {
+				return 0, errors.New("truncated SCT entry")
+			}
+			size := int(binary.BigEndian.Uint16(list))
+			if size == 0 || len(list) < 2+size {
+				return 0, errors.New("truncated SCT entry")
+			}
+			list = list[2+size:]
+			n++
+		}
+		return n, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,88 @@
+package security
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCert 生成一张自签名证书，scts 为内嵌的 SCT 数量 (0 表示不带扩展)
+func newTestCert(t *testing.T, scts int) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if scts > 0 {
+		var list []byte
+		for range scts {
+			sct := []byte{0, 1, 2, 3}
+			list = append(list, 0, byte(len(sct)))
+			list = append(list, sct...)
+		}
+		list = append([]byte{byte(len(list) >> 8), byte(len(list))}, list...)
+		value, err := asn1.Marshal(list)
+		require.NoError(t, err)
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCTLogChecker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Embedded SCTs", func(t *testing.T) {
+		cert := newTestCert(t, 2)
+		res := (&CTLogChecker{Certificate: &cert}).Check(ctx)
+		assert.True(t, res.Passed, res.Message)
+		assert.Contains(t, res.Message, "Found 2 SCT(s)")
+	})
+
+	t.Run("Stapled SCTs", func(t *testing.T) {
+		cert := newTestCert(t, 0)
+		cert.SignedCertificateTimestamps = [][]byte{{1, 2, 3}}
+		res := (&CTLogChecker{Certificate: &cert}).Check(ctx)
+		assert.True(t, res.Passed, res.Message)
+		assert.Contains(t, res.Message, "1 via TLS extension")
+	})
+
+	t.Run("Missing SCTs From File", func(t *testing.T) {
+		cert := newTestCert(t, 0)
+		path := filepath.Join(t.TempDir(), "cert.pem")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+
+		c := &CTLogChecker{CertFile: path}
+		res := c.Check(ctx)
+		assert.False(t, res.Passed)
+		assert.Equal(t, SeverityWarn, res.Severity)
+		assert.Equal(t, "ct_sct:"+path, res.Name)
+	})
+
+	t.Run("Unreadable File", func(t *testing.T) {
+		res := (&CTLogChecker{CertFile: filepath.Join(t.TempDir(), "missing.pem")}).Check(ctx)
+		assert.False(t, res.Passed)
+		assert.Error(t, res.Error)
+	})
+}
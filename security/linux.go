@@ -5,6 +5,7 @@ package security
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -40,6 +41,89 @@ func (c *UlimitChecker) Check(ctx context.Context) Result {
 	return Result{Name: c.Name(), Passed: true}
 }
 
+// defaultFDWarnPercent 是 FDUsageChecker 未设置 WarnPercent 时的告警阈值
+const defaultFDWarnPercent = 80
+
+// FDUsageChecker 检查进程当前打开的文件描述符数量占软上限 (RLIMIT_NOFILE) 的比例。
+// UlimitChecker 只检查上限本身，缓慢的 FD 泄漏只有在运行时才会逼近上限；
+// 配合定期重新执行检查，可以在出现 "accept: too many open files" 之前发现问题。
+type FDUsageChecker struct {
+	// WarnPercent 使用率 (0-100) 超过该值时检查失败，默认 80
+	WarnPercent float64
+	Severity    Severity
+}
+
+func (c *FDUsageChecker) Name() string { return "os_fd_usage" }
+
+func (c *FDUsageChecker) Check(ctx context.Context) Result {
+	var rLimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit); err != nil {
+		return Result{
+			Name: c.Name(), Passed: false, Severity: SeverityWarn,
+			Error: err, Message: "Failed to get RLIMIT_NOFILE",
+		}
+	}
+	used, err := countOpenFDs()
+	if err != nil {
+		return Result{
+			Name: c.Name(), Passed: false, Severity: SeverityWarn,
+			Error: err, Message: "Failed to count open file descriptors",
+		}
+	}
+
+	threshold := c.WarnPercent
+	if threshold <= 0 {
+		threshold = defaultFDWarnPercent
+	}
+	percent := float64(used) / float64(rLimit.Cur) * 100
+	if percent > threshold {
+		return Result{
+			Name:     c.Name(),
+			Passed:   false,
+			Severity: c.Severity,
+			Message:  fmt.Sprintf("Open FDs %d/%d (%.1f%%) exceed %.0f%% of the soft limit, possible FD leak", used, rLimit.Cur, percent, threshold),
+		}
+	}
+	return Result{
+		Name:    c.Name(),
+		Passed:  true,
+		Message: fmt.Sprintf("Open FDs %d/%d (%.1f%%)", used, rLimit.Cur, percent),
+	}
+}
+
+// countOpenFDs 统计 /proc/self/fd 中的条目数，不含读取目录本身占用的 FD
+func countOpenFDs() (int, error) {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	return len(names) - 1, nil
+}
+
+var errSysctlFormat = errors.New("invalid sysctl value format")
+
+// ReadSysctl 读取整数类型的内核参数，例如 ReadSysctl("net.core.somaxconn")
+func ReadSysctl(key string) (int64, error) {
+	// 将点号转换为路径，例如 net.core.somaxconn -> /proc/sys/net/core/somaxconn
+	path := "/proc/sys/" + strings.ReplaceAll(key, ".", "/")
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	val, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", errSysctlFormat, err)
+	}
+	return val, nil
+}
+
 // SysctlChecker 检查内核参数 (/proc/sys)
 type SysctlChecker struct {
 	Key      string // e.g., "net.core.somaxconn"
@@ -50,10 +134,10 @@ type SysctlChecker struct {
 func (c *SysctlChecker) Name() string { return "os_sysctl:" + c.Key }
 
 func (c *SysctlChecker) Check(ctx context.Context) Result {
-	// 将点号转换为路径，例如 net.core.somaxconn -> /proc/sys/net/core/somaxconn
-	path := "/proc/sys/" + strings.ReplaceAll(c.Key, ".", "/")
-
-	content, err := os.ReadFile(path)
+	val, err := ReadSysctl(c.Key)
+	if errors.Is(err, errSysctlFormat) {
+		return Result{Name: c.Name(), Passed: false, Severity: SeverityWarn, Error: err, Message: "Invalid sysctl value format"}
+	}
 	if err != nil {
 		// 在某些容器环境（如无特权容器），/proc/sys 可能不可读
 		// 降级为 Info，不报错
@@ -65,12 +149,6 @@ func (c *SysctlChecker) Check(ctx context.Context) Result {
 		}
 	}
 
-	valStr := strings.TrimSpace(string(content))
-	val, err := strconv.ParseInt(valStr, 10, 64)
-	if err != nil {
-		return Result{Name: c.Name(), Passed: false, Severity: SeverityWarn, Error: err, Message: "Invalid sysctl value format"}
-	}
-
 	if val < c.MinValue {
 		return Result{
 			Name:     c.Name(),
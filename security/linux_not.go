@@ -2,7 +2,10 @@
 
 package security
 
-import "context"
+import (
+	"context"
+	"errors"
+)
 
 // 在非 Linux 系统下，这些检查直接通过（或不做任何事）
 
@@ -16,6 +19,16 @@ func (c *UlimitChecker) Check(ctx context.Context) Result {
 	return Result{Name: c.Name(), Passed: true, Message: "Skipped on non-linux OS"}
 }
 
+type FDUsageChecker struct {
+	WarnPercent float64
+	Severity    Severity
+}
+
+func (c *FDUsageChecker) Name() string { return "os_fd_usage" }
+func (c *FDUsageChecker) Check(ctx context.Context) Result {
+	return Result{Name: c.Name(), Passed: true, Message: "Skipped on non-linux OS"}
+}
+
 type SysctlChecker struct {
 	Key      string
 	MinValue int
@@ -26,3 +39,17 @@ func (c *SysctlChecker) Name() string { return "os_sysctl:" + c.Key }
 func (c *SysctlChecker) Check(ctx context.Context) Result {
 	return Result{Name: c.Name(), Passed: true, Message: "Skipped on non-linux OS"}
 }
+
+type SwapChecker struct {
+	Severity Severity
+}
+
+func (c *SwapChecker) Name() string { return "os_swap" }
+func (c *SwapChecker) Check(ctx context.Context) Result {
+	return Result{Name: c.Name(), Passed: true, Message: "Skipped on non-linux OS"}
+}
+
+// ReadSysctl 在非 Linux 系统下不可用
+func ReadSysctl(key string) (int64, error) {
+	return 0, errors.New("sysctl is not supported on this OS")
+}
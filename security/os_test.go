@@ -22,6 +22,19 @@ func TestUlimitChecker_Smoke(t *testing.T) {
 	}
 }
 
+func TestFDUsageChecker(t *testing.T) {
+	res := (&FDUsageChecker{}).Check(context.Background())
+	assert.Equal(t, "os_fd_usage", res.Name)
+	assert.True(t, res.Passed, res.Message)
+	assert.Contains(t, res.Message, "Open FDs")
+
+	// 阈值极低时，任何进程 (至少有 stdin/stdout/stderr) 都会超过
+	res = (&FDUsageChecker{WarnPercent: 0.0001, Severity: SeverityFatal}).Check(context.Background())
+	assert.False(t, res.Passed)
+	assert.Equal(t, SeverityFatal, res.Severity)
+	assert.Contains(t, res.Message, "possible FD leak")
+}
+
 // 冒烟测试：验证 SysctlChecker
 func TestSysctlChecker_Smoke(t *testing.T) {
 	// 检查一个几乎所有 Linux 都有的参数
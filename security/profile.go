@@ -0,0 +1,35 @@
+package security
+
+import "sort"
+
+// ProductionProfile 返回一组适用于生产环境的检查器，提供开箱即用的加固基线：
+//   - 以 root 运行: Fatal
+//   - 开启 swap: Warn
+//   - 文件描述符上限低于 65535: Warn
+//   - secrets 中的弱密钥: Fatal (复杂度不足为 Warn)
+//
+// secrets 的 key 为密钥的标识 (如 "jwt_secret")，用于日志与结果中的检查器名称，不会输出密钥本身。
+// 用法: mgr.Register(security.ProductionProfile(map[string]string{"jwt": cfg.JWTSecret})...)
+func ProductionProfile(secrets map[string]string) []Checker {
+	checkers := []Checker{
+		&RootUserChecker{Severity: SeverityFatal},
+		&SwapChecker{Severity: SeverityWarn},
+		&UlimitChecker{MinLimit: 65535, Severity: SeverityWarn},
+	}
+
+	// 按名称排序，保证检查器顺序稳定
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checkers = append(checkers, &SecretStrengthChecker{
+			NameID:     name,
+			Secret:     secrets[name],
+			MinLength:  16,
+			MinEntropy: 3.0,
+		})
+	}
+	return checkers
+}
@@ -2,6 +2,7 @@ package security
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"strings"
@@ -36,83 +37,97 @@ func checkComplexity(s string) (bool, bool) {
 	return hasLetter, hasNumberOrSymbol
 }
 
-// SecretStrengthChecker 检查敏感字符串的强度
-type SecretStrengthChecker struct {
-	NameID    string
-	Secret    string
+// 密码强度校验失败的错误类型，可通过 errors.Is 判断具体原因
+var (
+	ErrSecretEmpty      = errors.New("secret is empty")
+	ErrSecretTooShort   = errors.New("secret is too short")
+	ErrSecretWeak       = errors.New("secret uses a common weak value")
+	ErrSecretLowEntropy = errors.New("secret entropy is too low")
+	ErrSecretComplexity = errors.New("secret should contain a mix of letters and numbers/symbols")
+)
+
+// SecretPolicy 定义密码/密钥强度策略
+type SecretPolicy struct {
+	// MinLength 最小长度，默认 8
 	MinLength int
-	// MinEntropy 最小熵值（建议值：3.0 左右）
+	// MinEntropy 最小熵值（建议值：3.0 左右），默认 2.5
 	MinEntropy float64
 }
 
-func (c *SecretStrengthChecker) Name() string { return "secret_strength:" + c.NameID }
-
-func (c *SecretStrengthChecker) Check(ctx context.Context) Result {
-	if c.Secret == "" {
-		return Result{
-			Name: c.Name(), Passed: false, Severity: SeverityFatal,
-			Message: "Secret is empty!",
-		}
+// ValidateSecret 按照 policy 校验 secret 的强度。
+// 既可用于启动自检 (应用密钥)，也可用于运行时校验用户密码 (如注册流程)。
+// 返回的错误包装了 ErrSecretXxx 系列哨兵错误，且不会包含 secret 本身。
+func ValidateSecret(secret string, policy SecretPolicy) error {
+	if secret == "" {
+		return ErrSecretEmpty
 	}
 
 	// 1. 检查长度
-	minLength := c.MinLength
+	minLength := policy.MinLength
 	if minLength == 0 {
 		minLength = 8 // 默认最小长度
 	}
-	if len(c.Secret) < minLength {
-		return Result{
-			Name:     c.Name(),
-			Passed:   false,
-			Severity: SeverityFatal,
-			Message:  fmt.Sprintf("Secret is too short (%d chars), must be at least %d chars.", len(c.Secret), minLength),
-		}
+	if len(secret) < minLength {
+		return fmt.Errorf("%w (%d chars), must be at least %d chars", ErrSecretTooShort, len(secret), minLength)
 	}
 
 	// 2. 检查常见默认值 (扩展黑名单)
 	for _, weak := range WeakList {
-		if strings.EqualFold(c.Secret, weak) {
-			return Result{
-				Name:     c.Name(),
-				Passed:   false,
-				Severity: SeverityFatal,
-				Message:  fmt.Sprintf("Secret uses a common weak value: '%s'", weak),
-			}
+		if strings.EqualFold(secret, weak) {
+			return ErrSecretWeak
 		}
 	}
 
 	// 3. 熵值检查 (Shannon Entropy)
 	// 简单的长度检查不足以防御 "aaaaaaaa" 这种密码
-	entropy := calculateEntropy(c.Secret)
-	minEntropy := c.MinEntropy
+	entropy := calculateEntropy(secret)
+	minEntropy := policy.MinEntropy
 	if minEntropy == 0 {
 		minEntropy = 2.5 // 默认熵值阈值，"12345678" 约为 2.0，随机 8 字符约为 4.0
 	}
-
 	if entropy < minEntropy {
-		return Result{
-			Name:     c.Name(),
-			Passed:   false,
-			Severity: SeverityFatal,
-			Message:  fmt.Sprintf("Secret entropy is too low (%.2f < %.2f). Avoid repeating characters or simple sequences.", entropy, minEntropy),
-		}
+		return fmt.Errorf("%w (%.2f < %.2f), avoid repeating characters or simple sequences", ErrSecretLowEntropy, entropy, minEntropy)
 	}
 
 	// 4. 复杂度检查 (包含数字和字母)
 	// Performance optimization: Using direct string iteration avoids significant CPU overhead on every check compared to regexp execution.
 	// We combine both checks into a single loop to avoid iterating over the string twice.
-	hasLetter, hasNumberOrSymbol := checkComplexity(c.Secret)
-
+	hasLetter, hasNumberOrSymbol := checkComplexity(secret)
 	if !hasLetter || !hasNumberOrSymbol {
-		return Result{
-			Name:     c.Name(),
-			Passed:   false,
-			Severity: SeverityWarn, // 复杂度不足通常作为警告，不阻断启动（除非特别严格）
-			Message:  "Secret should contain a mix of letters and numbers/symbols",
-		}
+		return ErrSecretComplexity
 	}
 
-	return Result{Name: c.Name(), Passed: true}
+	return nil
+}
+
+// SecretStrengthChecker 检查敏感字符串的强度，是 ValidateSecret 的启动自检适配器
+type SecretStrengthChecker struct {
+	NameID    string
+	Secret    string
+	MinLength int
+	// MinEntropy 最小熵值（建议值：3.0 左右）
+	MinEntropy float64
+}
+
+func (c *SecretStrengthChecker) Name() string { return "secret_strength:" + c.NameID }
+
+func (c *SecretStrengthChecker) Check(ctx context.Context) Result {
+	err := ValidateSecret(c.Secret, SecretPolicy{MinLength: c.MinLength, MinEntropy: c.MinEntropy})
+	if err == nil {
+		return Result{Name: c.Name(), Passed: true}
+	}
+
+	severity := SeverityFatal
+	if errors.Is(err, ErrSecretComplexity) {
+		severity = SeverityWarn // 复杂度不足通常作为警告，不阻断启动（除非特别严格）
+	}
+	return Result{
+		Name:     c.Name(),
+		Passed:   false,
+		Severity: severity,
+		Message:  err.Error(),
+		Error:    err,
+	}
 }
 
 // calculateEntropy 计算字符串的香农熵
@@ -34,3 +34,26 @@ func TestSecretStrengthChecker(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSecret(t *testing.T) {
+	policy := SecretPolicy{MinLength: 10}
+
+	assert.NoError(t, ValidateSecret("this_is_a_very_long_and_strong_secret_key_12345", policy))
+	assert.ErrorIs(t, ValidateSecret("", policy), ErrSecretEmpty)
+	assert.ErrorIs(t, ValidateSecret("short", policy), ErrSecretTooShort)
+	assert.ErrorIs(t, ValidateSecret("changeme", SecretPolicy{MinLength: 1}), ErrSecretWeak)
+	assert.ErrorIs(t, ValidateSecret("aaaaaaaaaaaa", policy), ErrSecretLowEntropy)
+	assert.ErrorIs(t, ValidateSecret("abcdefghijklmn", policy), ErrSecretComplexity)
+
+	// 错误信息不应泄露 secret 本身
+	err := ValidateSecret("qwerty", SecretPolicy{MinLength: 1})
+	assert.NotContains(t, err.Error(), "qwerty")
+}
+
+func TestSecretStrengthChecker_Severity(t *testing.T) {
+	c := &SecretStrengthChecker{NameID: "test_secret", Secret: "abcdefghijklmn"}
+	res := c.Check(context.Background())
+	assert.False(t, res.Passed)
+	assert.Equal(t, SeverityWarn, res.Severity)
+	assert.ErrorIs(t, res.Error, ErrSecretComplexity)
+}
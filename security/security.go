@@ -3,6 +3,8 @@ package security
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -31,6 +33,38 @@ func (s Severity) String() string {
 	}
 }
 
+// MarshalJSON 将 Severity 序列化为 "INFO"/"WARN"/"FATAL"，输出可读且不依赖枚举的数值顺序
+func (s Severity) MarshalJSON() ([]byte, error) {
+	if s < SeverityInfo || s > SeverityFatal {
+		return nil, fmt.Errorf("security: invalid severity %d", int(s))
+	}
+	return []byte(strconv.Quote(s.String())), nil
+}
+
+// UnmarshalJSON 解析 "INFO"/"WARN"/"FATAL" (不区分大小写)，兼容旧版本输出的整数值
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	if str, err := strconv.Unquote(string(data)); err == nil {
+		switch strings.ToUpper(str) {
+		case "INFO":
+			*s = SeverityInfo
+		case "WARN":
+			*s = SeverityWarn
+		case "FATAL":
+			*s = SeverityFatal
+		default:
+			return fmt.Errorf("security: unknown severity %q", str)
+		}
+		return nil
+	}
+
+	n, err := strconv.Atoi(string(data))
+	if err != nil || Severity(n) < SeverityInfo || Severity(n) > SeverityFatal {
+		return fmt.Errorf("security: invalid severity %s", data)
+	}
+	*s = Severity(n)
+	return nil
+}
+
 // Result 封装检查结果
 type Result struct {
 	Name     string
@@ -50,6 +84,13 @@ type Checker interface {
 type Manager struct {
 	logger   *zerolog.Logger
 	checkers []Checker
+
+	auditSink   func(Result)
+	auditPassed bool
+
+	// results 保存每个检查器最近一次的结果，key 为 Checker.Name()
+	resultsMu sync.RWMutex
+	results   map[string]Result
 }
 
 func New(logger *zerolog.Logger) *Manager {
@@ -64,6 +105,53 @@ func (m *Manager) Register(c ...Checker) {
 	m.checkers = append(m.checkers, c...)
 }
 
+// Replace 替换所有检查项，用于配置重载后按新配置重建检查器 (如轮换后的密钥)。
+// 不能与 Run 并发调用。
+func (m *Manager) Replace(c ...Checker) {
+	m.checkers = append([]Checker(nil), c...)
+}
+
+// WithAuditSink 设置审计回调，每个失败的检查结果都会传给 sink (例如写入只追加的审计存储)。
+// sink 在 Run 内同步串行调用，保证审计记录在应用因 Fatal 退出之前已经落地。
+func (m *Manager) WithAuditSink(sink func(Result)) *Manager {
+	m.auditSink = sink
+	return m
+}
+
+// WithAuditPassed 让审计回调同时接收通过的检查结果，用于证明部署前确实执行过检查
+func (m *Manager) WithAuditPassed() *Manager {
+	m.auditPassed = true
+	return m
+}
+
+// audit 调用审计回调，调用方需持有 mu 以保证串行
+func (m *Manager) audit(res Result) {
+	if m.auditSink == nil || (res.Passed && !m.auditPassed) {
+		return
+	}
+	m.auditSink(res)
+}
+
+// LastResult 返回指定检查器最近一次 Run 的结果。
+// 可用于根据自检结果调整应用行为，例如 swap 检查失败时关闭内存缓存。
+// 检查器尚未执行过时返回 false。
+func (m *Manager) LastResult(checkerName string) (Result, bool) {
+	m.resultsMu.RLock()
+	defer m.resultsMu.RUnlock()
+	res, ok := m.results[checkerName]
+	return res, ok
+}
+
+// record 保存检查器的结果
+func (m *Manager) record(checkerName string, res Result) {
+	m.resultsMu.Lock()
+	defer m.resultsMu.Unlock()
+	if m.results == nil {
+		m.results = make(map[string]Result)
+	}
+	m.results[checkerName] = res
+}
+
 // Run 执行所有检查。
 // 如果有 SeverityFatal 级别的检查失败，返回 error。
 func (m *Manager) Run(ctx context.Context) error {
@@ -86,16 +174,30 @@ func (m *Manager) Run(ctx context.Context) error {
 				if r := recover(); r != nil {
 					m.logger.Error().Str("checker", c.Name()).Interface("panic", r).Msg("Security checker panicked")
 					// Panic 视为 Fatal 错误
+					res := Result{
+						Name:     c.Name(),
+						Severity: SeverityFatal,
+						Message:  "checker panicked",
+						Error:    fmt.Errorf("panic: %v", r),
+					}
+					m.record(c.Name(), res)
 					mu.Lock()
 					fatalCount++
+					m.audit(res)
 					mu.Unlock()
 				}
 			}()
 
 			res := c.Check(ctx)
+			m.record(c.Name(), res)
 
 			if res.Passed {
 				m.logger.Debug().Str("check", res.Name).Msg("Security check passed")
+				if m.auditPassed {
+					mu.Lock()
+					m.audit(res)
+					mu.Unlock()
+				}
 				return nil
 			}
 
@@ -115,6 +217,7 @@ func (m *Manager) Run(ctx context.Context) error {
 				fatalCount++
 				m.logger.Error().Err(res.Error).Msg(msg)
 			}
+			m.audit(res)
 			return nil
 		})
 	}
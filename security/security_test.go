@@ -5,8 +5,10 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/bytedance/sonic"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // MockChecker 用于测试 Manager 行为的桩
@@ -61,3 +63,99 @@ func TestManager_Run(t *testing.T) {
 		assert.Contains(t, err.Error(), "fatal errors found")
 	})
 }
+
+func TestManager_AuditSink(t *testing.T) {
+	logger := &log.Logger
+	checkers := []Checker{
+		&MockChecker{NameVal: "ok", ResultVal: Result{Name: "ok", Passed: true}},
+		&MockChecker{NameVal: "warn", ResultVal: Result{Name: "warn", Severity: SeverityWarn, Message: "warning"}},
+		&MockChecker{NameVal: "fatal", ResultVal: Result{Name: "fatal", Severity: SeverityFatal, Message: "boom"}},
+	}
+
+	t.Run("Should audit only failures by default", func(t *testing.T) {
+		var audited []string
+		mgr := New(logger).WithAuditSink(func(r Result) { audited = append(audited, r.Name) })
+		mgr.Register(checkers...)
+
+		err := mgr.Run(context.Background())
+		assert.Error(t, err)
+		// Run 返回时审计记录必须已经写入
+		assert.ElementsMatch(t, []string{"warn", "fatal"}, audited)
+	})
+
+	t.Run("Should audit passed checks when enabled", func(t *testing.T) {
+		var audited []string
+		mgr := New(logger).WithAuditSink(func(r Result) { audited = append(audited, r.Name) }).WithAuditPassed()
+		mgr.Register(checkers...)
+
+		_ = mgr.Run(context.Background())
+		assert.ElementsMatch(t, []string{"ok", "warn", "fatal"}, audited)
+	})
+}
+
+func TestManager_LastResult(t *testing.T) {
+	mgr := New(&log.Logger)
+	mgr.Register(
+		&MockChecker{NameVal: "swap", ResultVal: Result{Name: "swap", Severity: SeverityWarn, Message: "swap enabled"}},
+		&MockChecker{NameVal: "ok", ResultVal: Result{Name: "ok", Passed: true}},
+	)
+
+	_, ok := mgr.LastResult("swap")
+	assert.False(t, ok, "no result before Run")
+
+	assert.NoError(t, mgr.Run(context.Background()))
+
+	res, ok := mgr.LastResult("swap")
+	assert.True(t, ok)
+	assert.False(t, res.Passed)
+	assert.Equal(t, "swap enabled", res.Message)
+
+	res, ok = mgr.LastResult("ok")
+	assert.True(t, ok)
+	assert.True(t, res.Passed)
+
+	_, ok = mgr.LastResult("unknown")
+	assert.False(t, ok)
+}
+
+func TestProductionProfile(t *testing.T) {
+	checkers := ProductionProfile(map[string]string{"jwt": "changeme"})
+
+	names := make([]string, 0, len(checkers))
+	for _, c := range checkers {
+		names = append(names, c.Name())
+	}
+	assert.Equal(t, []string{"root_user", "os_swap", "os_ulimit", "secret_strength:jwt"}, names)
+	assert.Equal(t, SeverityFatal, checkers[0].(*RootUserChecker).Severity)
+
+	// 弱密钥在生产基线下阻断启动
+	res := checkers[3].Check(context.Background())
+	assert.False(t, res.Passed)
+	assert.Equal(t, SeverityFatal, res.Severity)
+}
+
+func TestSeverity_JSON(t *testing.T) {
+	type report struct {
+		Severity Severity `json:"severity"`
+	}
+	for _, sev := range []Severity{SeverityInfo, SeverityWarn, SeverityFatal} {
+		data, err := sonic.Marshal(report{Severity: sev})
+		require.NoError(t, err)
+		assert.Equal(t, `{"severity":"`+sev.String()+`"}`, string(data))
+
+		var got report
+		require.NoError(t, sonic.Unmarshal(data, &got))
+		assert.Equal(t, sev, got.Severity)
+	}
+
+	var sev Severity
+	require.NoError(t, sev.UnmarshalJSON([]byte(`"warn"`)))
+	assert.Equal(t, SeverityWarn, sev)
+	require.NoError(t, sev.UnmarshalJSON([]byte(`2`)), "integer values from older versions are accepted")
+	assert.Equal(t, SeverityFatal, sev)
+
+	assert.Error(t, sev.UnmarshalJSON([]byte(`"CRITICAL"`)))
+	assert.Error(t, sev.UnmarshalJSON([]byte(`7`)))
+	_, err := Severity(7).MarshalJSON()
+	assert.Error(t, err)
+}
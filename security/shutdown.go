@@ -0,0 +1,81 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultGracePeriodEnv 是 ShutdownBudgetChecker 默认读取宽限期的环境变量
+const DefaultGracePeriodEnv = "APPX_TERMINATION_GRACE_PERIOD"
+
+// ShutdownBudgetChecker 检查优雅关闭所需的时间是否超出编排系统的宽限期。
+// 例如 Kubernetes 的 terminationGracePeriodSeconds 为 30s，而应用的关闭超时为 60s 时，
+// 进程会在排空连接的中途被 SIGKILL，存量请求被直接中断。
+//
+// 宽限期通常无法在进程内探测，需要通过 GracePeriod 或环境变量 (如在 Pod 模板中与
+// terminationGracePeriodSeconds 一同设置) 提供；两者都未设置时检查直接通过。
+type ShutdownBudgetChecker struct {
+	// GracePeriod 编排系统的宽限期，为 0 时从环境变量 GracePeriodEnv 读取
+	GracePeriod time.Duration
+	// GracePeriodEnv 读取宽限期的环境变量名，默认 DefaultGracePeriodEnv。
+	// 值可以是 time.ParseDuration 格式 (如 "45s")，也可以是整数秒 (与 terminationGracePeriodSeconds 一致)
+	GracePeriodEnv string
+	// ShutdownTimeout 应用的关闭超时 (与 appx.WithShutdownTimeout 一致)
+	ShutdownTimeout time.Duration
+	// PreShutdownDelay 开始关闭前的等待时间 (如等待负载均衡器摘除流量的 preStop sleep)，计入关闭所需的时间
+	PreShutdownDelay time.Duration
+	Severity         Severity
+}
+
+func (c *ShutdownBudgetChecker) Name() string { return "shutdown_budget" }
+
+func (c *ShutdownBudgetChecker) Check(ctx context.Context) Result {
+	grace, source, err := c.gracePeriod()
+	if err != nil {
+		return Result{
+			Name:     c.Name(),
+			Passed:   false,
+			Severity: c.Severity,
+			Message:  fmt.Sprintf("Invalid grace period in %s", source),
+			Error:    err,
+		}
+	}
+	if grace <= 0 {
+		return Result{Name: c.Name(), Passed: true, Message: "Skipped: orchestrator grace period unknown"}
+	}
+
+	need := c.ShutdownTimeout + c.PreShutdownDelay
+	if need > grace {
+		return Result{
+			Name:     c.Name(),
+			Passed:   false,
+			Severity: c.Severity,
+			Message: fmt.Sprintf("Shutdown timeout %s plus pre-shutdown delay %s exceeds the grace period %s (from %s), the process may be killed while draining",
+				c.ShutdownTimeout, c.PreShutdownDelay, grace, source),
+		}
+	}
+	return Result{Name: c.Name(), Passed: true}
+}
+
+// gracePeriod 返回宽限期及其来源，未设置时返回 0
+func (c *ShutdownBudgetChecker) gracePeriod() (time.Duration, string, error) {
+	if c.GracePeriod > 0 {
+		return c.GracePeriod, "config", nil
+	}
+	env := c.GracePeriodEnv
+	if env == "" {
+		env = DefaultGracePeriodEnv
+	}
+	v := os.Getenv(env)
+	if v == "" {
+		return 0, env, nil
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, env, nil
+	}
+	d, err := time.ParseDuration(v)
+	return d, env, err
+}
@@ -0,0 +1,53 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownBudgetChecker(t *testing.T) {
+	t.Run("Should skip when grace period is unknown", func(t *testing.T) {
+		t.Setenv(DefaultGracePeriodEnv, "")
+		c := &ShutdownBudgetChecker{ShutdownTimeout: time.Minute, Severity: SeverityWarn}
+		res := c.Check(context.Background())
+		assert.True(t, res.Passed)
+		assert.Contains(t, res.Message, "Skipped")
+	})
+
+	t.Run("Should fail when shutdown exceeds grace period", func(t *testing.T) {
+		c := &ShutdownBudgetChecker{
+			GracePeriod:      30 * time.Second,
+			ShutdownTimeout:  25 * time.Second,
+			PreShutdownDelay: 10 * time.Second,
+			Severity:         SeverityWarn,
+		}
+		res := c.Check(context.Background())
+		assert.False(t, res.Passed)
+		assert.Equal(t, SeverityWarn, res.Severity)
+		assert.Contains(t, res.Message, "exceeds the grace period 30s")
+
+		c.PreShutdownDelay = 5 * time.Second
+		assert.True(t, c.Check(context.Background()).Passed)
+	})
+
+	t.Run("Should read grace period from env", func(t *testing.T) {
+		c := &ShutdownBudgetChecker{GracePeriodEnv: "TEST_GRACE_PERIOD", ShutdownTimeout: time.Minute, Severity: SeverityFatal}
+
+		t.Setenv("TEST_GRACE_PERIOD", "30")
+		res := c.Check(context.Background())
+		assert.False(t, res.Passed)
+		assert.Equal(t, SeverityFatal, res.Severity)
+		assert.Contains(t, res.Message, "TEST_GRACE_PERIOD")
+
+		t.Setenv("TEST_GRACE_PERIOD", "90s")
+		assert.True(t, c.Check(context.Background()).Passed)
+
+		t.Setenv("TEST_GRACE_PERIOD", "soon")
+		res = c.Check(context.Background())
+		assert.False(t, res.Passed)
+		assert.Error(t, res.Error)
+	})
+}
@@ -2,77 +2,404 @@ package appx
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
 	"runtime/debug"
+	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
+	"github.com/oy3o/appx/cert"
 	"github.com/oy3o/appx/security"
-	"github.com/oy3o/httpx"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 )
 
 type Appx struct {
-	config          any
+	configs []configSection
+	// configRedactPaths 是配置快照中需要整体脱敏的路径
+	configRedactPaths [][]string
+	// configDedup 开启后，配置快照与上一次相同时不再打印；configStateFile 用于跨进程记录上一次的哈希
+	configDedup     bool
+	configStateFile string
+	configHash      string
+	// configSnapshotFile 非空时，启动与重载时将脱敏后的配置快照写入该文件
+	configSnapshotFile string
+	// hideRuntimeInfo 为 true 时启动时不打印运行时参数
+	hideRuntimeInfo bool
 	logger          *zerolog.Logger
 	shutdownTimeout time.Duration
 	secMgr          *security.Manager
+	// bridgeCheckers 是由其他组件 (如 WithCertExpiryCheck) 贡献的检查器，Run 时注册到 secMgr
+	bridgeCheckers []security.Checker
 
 	// 健康检查配置
 	healthTimeoutTotal    time.Duration
 	healthTimeoutPerCheck time.Duration
-
+	// healthConcurrency 限制同时执行的检查器数量，0 表示不限制
+	healthConcurrency int
+	// healthSem 限制全应用同时执行的检查器总数，nil 表示不限制
+	healthSem chan struct{}
+	// healthInterval 大于 0 时启用后台健康检查，/healthz 只返回缓存结果
+	healthInterval time.Duration
+	healthCache    atomic.Pointer[healthSnapshot]
+	// healthHistory 非 nil 时记录最近的健康状态变化
+	healthHistory *healthHistory
+	// /healthz 健康与不健康时返回的状态码，默认 200/503
+	healthyCode   int
+	unhealthyCode int
+	// minUptime 为就绪前需要的最短运行时间，从 readyAt (Run 中所有服务启动完成的时刻) 开始计算
+	minUptime time.Duration
+	readyAt   atomic.Pointer[time.Time]
+
+	// mu 保护 services 与 started，支持运行期间动态添加服务
+	mu             sync.Mutex
+	running        bool // Run 已被调用
+	started        bool
 	services       []Service
-	hooks          []ShutdownHook
-	healthCheckers []HealthChecker
+	hooks          []hookEntry
+	startupHooks   []StartupHook
+	healthCheckers []healthEntry
 
 	// fatalChan 用于接收 Service 运行时的致命错误
 	fatalChan chan error
 	// inShutdown 标记服务器是否已进入关闭流程
 	inShutdown atomic.Bool
+
+	// ready 在所有服务启动成功后关闭
+	ready chan struct{}
+	// shutdownReq 在调用 Shutdown 时关闭，stopped 在 Run 返回时关闭
+	shutdownReq  chan struct{}
+	shutdownOnce sync.Once
+	stopped      chan struct{}
+
+	// ctx 是应用的根 Context，在 New 中创建，关闭流程开始时取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	// baseCtx 是 WithBaseContext 注入的外部 Context，ctx 由它派生
+	baseCtx context.Context
+
+	// dryRun 开启后 Run 只做校验，不启动服务
+	dryRun bool
+
+	// restartPolicy 非 nil 时，实现了 Restartable 的服务出错后会被重启而不是关闭应用
+	restartPolicy *restartPolicy
+	// pending 记录在锁外进行的服务启动 (AddAndStart 与服务重启)，关闭流程在停止服务前等待其结束
+	pending sync.WaitGroup
+	// starting 是 AddAndStart 正在启动的服务名称，占位防止重名
+	starting map[string]struct{}
+
+	// deps 记录通过 AddWithDeps 声明的依赖，key 为服务名称
+	deps map[string][]string
+
+	// shutdownSignals 触发优雅关闭的信号，nil 表示使用默认的 SIGINT/SIGTERM
+	shutdownSignals []os.Signal
+	// signalHandlers 记录 WithReloadSignal 注册的信号回调
+	signalHandlers map[os.Signal]func() error
+
+	// concurrentStop 开启后，关闭流程中同一阶段 (互不依赖) 的服务并发停止
+	concurrentStop bool
+
+	// gracefulUpgrade 开启后，收到 SIGUSR2 时将监听器交给新进程，新进程就绪后优雅退出
+	gracefulUpgrade bool
+	upgradeTimeout  time.Duration // 等待新进程就绪的最长时间
+
+	// eventSink 与 observer 非 nil 时，生命周期状态变化会投递给它们 (前者异步，后者同步)
+	eventSink EventSink
+	observer  func(LifecycleEvent)
+
+	// reloadFn 非 nil 时，收到 SIGHUP 或调用 Reload 会重新加载配置并重新执行安全自检
+	reloadFn      ReloadFunc
+	onReloadFatal func(err error) bool
+	reloadChan    chan struct{}
+
+	// certReloaders 是 WithCertReload 注册的证书管理器，随配置重载一起从磁盘重载证书
+	certReloaders []*cert.Manager
 }
 
 func New(opts ...Option) *Appx {
 	s := &Appx{
 		shutdownTimeout:       30 * time.Second,
-		healthTimeoutTotal:    3 * time.Second, // 默认值保持不变，但现在可配置
-		healthTimeoutPerCheck: 2 * time.Second, // 默认值
+		healthTimeoutTotal:    defaultHealthTimeoutTotal,
+		healthTimeoutPerCheck: defaultHealthTimeoutPerCheck,
+		healthyCode:           http.StatusOK,
+		unhealthyCode:         http.StatusServiceUnavailable,
 		services:              make([]Service, 0),
-		hooks:                 make([]ShutdownHook, 0),
-		healthCheckers:        make([]HealthChecker, 0),
+		hooks:                 make([]hookEntry, 0),
+		healthCheckers:        make([]healthEntry, 0),
 		fatalChan:             make(chan error, 32),
+		ready:                 make(chan struct{}),
+		shutdownReq:           make(chan struct{}),
+		stopped:               make(chan struct{}),
+		reloadChan:            make(chan struct{}, 1),
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
+	base := s.baseCtx
+	if base == nil {
+		base = context.Background()
+	}
+	s.ctx, s.cancel = context.WithCancel(context.WithValue(base, shutdownStateKey{}, &s.inShutdown))
 	if s.logger == nil {
 		s.logger = &log.Logger
 	}
 	return s
 }
 
-// Add 注册服务
+// Add 注册服务。
+// 必须在 Run 之前调用；Run 启动后请使用 AddAndStart，否则会 panic。
+// 服务名称用作日志字段与指标标签，必须唯一，重名时 panic；需要以错误形式处理时请使用 AddChecked。
 func (s *Appx) Add(svc Service) {
+	if err := s.AddChecked(svc); err != nil {
+		panic(err.Error())
+	}
+}
+
+// AddChecked 与 Add 相同，但在 Run 之后调用或服务重名时返回错误而不是 panic
+func (s *Appx) AddChecked(svc Service) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("appx: Add(%q) called after Run, use AddAndStart instead", svc.Name())
+	}
+	if err := s.checkDuplicate(svc); err != nil {
+		return err
+	}
+	s.enroll(svc)
+	return nil
+}
+
+// AddAndStart 在运行期间动态添加服务 (如运行时发现的插件)。
+// 服务会被立即启动，并加入关闭流程 (与其他服务一样按注册的倒序停止)。
+// ctx 会被传递给 svc.Start，应当是与应用同生命周期的 Context，而不是某个请求的 Context。
+// 在 Run 之前调用等同于 Add；关闭流程开始后调用返回错误。并发安全。
+// svc.Start 在锁外执行，耗时的启动不会阻塞关闭流程；关闭流程会等待其返回后再停止服务。
+func (s *Appx) AddAndStart(ctx context.Context, svc Service) error {
+	s.mu.Lock()
+	if s.inShutdown.Load() {
+		s.mu.Unlock()
+		return fmt.Errorf("appx: cannot add service %s during shutdown", svc.Name())
+	}
+	if err := s.checkDuplicate(svc); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if !s.started {
+		s.enroll(svc)
+		s.mu.Unlock()
+		return nil
+	}
+	// 登记占位：名称不能被重复使用，关闭流程等待启动结束
+	if s.starting == nil {
+		s.starting = make(map[string]struct{})
+	}
+	s.starting[svc.Name()] = struct{}{}
+	s.pending.Add(1)
+	s.mu.Unlock()
+	defer s.pending.Done()
+
+	if notifier, ok := svc.(ErrorNotifiable); ok {
+		notifier.SetErrorNotify(s.notifierFor(svc))
+	}
+	ctx = context.WithValue(ctx, shutdownStateKey{}, &s.inShutdown)
+	err := svc.Start(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.starting, svc.Name())
+	if err != nil {
+		return fmt.Errorf("service %s start failed: %w", svc.Name(), err)
+	}
+	// 启动期间关闭流程可能已经开始，仍然登记，由关闭流程 (或启动失败的回滚) 停止
+	s.services = append(s.services, svc)
+	return nil
+}
+
+// checkDuplicate 检查服务名称是否已被注册或正在启动，调用方需持有 mu
+func (s *Appx) checkDuplicate(svc Service) error {
+	for _, existing := range s.services {
+		if existing.Name() == svc.Name() {
+			return fmt.Errorf("appx: duplicate service name %q", svc.Name())
+		}
+	}
+	if _, ok := s.starting[svc.Name()]; ok {
+		return fmt.Errorf("appx: duplicate service name %q", svc.Name())
+	}
+	return nil
+}
+
+// waitPending 等待在锁外进行的服务启动与重启结束，最多等待到 ctx 结束
+func (s *Appx) waitPending(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn().Msg("Timed out waiting for pending service starts, stopping services anyway")
+	}
+}
+
+// enroll 注入错误回调并登记服务，调用方需持有 mu
+func (s *Appx) enroll(svc Service) {
 	if notifier, ok := svc.(ErrorNotifiable); ok {
-		notifier.SetErrorNotify(s.notifyFatalError)
+		notifier.SetErrorNotify(s.notifierFor(svc))
 	}
 	s.services = append(s.services, svc)
 }
 
-// AddShutdownHook 注册关闭钩子
+// snapshotServices 返回当前已登记服务的副本
+func (s *Appx) snapshotServices() []Service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Service(nil), s.services...)
+}
+
+// hookEntry 是注册的关闭钩子
+type hookEntry struct {
+	fn ShutdownHook
+	// always 为 true 时，Run 因启动失败提前返回也会执行
+	always bool
+}
+
+// AddStartupHook 注册启动钩子，在安全自检通过之后、启动任何服务之前按注册顺序执行。
+// 任一钩子返回错误时中止启动：后续钩子与服务都不会执行，已注册的清理钩子会执行，Run 返回该错误。
+// 演练模式 (dry run) 下不执行。
+func (s *Appx) AddStartupHook(hook StartupHook) {
+	s.startupHooks = append(s.startupHooks, hook)
+}
+
+// AddShutdownHook 注册关闭钩子，仅在应用完整启动后的优雅关闭流程中执行
 func (s *Appx) AddShutdownHook(hook ShutdownHook) {
-	s.hooks = append(s.hooks, hook)
+	s.hooks = append(s.hooks, hookEntry{fn: hook})
 }
 
-// AddHealthChecker 注册健康检查
-func (s *Appx) AddHealthChecker(checker HealthChecker) {
-	s.healthCheckers = append(s.healthCheckers, checker)
+// AddCleanupHook 注册清理钩子 (如关闭 Run 之前打开的 DB 连接池)。
+// 与 AddShutdownHook 不同，即使 Run 因安全自检失败或服务启动失败而提前返回，它也会执行，避免资源泄漏。
+// 优雅关闭时与关闭钩子按注册顺序一起执行。
+func (s *Appx) AddCleanupHook(hook ShutdownHook) {
+	s.hooks = append(s.hooks, hookEntry{fn: hook, always: true})
+}
+
+// runHooks 按注册顺序执行钩子，cleanupOnly 为 true 时只执行清理钩子
+func (s *Appx) runHooks(ctx context.Context, cleanupOnly bool) {
+	for _, h := range s.hooks {
+		if cleanupOnly && !h.always {
+			continue
+		}
+		if err := h.fn(ctx); err != nil {
+			s.logger.Error().Err(err).Msg("Shutdown hook error")
+			if ctx.Err() != nil {
+				shutdownDroppedTotal.WithLabelValues(droppedHook).Inc()
+			}
+		}
+	}
+}
+
+// validate 是演练模式下的 Run：校验所有服务后执行清理钩子
+func (s *Appx) validate(ctx context.Context) error {
+	var errs []error
+	for _, svc := range s.snapshotServices() {
+		v, ok := svc.(Validator)
+		if !ok {
+			continue
+		}
+		if err := v.Validate(ctx); err != nil {
+			s.logger.Error().Err(err).Str("name", svc.Name()).Msg("Service validation failed")
+			errs = append(errs, fmt.Errorf("service %s: %w", svc.Name(), err))
+		}
+	}
+	s.runCleanupHooks()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.logger.Info().Msg("Dry run completed, all services validated")
+	return nil
+}
+
+// runCleanupHooks 在 Run 未进入运行状态就返回时 (启动失败或演练模式) 执行清理钩子
+func (s *Appx) runCleanupHooks() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	s.runHooks(ctx, true)
+}
+
+// AddHealthChecker 注册健康检查。
+// groups 为可选的分组标签 (如 "database")，可通过 /healthz?group=database 只执行该分组的检查。
+// 检查器可实现 KindedHealthChecker 声明参与 /readyz 还是 /livez，默认只参与 /readyz。
+func (s *Appx) AddHealthChecker(checker HealthChecker, groups ...string) {
+	s.healthCheckers = append(s.healthCheckers, healthEntry{checker: checker, groups: groups})
+}
+
+// AddHealthCheckerWithTimeout 与 AddHealthChecker 相同，但为该检查器单独设置超时 (如耗时较长的 DB Ping)，
+// 覆盖 WithHealthCheckTimeout 中的 perCheck。检查仍受总超时约束，d <= 0 时使用 perCheck。
+func (s *Appx) AddHealthCheckerWithTimeout(checker HealthChecker, d time.Duration, groups ...string) {
+	s.healthCheckers = append(s.healthCheckers, healthEntry{checker: checker, groups: groups, timeout: d})
+}
+
+// Ready 返回一个在所有服务启动成功后关闭的 channel。
+// 可用于 sidecar 协调或测试中等待应用就绪，而无需轮询端口。
+// 注意：仅在启动成功时关闭；若启动失败 (安全自检失败或服务启动失败)，
+// channel 永远不会关闭，Run 会返回对应的错误。
+func (s *Appx) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Context 返回应用的根 Context，在关闭流程开始时 (停止服务之前) 被取消。
+// New 之后即可获取，适合不属于 Service 模型的后台 goroutine (如 main 中启动的缓存刷新) 监听应用退出。
+// Run 因启动失败返回时同样会被取消。
+func (s *Appx) Context() context.Context {
+	return s.ctx
+}
+
+// Shutdown 以编程方式触发优雅关闭，效果与收到 SIGTERM 相同：
+// 标记关闭状态、取消根 Context、倒序停止服务并执行 Shutdown Hooks。
+// 阻塞直到 Run 完成关闭流程返回，或 ctx 结束 (此时返回 ctx.Err()，关闭流程仍在后台继续)。
+// 可安全地多次或并发调用。Run 尚未调用时只标记关闭状态并立即返回，之后调用 Run 会返回错误。
+// 注意不要在服务的 Stop 或 Shutdown Hook 中调用，否则会一直等待到 ctx 结束。
+func (s *Appx) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() { close(s.shutdownReq) })
+
+	s.mu.Lock()
+	if !s.running {
+		s.inShutdown.Store(true)
+		s.mu.Unlock()
+		s.cancel()
+		return nil
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdownStateKey 是关闭状态在 Context 中的 key
+type shutdownStateKey struct{}
+
+// IsShuttingDown 返回应用是否已进入关闭流程 (收到信号或致命错误之后，停止服务之前即为 true)。
+// 可用于在关闭期间停止接收新任务，或返回 Retry-After。
+func (s *Appx) IsShuttingDown() bool {
+	return s.inShutdown.Load()
+}
+
+// ShuttingDown 从 Context 中读取应用的关闭状态，供无法直接持有 *Appx 的代码使用。
+// Appx 传给 Service.Start 的 Context 携带该状态，HttpService 会将其传递到每个请求的 Context，
+// 因此 handler 中可以直接调用 appx.ShuttingDown(r.Context())。Context 未关联 Appx 时返回 false。
+func ShuttingDown(ctx context.Context) bool {
+	state, ok := ctx.Value(shutdownStateKey{}).(*atomic.Bool)
+	return ok && state.Load()
 }
 
 // notifyFatalError 内部回调
@@ -93,16 +420,18 @@ func (s *Appx) notifyFatalError(err error) {
 }
 
 // handlePanic 是一个内部辅助函数，用于在 Service 的 goroutine 中捕获 Panic。
-// 它会记录堆栈信息并通过 notifyFatalError 通知 Appx 关闭。
-// 使用方法: defer handlePanic(logger, notifier)
-func handlePanic(logger *zerolog.Logger, notifier ErrorNotifier) {
+// 它会记录堆栈信息、累加 appx_service_panic_total 并通过 notifyFatalError 通知 Appx 关闭。
+// 使用方法: defer handlePanic(logger, name, notifier)
+func handlePanic(logger *zerolog.Logger, service string, notifier ErrorNotifier) {
 	if r := recover(); r != nil {
 		stack := debug.Stack()
 		err := fmt.Errorf("service panic: %v", r)
+		servicePanicTotal.WithLabelValues(service).Inc()
 
 		// 1. 记录日志 (包含堆栈)
 		if logger != nil {
 			logger.Error().
+				Str("service", service).
 				Interface("panic", r).
 				Str("stack", string(stack)).
 				Msg("Service crashed with panic")
@@ -115,143 +444,235 @@ func handlePanic(logger *zerolog.Logger, notifier ErrorNotifier) {
 	}
 }
 
-// HealthHandler 返回一个标准的 http.Handler 用于 /healthz
-func (s *Appx) HealthHandler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Performance optimization: Fast-path for the common case where no health checkers are registered.
-		// Avoids context and errgroup allocation overhead on frequent /healthz probes.
-		if len(s.healthCheckers) == 0 {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-			return
-		}
-
-		// 1. 创建一个带有超时的上下文，防止整个健康检查请求耗时过长
-		// 使用配置的超时时间
-		ctx, cancel := context.WithTimeout(r.Context(), s.healthTimeoutTotal)
-		defer cancel()
+func (s *Appx) Run() (err error) {
+	s.mu.Lock()
+	if s.inShutdown.Load() {
+		s.mu.Unlock()
+		return errors.New("appx: Run called after Shutdown")
+	}
+	s.running = true
+	s.mu.Unlock()
+	defer close(s.stopped)
 
-		// 2. 创建 errgroup
-		g, ctx := errgroup.WithContext(ctx)
+	s.emit(LifecycleEvent{Phase: PhaseStarting})
+	defer func() { s.emit(LifecycleEvent{Phase: PhaseStopped, Err: err}) }()
 
-		// 3. 遍历所有检查器，并发执行
-		for _, c := range s.healthCheckers {
-			c := c // 捕获循环变量 (Go 1.22+ 不需要这行，但在旧版本是必须的)
+	// 0. 打印配置快照 (New Feature)
+	s.logConfigSnapshot()
+	if !s.hideRuntimeInfo {
+		printRuntimeInfo(s.logger)
+	}
 
-			g.Go(func() error {
-				checkCtx, checkCancel := context.WithTimeout(ctx, s.healthTimeoutPerCheck)
-				defer checkCancel()
+	// 根 Context 在 New 中创建，Run 返回时必定被取消
+	ctx, cancel := s.ctx, s.cancel
+	defer cancel()
 
-				if err := c.Check(checkCtx); err != nil {
-					return fmt.Errorf("[%s] %w", c.Name(), err)
-				}
-				return nil
-			})
-		}
+	// 按依赖关系确定启动顺序，依赖声明有误时在启动任何服务之前失败
+	s.mu.Lock()
+	ordered, err := orderServices(s.services, s.deps)
+	if err == nil {
+		s.services = ordered
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Invalid service dependencies")
+		s.runCleanupHooks()
+		return err
+	}
 
-		// 4. 等待结果
-		// errgroup 会返回第一个出现的错误，且一旦有错误，ctx 会被 cancel，
-		// 其他正在进行的检查如果监听了 ctx 也会尽快退出。
-		if err := g.Wait(); err != nil {
-			s.logger.Warn().Err(err).Msg("Health check failed")
-
-			// 返回 503 和具体的错误信息
-			httpx.Error(w, r, &httpx.HttpError{
-				HttpCode: http.StatusServiceUnavailable,
-				BizCode:  "Service Unavailable",
-				Msg:      fmt.Sprintf("Health check failed: %v", err),
-			})
-			return
+	// 1. 安全自检 (含 WithCertExpiryCheck 等桥接的检查器)
+	if len(s.bridgeCheckers) > 0 {
+		if s.secMgr == nil {
+			s.secMgr = security.New(s.logger)
 		}
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-}
-
-func (s *Appx) Run() error {
-	// 0. 打印配置快照 (New Feature)
-	if s.config != nil {
-		printConfigSnapshot(s.logger, s.config)
+		s.secMgr.Register(s.bridgeCheckers...)
 	}
-
-	// 1. 安全自检
 	if s.secMgr != nil {
+		s.emit(LifecycleEvent{Phase: PhaseSecurityCheck})
 		if err := s.secMgr.Run(context.Background()); err != nil {
 			s.logger.Error().Err(err).Msg("Security check failed")
+			s.runCleanupHooks()
 			return err
 		}
 	}
 
-	// 创建根 Context
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	if s.dryRun {
+		return s.validate(ctx)
+	}
+
+	// 2. 执行启动钩子
+	for i, hook := range s.startupHooks {
+		if err := hook(ctx); err != nil {
+			s.logger.Error().Err(err).Int("hook", i).Msg("Startup hook failed")
+			s.runCleanupHooks()
+			return fmt.Errorf("startup hook failed: %w", err)
+		}
+	}
 
-	// 2. 启动服务
+	// 3. 启动服务
 	// 由于 Service.Start 实现约定为非阻塞（内部 go func），这里直接顺序启动即可。
 	// 任何启动时的立即错误（如端口被占用）会立刻返回。
 	var startedServices []Service // 记录已启动的服务
 
-	for _, svc := range s.services {
+	s.mu.Lock()
+	s.started = true
+	services := append([]Service(nil), s.services...)
+	s.mu.Unlock()
+
+	for _, svc := range services {
 		if err := svc.Start(ctx); err != nil {
 			s.logger.Error().Err(err).Str("name", svc.Name()).Msg("Service failed to start, rolling back...")
 
-			// 回滚：停止已启动的服务
+			// 回滚：停止已启动的服务，包括启动期间通过 AddAndStart 加入的服务 (登记在 s.services 末尾)。
+			// 先标记关闭状态，之后的 AddAndStart 返回错误，进行中的等待其结束
 			rollbackCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
+			s.mu.Lock()
+			s.inShutdown.Store(true)
+			s.mu.Unlock()
+			s.waitPending(rollbackCtx)
+			s.mu.Lock()
+			startedServices = append(startedServices, s.services[len(services):]...)
+			s.mu.Unlock()
 			for i := len(startedServices) - 1; i >= 0; i-- {
-				_ = startedServices[i].Stop(rollbackCtx)
+				stopErr := startedServices[i].Stop(rollbackCtx)
+				s.emit(LifecycleEvent{Phase: PhaseServiceStopped, Service: startedServices[i].Name(), Err: stopErr})
 			}
+			s.runCleanupHooks()
 
 			return fmt.Errorf("service %s start failed: %w", svc.Name(), err)
 		}
 		startedServices = append(startedServices, svc)
+		s.emit(LifecycleEvent{Phase: PhaseServiceStarted, Service: svc.Name()})
+	}
+
+	// 后台健康检查随根 Context 一起结束
+	if s.healthInterval > 0 {
+		go s.runHealthLoop(ctx)
 	}
 
-	// 3. 信号监听与错误捕获
+	// 4. 信号监听与错误捕获，在通知就绪之前注册，就绪之后收到的信号不会丢失
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	upgrade := make(chan os.Signal, 1)
+	defer s.watchSignals(ctx, quit, upgrade)()
+
+	// 所有服务已启动，通知等待方 (包括发起平滑升级的父进程)
+	readyAt := time.Now()
+	s.readyAt.Store(&readyAt)
+	close(s.ready)
+	s.emit(LifecycleEvent{Phase: PhaseReady})
+	notifyUpgradeReady()
+
+	// 重载在独立的 goroutine 中执行，期间关闭信号照常处理
+	reloadFatal := make(chan error, 1)
+	if s.reloadEnabled() {
+		go s.runReload(ctx, reloadFatal)
+	}
 
 	var shutdownReason string
 	var returnErr error // 用于记录导致退出的错误
 
-	select {
-	// 由于 Start 是非阻塞的，ctx.Done() 只有在外部 cancel 时才会触发，或者配合其他 Context 管理
-	// 这里主要依赖 fatalChan 和 quit
-	case sig := <-quit:
-		shutdownReason = fmt.Sprintf("signal received: %s", sig)
-	case err := <-s.fatalChan:
-		shutdownReason = fmt.Sprintf("fatal service error: %v", err)
-		returnErr = err // 捕获错误用于返回
+	// upgrading 在平滑升级等待新进程就绪期间非 nil，期间关闭信号照常处理
+	var upgrading chan error
+	var upgradePID int
+
+wait:
+	for {
+		select {
+		// cancel 只在关闭流程中调用，此前 ctx.Done() 只会因 WithBaseContext 注入的外部 Context 结束而触发
+		case <-ctx.Done():
+			shutdownReason = fmt.Sprintf("base context done: %v", context.Cause(ctx))
+			break wait
+		case sig := <-quit:
+			shutdownReason = fmt.Sprintf("signal received: %s", sig)
+			break wait
+		case <-s.shutdownReq:
+			shutdownReason = "Shutdown called"
+			break wait
+		case err := <-s.fatalChan:
+			shutdownReason = fmt.Sprintf("fatal service error: %v", err)
+			returnErr = err // 捕获错误用于返回
+			break wait
+		case <-upgrade:
+			// 平滑升级：新进程报告就绪后，当前进程才进入优雅关闭排空连接
+			if upgrading != nil {
+				s.logger.Warn().Int("pid", upgradePID).Msg("Graceful upgrade already in progress")
+				continue
+			}
+			child, err := s.forkChild()
+			if err != nil {
+				s.logger.Error().Err(err).Msg("Graceful upgrade failed, keep serving")
+				continue
+			}
+			upgradePID = child.pid()
+			result := make(chan error, 1)
+			upgrading = result
+			go func() { result <- child.waitReady(orDefault(s.upgradeTimeout, defaultUpgradeTimeout)) }()
+			s.logger.Info().Int("pid", upgradePID).Msg("Waiting for new process to become ready")
+		case err := <-upgrading:
+			upgrading = nil
+			if err != nil {
+				s.logger.Error().Err(err).Msg("Graceful upgrade aborted, keep serving")
+				continue
+			}
+			s.commitUpgrade()
+			shutdownReason = fmt.Sprintf("graceful upgrade: handed over to pid %d", upgradePID)
+			break wait
+		case err := <-reloadFatal:
+			shutdownReason = fmt.Sprintf("security check failed after reload: %v", err)
+			returnErr = err
+			break wait
+		}
 	}
 
-	// 标记进入关闭状态
+	// 标记进入关闭状态 (持有锁，确保之后不会再有 AddAndStart 登记的服务)
+	s.mu.Lock()
 	s.inShutdown.Store(true)
+	s.mu.Unlock()
 
 	s.logger.Info().Str("reason", shutdownReason).Msg("Appx shutting down...")
+	s.emit(LifecycleEvent{Phase: PhaseDraining, Reason: shutdownReason})
 	cancel()
 
-	// 4. 优雅关闭流程
+	// 5. 优雅关闭流程
 	s.logger.Info().Msg("Shutting down appx...")
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer shutdownCancel()
 
-	// 4.1 倒序停止 Service (先停入口，再停后台)
-	for i := len(s.services) - 1; i >= 0; i-- {
-		svc := s.services[i]
-		s.logger.Info().Str("name", svc.Name()).Msg("Stopping service")
-		if err := svc.Stop(shutdownCtx); err != nil {
-			s.logger.Error().Err(err).Str("name", svc.Name()).Msg("Service stop error")
-		}
+	// 5.1 按阶段倒序停止 Service (先停入口，再停后台)，先等待进行中的启动与重启结束，避免并发地 Stop/Start 同一个服务
+	s.waitPending(shutdownCtx)
+	s.mu.Lock()
+	phases := shutdownPhases(s.services, s.deps, s.concurrentStop)
+	s.mu.Unlock()
+	for _, phase := range phases {
+		s.stopPhase(shutdownCtx, phase)
 	}
 
-	// 4.2 执行 Shutdown Hooks (关闭 DB, Redis 等)
-	for _, hook := range s.hooks {
-		if err := hook(shutdownCtx); err != nil {
-			s.logger.Error().Err(err).Msg("Shutdown hook error")
-		}
-	}
+	// 5.2 执行 Shutdown Hooks (关闭 DB, Redis 等)
+	s.runHooks(shutdownCtx, false)
 
 	s.logger.Info().Msg("Appx stopped gracefully")
 	return returnErr
 }
+
+// stopPhase 并发停止同一阶段的服务并等待全部返回。
+// 生命周期事件在全部停止后按阶段内的顺序在当前 goroutine 中发出，保持 observer 的同步语义。
+func (s *Appx) stopPhase(ctx context.Context, phase []Service) {
+	errs := make([]error, len(phase))
+	var g errgroup.Group
+	for i, svc := range phase {
+		s.logger.Info().Str("name", svc.Name()).Msg("Stopping service")
+		g.Go(func() error {
+			errs[i] = svc.Stop(ctx)
+			if errs[i] != nil {
+				s.logger.Error().Err(errs[i]).Str("name", svc.Name()).Msg("Service stop error")
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	for i, svc := range phase {
+		s.emit(LifecycleEvent{Phase: PhaseServiceStopped, Service: svc.Name(), Err: errs[i]})
+	}
+}
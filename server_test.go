@@ -4,17 +4,23 @@ import (
 	"context"
 	"github.com/bytedance/sonic"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/oy3o/appx/cert"
 	"github.com/oy3o/appx/security"
 	"github.com/oy3o/o11y"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"sync"
 )
 
@@ -120,13 +126,16 @@ func TestHandlePanic(t *testing.T) {
 		assert.Contains(t, err.Error(), "boom")
 	}
 
+	panics := testutil.ToFloat64(servicePanicTotal.WithLabelValues("panicky"))
+
 	// 模拟一个 panic 的 goroutine
 	func() {
-		defer handlePanic(&logger, notifier)
+		defer handlePanic(&logger, "panicky", notifier)
 		panic("boom")
 	}()
 
 	assert.True(t, notifyCalled)
+	assert.Equal(t, panics+1, testutil.ToFloat64(servicePanicTotal.WithLabelValues("panicky")))
 
 	// 验证日志包含堆栈
 	foundStack := false
@@ -267,6 +276,312 @@ func TestAppx_Run_Rollback(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "port binding failed")
 	assert.True(t, svc1Stopped, "Service 1 should be stopped (rolled back) when Service 2 fails to start")
+
+	// 启动失败时 Ready 不应关闭
+	select {
+	case <-app.Ready():
+		t.Fatal("Ready should not fire when startup fails")
+	default:
+	}
+}
+
+func TestAppx_Run_RollbackCleanupHooks(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+
+	var ran []string
+	app.AddShutdownHook(func(ctx context.Context) error {
+		ran = append(ran, "shutdown")
+		return nil
+	})
+	app.AddCleanupHook(func(ctx context.Context) error {
+		ran = append(ran, "cleanup")
+		return nil
+	})
+	app.Add(&MockService{
+		name:      "broken",
+		startFunc: func(ctx context.Context) error { return errors.New("port binding failed") },
+	})
+
+	assert.Error(t, app.Run())
+	// 启动失败时只执行清理钩子
+	assert.Equal(t, []string{"cleanup"}, ran)
+}
+
+func TestAppx_DryRun(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("valid", func(t *testing.T) {
+		app := New(WithLogger(&logger), WithDryRun())
+		started := false
+		app.Add(&MockService{name: "svc", startFunc: func(context.Context) error {
+			started = true
+			return nil
+		}})
+		app.Add(NewHttpService("http", "127.0.0.1:0", http.NotFoundHandler()))
+		cleaned := false
+		app.AddCleanupHook(func(context.Context) error {
+			cleaned = true
+			return nil
+		})
+
+		assert.NoError(t, app.Run())
+		assert.False(t, started, "dry run must not start services")
+		assert.True(t, cleaned)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		app := New(WithLogger(&logger), WithDryRun())
+		app.Add(NewHttpService("h3", ":0", nil).WithHTTP3())
+
+		err := app.Run()
+		assert.ErrorContains(t, err, "HTTP/3 requires TLS")
+		assert.ErrorContains(t, err, "service h3")
+	})
+}
+
+func TestAppx_Ready(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	svc := &MockService{name: "svc"}
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+
+	select {
+	case <-app.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ready was not closed after startup")
+	}
+
+	// 通过致命错误结束 Run
+	svc.errHandler(errors.New("stop"))
+	assert.EqualError(t, <-runErr, "stop")
+}
+
+func TestAppx_Context(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	ctx := app.Context()
+	assert.NoError(t, ctx.Err(), "Context should be usable before Run")
+
+	var errAtStop error
+	svc := &MockService{name: "svc"}
+	svc.stopFunc = func(context.Context) error {
+		errAtStop = ctx.Err()
+		return nil
+	}
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+	assert.NoError(t, ctx.Err())
+
+	svc.errHandler(errors.New("stop"))
+	<-runErr
+	// 关闭流程开始时即取消，早于服务停止
+	assert.ErrorIs(t, errAtStop, context.Canceled)
+}
+
+func TestAppx_StartupHooks(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("RunInOrderBeforeServices", func(t *testing.T) {
+		app := New(WithLogger(&logger))
+		var order []string
+		app.AddStartupHook(func(context.Context) error { order = append(order, "migrate"); return nil })
+		app.AddStartupHook(func(context.Context) error { order = append(order, "warmup"); return nil })
+		app.Add(&MockService{name: "svc", startFunc: func(context.Context) error {
+			order = append(order, "svc")
+			return nil
+		}})
+
+		runErr := make(chan error, 1)
+		go func() { runErr <- app.Run() }()
+		<-app.Ready()
+		assert.Equal(t, []string{"migrate", "warmup", "svc"}, order)
+		require.NoError(t, app.Shutdown(context.Background()))
+		assert.NoError(t, <-runErr)
+	})
+
+	t.Run("FailureAbortsStartup", func(t *testing.T) {
+		app := New(WithLogger(&logger))
+		var started, later, cleaned bool
+		app.AddStartupHook(func(context.Context) error { return errors.New("migration failed") })
+		app.AddStartupHook(func(context.Context) error { later = true; return nil })
+		app.AddCleanupHook(func(context.Context) error { cleaned = true; return nil })
+		app.Add(&MockService{name: "svc", startFunc: func(context.Context) error {
+			started = true
+			return nil
+		}})
+
+		err := app.Run()
+		assert.ErrorContains(t, err, "migration failed")
+		assert.False(t, later)
+		assert.False(t, started)
+		assert.True(t, cleaned)
+	})
+}
+
+func TestAppx_BaseContext(t *testing.T) {
+	logger := zerolog.Nop()
+	type key struct{}
+	base, stop := context.WithCancel(context.WithValue(context.Background(), key{}, "v"))
+	defer stop()
+	app := New(WithLogger(&logger), WithBaseContext(base))
+	assert.Equal(t, "v", app.Context().Value(key{}), "root context derives from the base context")
+
+	stopped := make(chan struct{})
+	svc := &MockService{name: "svc"}
+	svc.stopFunc = func(context.Context) error {
+		close(stopped)
+		return nil
+	}
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	// 取消外部 Context 触发优雅关闭，与收到信号一致
+	stop()
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run did not return after base context was canceled")
+	}
+	assert.True(t, app.IsShuttingDown())
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("service was not stopped")
+	}
+}
+
+func TestAppx_Shutdown(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("Running", func(t *testing.T) {
+		app := New(WithLogger(&logger))
+		var stops atomic.Int32
+		svc := &MockService{name: "svc"}
+		svc.stopFunc = func(context.Context) error {
+			stops.Add(1)
+			return nil
+		}
+		var hooked atomic.Bool
+		app.AddShutdownHook(func(context.Context) error {
+			hooked.Store(true)
+			return nil
+		})
+		app.Add(svc)
+
+		runErr := make(chan error, 1)
+		go func() { runErr <- app.Run() }()
+		<-app.Ready()
+
+		// 并发调用，全部在关闭流程完成后返回
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, app.Shutdown(context.Background()))
+			}()
+		}
+		wg.Wait()
+		assert.True(t, app.IsShuttingDown())
+		assert.Error(t, app.Context().Err())
+		assert.Equal(t, int32(1), stops.Load())
+		assert.True(t, hooked.Load())
+		assert.NoError(t, <-runErr)
+
+		// 关闭完成后再次调用立即返回
+		assert.NoError(t, app.Shutdown(context.Background()))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		app := New(WithLogger(&logger))
+		release := make(chan struct{})
+		svc := &MockService{name: "svc"}
+		svc.stopFunc = func(context.Context) error {
+			<-release
+			return nil
+		}
+		app.Add(svc)
+		go app.Run()
+		<-app.Ready()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, app.Shutdown(ctx), context.DeadlineExceeded)
+		close(release)
+		assert.NoError(t, app.Shutdown(context.Background()))
+	})
+
+	t.Run("BeforeRun", func(t *testing.T) {
+		app := New(WithLogger(&logger))
+		assert.NoError(t, app.Shutdown(context.Background()))
+		assert.True(t, app.IsShuttingDown())
+		assert.ErrorContains(t, app.Run(), "after Shutdown")
+	})
+}
+
+func TestAppx_ShuttingDown(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		fmt.Fprint(w, ShuttingDown(r.Context()))
+	})
+	httpSvc := NewHttpService("http", "127.0.0.1:0", handler).WithLogger(&logger)
+	svc := &MockService{name: "svc"}
+	app.Add(httpSvc)
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+	assert.False(t, app.IsShuttingDown())
+	assert.False(t, ShuttingDown(context.Background()))
+
+	base := "http://" + httpSvc.listener.Addr().String()
+	resp, err := http.Get(base)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "false", string(body))
+
+	// 关闭期间仍在排空的请求可以感知关闭状态，且其 Context 不会被取消
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-entered
+
+	svc.errHandler(errors.New("stop"))
+	require.Eventually(t, app.IsShuttingDown, time.Second, 5*time.Millisecond)
+	assert.True(t, ShuttingDown(app.Context()))
+	close(release)
+
+	assert.Equal(t, "true", <-slow)
+	assert.EqualError(t, <-runErr, "stop")
 }
 
 type mockChecker struct {
@@ -282,6 +597,30 @@ func (c *mockChecker) Check(ctx context.Context) security.Result {
 	return c.ResultVal
 }
 
+func TestAppx_CertExpiryCheck(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	// 测试证书 1 小时后过期，低于默认的 Fatal 阈值，自检失败导致启动中止
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithCertExpiryCheck(certMgr, 0, 0))
+	app.Add(&MockService{name: "svc"})
+	require.Error(t, app.Run())
+
+	res, ok := app.secMgr.LastResult("cert_expiry")
+	require.True(t, ok)
+	assert.False(t, res.Passed)
+	assert.Equal(t, security.SeverityFatal, res.Severity)
+
+	// 阈值调低后通过
+	app = New(WithLogger(&logger), WithCertExpiryCheck(certMgr, time.Minute, time.Second), WithDryRun())
+	require.NoError(t, app.Run())
+	res, ok = app.secMgr.LastResult("cert_expiry")
+	require.True(t, ok)
+	assert.True(t, res.Passed, res.Message)
+}
+
 func TestAppx_Run_SecurityCheckFail(t *testing.T) {
 	// 模拟安全检查失败导致无法启动
 	mockSecMgr := security.New(&log.Logger)
@@ -372,3 +711,109 @@ func TestHttpService_Options(t *testing.T) {
 	svc.WithKeepAlive(10 * time.Second)
 	assert.Equal(t, 10*time.Second, svc.keepAlivePeriod)
 }
+
+func TestAppx_AddAndStart(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	base := &MockService{name: "base"}
+	app.Add(base)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	// Run 之后调用 Add 应明确 panic
+	assert.PanicsWithValue(t, `appx: Add("late") called after Run, use AddAndStart instead`, func() {
+		app.Add(&MockService{name: "late"})
+	})
+
+	// 动态添加的服务会立即启动，并参与关闭流程
+	var started, stopped atomic.Bool
+	plugin := &MockService{
+		name:      "plugin",
+		startFunc: func(ctx context.Context) error { started.Store(true); return nil },
+		stopFunc:  func(ctx context.Context) error { stopped.Store(true); return nil },
+	}
+	assert.NoError(t, app.AddAndStart(context.Background(), plugin))
+	assert.True(t, started.Load())
+
+	// 动态添加的服务同样可以上报致命错误
+	plugin.errHandler(errors.New("plugin crashed"))
+	assert.EqualError(t, <-runErr, "plugin crashed")
+	assert.True(t, stopped.Load())
+
+	assert.Error(t, app.AddAndStart(context.Background(), &MockService{name: "too-late"}))
+}
+
+func TestAppx_AddAndStart_SlowStartDoesNotBlockShutdown(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	app.Add(&MockService{name: "base"})
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	starting := make(chan struct{})
+	release := make(chan struct{})
+	var stopped atomic.Bool
+	slow := &MockService{
+		name:      "slow",
+		startFunc: func(ctx context.Context) error { close(starting); <-release; return nil },
+		stopFunc:  func(ctx context.Context) error { stopped.Store(true); return nil },
+	}
+	addErr := make(chan error, 1)
+	go func() { addErr <- app.AddAndStart(context.Background(), slow) }()
+	<-starting
+
+	// 启动期间名称已被占用
+	assert.Error(t, app.AddAndStart(context.Background(), &MockService{name: "slow"}))
+
+	// 关闭流程可以立即开始，等待启动结束后停止该服务
+	go app.Shutdown(context.Background())
+	require.Eventually(t, app.IsShuttingDown, time.Second, time.Millisecond)
+	select {
+	case <-runErr:
+		t.Fatal("Run returned before the pending start finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-addErr)
+	assert.NoError(t, <-runErr)
+	assert.True(t, stopped.Load())
+}
+
+func TestAppx_StartFailureRollsBackDynamicServices(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	var stopped atomic.Bool
+	dynamic := &MockService{
+		name:     "dynamic",
+		stopFunc: func(ctx context.Context) error { stopped.Store(true); return nil },
+	}
+	// 第一个服务在启动过程中动态添加服务，随后第二个服务启动失败
+	app.Add(&MockService{name: "loader", startFunc: func(ctx context.Context) error {
+		return app.AddAndStart(ctx, dynamic)
+	}})
+	app.Add(&MockService{name: "broken", startFunc: func(ctx context.Context) error {
+		return errors.New("port in use")
+	}})
+
+	assert.ErrorContains(t, app.Run(), "port in use")
+	assert.True(t, stopped.Load(), "services added during startup are rolled back")
+}
+
+func TestAppx_DuplicateServiceName(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	app.Add(&MockService{name: "api"})
+
+	assert.EqualError(t, app.AddChecked(&MockService{name: "api"}), `appx: duplicate service name "api"`)
+	assert.PanicsWithValue(t, `appx: duplicate service name "api"`, func() {
+		app.Add(&MockService{name: "api"})
+	})
+	assert.Error(t, app.AddAndStart(context.Background(), &MockService{name: "api"}))
+	assert.Len(t, app.services, 1)
+
+	assert.NoError(t, app.AddChecked(&MockService{name: "worker"}))
+}
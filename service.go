@@ -32,11 +32,47 @@ type ErrorNotifiable interface {
 	SetErrorNotify(ErrorNotifier)
 }
 
+// Validator 是一个可选接口。
+// 开启 WithDryRun 时，Appx 会调用 Validate 代替 Start，用于在不监听端口、
+// 不启动后台任务的情况下校验服务的配置与依赖组装。
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// Readiness 是一个可选接口。
+// Service 在 Start 之后 (监听已绑定) 可能仍在预热，如缓存预加载、Leader 选举。
+// 实现此接口的服务会与注册的 HealthChecker 一起参与 /healthz 与 /readyz 的聚合，Ready 返回 false 期间视为不健康。
+type Readiness interface {
+	Ready(ctx context.Context) bool
+}
+
 // HealthChecker 定义健康检查接口
 type HealthChecker interface {
 	Name() string
 	Check(ctx context.Context) error
 }
 
+// HealthCheckerKind 指定健康检查器参与的探针类型
+type HealthCheckerKind int
+
+const (
+	// HealthReadiness 只参与就绪探针 (/readyz)，失败时摘除流量但不重启进程。未声明类型的检查器默认为此类型。
+	HealthReadiness HealthCheckerKind = iota
+	// HealthLiveness 只参与存活探针 (/livez)，失败时编排系统会重启进程，仅用于进程自身无法恢复的故障 (如死锁)。
+	HealthLiveness
+	// HealthBoth 同时参与两种探针
+	HealthBoth
+)
+
+// KindedHealthChecker 是一个可选接口。
+// HealthChecker 实现此接口可声明参与的探针类型；/healthz 不区分类型，始终执行所有检查器。
+type KindedHealthChecker interface {
+	HealthChecker
+	Kind() HealthCheckerKind
+}
+
 // ShutdownHook 定义关闭时的清理函数 (如关闭 DB)
 type ShutdownHook func(ctx context.Context) error
+
+// StartupHook 定义启动前的一次性初始化函数 (如执行 DB 迁移、预热缓存)
+type StartupHook func(ctx context.Context) error
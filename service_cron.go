@@ -0,0 +1,246 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// CronJobInfo 是定时任务的运行时快照
+type CronJobInfo struct {
+	Spec    string    `json:"spec"`
+	Next    time.Time `json:"next"`                 // 下一次计划执行的时间，未启动或表达式无法满足时为零值
+	LastRun time.Time `json:"last_run,omitzero"`    // 最近一次开始执行的时间
+	LastErr string    `json:"last_error,omitempty"` // 最近一次执行返回的错误或 panic
+	Running bool      `json:"running"`
+}
+
+// cronJob 是一个已注册的定时任务
+type cronJob struct {
+	spec     string
+	schedule cronSchedule
+	fn       func(ctx context.Context) error
+
+	mu      sync.Mutex
+	next    time.Time
+	lastRun time.Time
+	lastErr string
+	running bool
+}
+
+// CronService 按 cron 表达式调度定时任务，并托管到 Appx 生命周期中。
+// 同一个任务不会重叠执行：上一次执行超过了下一个触发时间时，错过的触发被跳过。
+// 任务中的 panic 会被恢复并记录 (计入 appx_service_panic_total)，只跳过本次执行，不会导致应用退出。
+type CronService struct {
+	name   string
+	logger *zerolog.Logger
+	loc    *time.Location
+
+	mu      sync.Mutex
+	jobs    []*cronJob
+	ctx     context.Context    // 任务执行使用的 Context，Stop 超时后取消
+	cancel  context.CancelFunc // 取消 ctx
+	stop    chan struct{}      // 关闭后不再触发新的执行
+	running bool
+	wg      sync.WaitGroup // 调度协程 (含执行中的任务)
+}
+
+var _ Service = (*CronService)(nil)
+
+func NewCronService(name string) *CronService {
+	return &CronService{
+		name: name,
+		loc:  time.Local,
+	}
+}
+
+func (s *CronService) WithLogger(l *zerolog.Logger) *CronService {
+	s.logger = l
+	return s
+}
+
+// WithLocation 设置解析 cron 表达式使用的时区 (默认 time.Local)，须在 AddJob 之前调用
+func (s *CronService) WithLocation(loc *time.Location) *CronService {
+	s.loc = loc
+	return s
+}
+
+func (s *CronService) Name() string { return s.name }
+
+// AddJob 注册定时任务。spec 为标准 5 字段 cron 表达式 (分 时 日 月 周)，
+// 也支持 @hourly、@daily 等别名与 "@every 30s"。表达式无效时返回错误。
+// 可以在 Start 之后调用，新任务立即开始调度。
+func (s *CronService) AddJob(spec string, fn func(ctx context.Context) error) error {
+	schedule, err := parseCron(spec, s.loc)
+	if err != nil {
+		return err
+	}
+	job := &cronJob{spec: spec, schedule: schedule, fn: fn}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+	if s.running {
+		s.schedule(job)
+	}
+	return nil
+}
+
+// Jobs 返回所有任务的快照 (按注册顺序)，包含下一次执行时间，可用于暴露到管理接口或日志
+func (s *CronService) Jobs() []CronJobInfo {
+	s.mu.Lock()
+	jobs := append([]*cronJob(nil), s.jobs...)
+	s.mu.Unlock()
+
+	infos := make([]CronJobInfo, len(jobs))
+	for i, job := range jobs {
+		job.mu.Lock()
+		infos[i] = CronJobInfo{
+			Spec:    job.spec,
+			Next:    job.next,
+			LastRun: job.lastRun,
+			LastErr: job.lastErr,
+			Running: job.running,
+		}
+		job.mu.Unlock()
+	}
+	return infos
+}
+
+func (s *CronService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return errors.New("cron service already started")
+	}
+
+	// 任务的 Context 继承 Start ctx 中的值，但不继承其取消，由 Stop 在超时后取消
+	s.ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	s.stop = make(chan struct{})
+	s.running = true
+	for _, job := range s.jobs {
+		s.schedule(job)
+	}
+
+	if s.logger != nil {
+		s.logger.Info().Str("name", s.name).Int("jobs", len(s.jobs)).Msg("Cron scheduler started")
+	}
+	return nil
+}
+
+// schedule 为任务启动调度协程，调用方需持有 s.mu。
+// 协程使用本次 Start 的 ctx 与 stop：Stop 超时后再次 Start 时，旧的协程结束当前执行后随即退出。
+func (s *CronService) schedule(job *cronJob) {
+	ctx, stop := s.ctx, s.stop
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx, stop, job)
+	}()
+}
+
+// loop 按计划循环执行任务，直到服务停止或表达式不再有下一次触发
+func (s *CronService) loop(ctx context.Context, stop <-chan struct{}, job *cronJob) {
+	for {
+		next := job.schedule.next(time.Now())
+		job.mu.Lock()
+		job.next = next
+		job.mu.Unlock()
+		if next.IsZero() {
+			if s.logger != nil {
+				s.logger.Warn().Str("name", s.name).Str("spec", job.spec).Msg("Cron job will never run again")
+			}
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.run(ctx, job)
+	}
+}
+
+// run 执行一次任务，panic 与错误只记录，不影响后续调度。
+// 上一次执行仍未结束时 (如 Stop 超时后重新 Start) 跳过本次触发。
+func (s *CronService) run(ctx context.Context, job *cronJob) {
+	start := time.Now()
+	job.mu.Lock()
+	if job.running {
+		job.mu.Unlock()
+		return
+	}
+	job.lastRun, job.running = start, true
+	job.mu.Unlock()
+
+	var err error
+	func() {
+		// panic 经统一的处理记录日志、堆栈与指标，转换为本次执行的错误，而不是通知 Appx 退出
+		defer handlePanic(s.logger, s.name, func(p error) { err = p })
+		err = job.fn(ctx)
+	}()
+
+	job.mu.Lock()
+	job.running = false
+	job.lastErr = ""
+	if err != nil {
+		job.lastErr = err.Error()
+	}
+	job.mu.Unlock()
+
+	if err != nil && s.logger != nil {
+		s.logger.Error().Err(err).
+			Str("name", s.name).
+			Str("spec", job.spec).
+			Dur("latency", time.Since(start)).
+			Msg("Cron job failed")
+	}
+}
+
+// Stop 停止触发新的执行，并等待执行中的任务完成。
+// ctx 结束时取消任务的 Context 并返回 ctx.Err()，仍未结束的任务计入 appx_shutdown_dropped_total{kind="task"}。
+func (s *CronService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	close(s.stop)
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		cancel()
+		return nil
+	case <-ctx.Done():
+	}
+
+	cancel()
+	var n int
+	for _, info := range s.Jobs() {
+		if info.Running {
+			n++
+		}
+	}
+	if n > 0 {
+		shutdownDroppedTotal.WithLabelValues(droppedTask).Add(float64(n))
+		if s.logger != nil {
+			s.logger.Warn().Int("jobs", n).Str("name", s.name).Msg("Cron jobs still running after shutdown timeout")
+		}
+	}
+	return ctx.Err()
+}
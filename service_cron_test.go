@@ -0,0 +1,139 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	// 2024-03-15 是周五
+	base := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 3, 15, 10, 25, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)},
+		{"30 2 1,15 * *", time.Date(2024, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// 日与周同时受限时满足其一即可
+		{"0 12 1 * sat", time.Date(2024, 3, 16, 12, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := parseCron(tt.spec, time.UTC)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.next(base))
+		})
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every -1s", "@every soon"} {
+		_, err := parseCron(spec, time.UTC)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCronService(t *testing.T) {
+	svc := NewCronService("cron")
+	assert.Error(t, svc.AddJob("not a spec", func(context.Context) error { return nil }))
+
+	var runs, panics atomic.Int32
+	require.NoError(t, svc.AddJob("@every 10ms", func(context.Context) error {
+		runs.Add(1)
+		return errors.New("boom")
+	}))
+	require.NoError(t, svc.AddJob("@every 10ms", func(context.Context) error {
+		panics.Add(1)
+		panic("oops")
+	}))
+	assert.True(t, svc.Jobs()[0].Next.IsZero(), "not scheduled before Start")
+
+	require.NoError(t, svc.Start(context.Background()))
+	assert.Error(t, svc.Start(context.Background()))
+
+	// panic 只跳过本次执行，任务继续调度
+	require.Eventually(t, func() bool { return runs.Load() >= 2 && panics.Load() >= 2 }, 2*time.Second, 5*time.Millisecond)
+	jobs := svc.Jobs()
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "@every 10ms", jobs[0].Spec)
+	assert.False(t, jobs[0].Next.IsZero())
+	assert.False(t, jobs[0].LastRun.IsZero())
+	assert.Equal(t, "boom", jobs[0].LastErr)
+	assert.Contains(t, jobs[1].LastErr, "oops")
+
+	// Start 之后注册的任务立即开始调度
+	var late atomic.Int32
+	require.NoError(t, svc.AddJob("@every 10ms", func(context.Context) error {
+		late.Add(1)
+		return nil
+	}))
+	require.Eventually(t, func() bool { return late.Load() > 0 }, 2*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, svc.Stop(context.Background()))
+	n := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, n, runs.Load(), "no runs after Stop")
+}
+
+func TestCronService_StopTimeout(t *testing.T) {
+	svc := NewCronService("cron")
+	started := make(chan struct{})
+	var once atomic.Bool
+	require.NoError(t, svc.AddJob("@every 10ms", func(ctx context.Context) error {
+		if once.CompareAndSwap(false, true) {
+			close(started)
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	require.NoError(t, svc.Start(context.Background()))
+	<-started
+	assert.True(t, svc.Jobs()[0].Running)
+
+	// 超时后取消任务的 Context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+	require.Eventually(t, func() bool { return !svc.Jobs()[0].Running }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, context.Canceled.Error(), svc.Jobs()[0].LastErr)
+}
+
+func TestCronService_RestartAfterStopTimeout(t *testing.T) {
+	svc := NewCronService("cron")
+	var active, overlaps, runs atomic.Int32
+	require.NoError(t, svc.AddJob("@every 5ms", func(ctx context.Context) error {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer active.Add(-1)
+		runs.Add(1)
+		// 忽略取消，执行时间超过触发间隔与 Stop 的超时
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}))
+	require.NoError(t, svc.Start(context.Background()))
+	require.Eventually(t, func() bool { return svc.Jobs()[0].Running }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+
+	// 上一次执行尚未结束时重新启动，同一任务仍不会重叠执行
+	require.NoError(t, svc.Start(context.Background()))
+	require.Eventually(t, func() bool { return runs.Load() >= 4 }, 2*time.Second, time.Millisecond)
+	require.NoError(t, svc.Stop(context.Background()))
+	assert.Zero(t, overlaps.Load())
+}
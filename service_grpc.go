@@ -6,8 +6,11 @@ import (
 	"time"
 
 	"github.com/oy3o/netx"
+	"github.com/oy3o/o11y"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type GrpcService struct {
@@ -18,10 +21,34 @@ type GrpcService struct {
 	listener net.Listener
 	onFatal  ErrorNotifier
 	maxConns int
+
+	// health 为标准 gRPC 健康检查服务，Stop 时先切换为 NOT_SERVING 再排空
+	health *health.Server
+	// drainDelay 是切换 NOT_SERVING 后等待负载均衡器摘除流量的时间
+	drainDelay time.Duration
+	// tracker 统计进行中的 RPC，用于在强制停止时报告被中断的调用数
+	tracker *RPCTracker
+	// unaryDrain / streamDrain 是 Stop 时 unary 与 streaming 调用各自的排空窗口
+	unaryDrain  time.Duration
+	streamDrain time.Duration
 }
 
 var _ Service = (*GrpcService)(nil)
 
+// GrpcObservabilityOptions 返回 gRPC 可观测性所需的 ServerOption，与 HttpService.WithObservability 对应：
+// OTel StatsHandler 负责 Trace 传播与 RPC 指标，拦截器将绑定 trace_id 的 Logger 注入调用的 Context，
+// 因此 handler 中 o11y.GetLoggerFromContext 与 o11y.GetTraceID 的行为与 HTTP handler 一致。
+// 拦截器只能在创建 grpc.Server 时注入，cfg.Enabled 为 false 时返回 nil:
+//
+//	srv := grpc.NewServer(appx.GrpcObservabilityOptions(cfg.O11y)...)
+//	app.Add(appx.NewGrpcService("grpc", ":9000", srv))
+func GrpcObservabilityOptions(cfg o11y.Config) []grpc.ServerOption {
+	if !cfg.Enabled {
+		return nil
+	}
+	return o11y.GRPCServerOptions()
+}
+
 func NewGrpcService(name, addr string, srv *grpc.Server) *GrpcService {
 	return &GrpcService{
 		name:     name,
@@ -36,6 +63,46 @@ func (s *GrpcService) WithLogger(l *zerolog.Logger) *GrpcService {
 	return s
 }
 
+// WithHealth 在 gRPC Server 上注册标准健康检查服务 (grpc.health.v1.Health)。
+// 启动后状态为 SERVING，Stop 时会先切换为 NOT_SERVING，
+// 使用 health-checking 负载均衡策略的客户端可以在连接断开前完成摘流。
+// 注意：必须在 Start 之前调用。
+func (s *GrpcService) WithHealth() *GrpcService {
+	s.health = health.NewServer()
+	healthpb.RegisterHealthServer(s.server, s.health)
+	return s
+}
+
+// WithDrainDelay 设置 Stop 时从 NOT_SERVING 到 GracefulStop 之间的等待时间。
+// 应略大于客户端健康检查的探测周期。
+func (s *GrpcService) WithDrainDelay(d time.Duration) *GrpcService {
+	s.drainDelay = d
+	return s
+}
+
+// WithRPCTracker 关联 RPC 统计器 (其拦截器需在创建 grpc.Server 时通过 ServerOptions 注入)。
+// 优雅停止超时后，被强制中断的调用数会记录到日志与 appx_shutdown_dropped_total{kind="grpc_rpc"}。
+func (s *GrpcService) WithRPCTracker(t *RPCTracker) *GrpcService {
+	s.tracker = t
+	return s
+}
+
+// WithDrainWindows 为 unary 与 streaming 调用设置不同的排空窗口 (从 GracefulStop 开始计时)。
+// Stop 时先拒绝新 RPC；unary 窗口到期后取消仍在进行中的 unary 调用的 Context，
+// stream 窗口到期后强制停止，中断剩余的 streaming 调用。两者均受 Stop 的 ctx 约束，0 表示不单独限制。
+// unary 窗口依赖 RPCTracker 拦截器 (见 WithRPCTracker)，未关联时只有 stream 窗口生效。
+func (s *GrpcService) WithDrainWindows(unary, stream time.Duration) *GrpcService {
+	s.unaryDrain = unary
+	s.streamDrain = stream
+	return s
+}
+
+// HealthServer 返回健康检查服务，可用于设置各个子服务的状态。
+// 未调用 WithHealth 时返回 nil。
+func (s *GrpcService) HealthServer() *health.Server {
+	return s.health
+}
+
 func (s *GrpcService) SetErrorNotify(fn ErrorNotifier) {
 	s.onFatal = fn
 }
@@ -56,9 +123,13 @@ func (s *GrpcService) Start(ctx context.Context) error {
 	)
 	s.listener = ln
 
+	if s.health != nil {
+		s.health.Resume()
+	}
+
 	go func() {
 		// 使用统一的 Panic 处理机制
-		defer handlePanic(s.logger, s.onFatal)
+		defer handlePanic(s.logger, s.name, s.onFatal)
 
 		// 打印启动信息
 		printServiceListening(s.logger, s.name, "gRPC (HTTP/2)", ln.Addr().String())
@@ -77,19 +148,90 @@ func (s *GrpcService) Start(ctx context.Context) error {
 }
 
 func (s *GrpcService) Stop(ctx context.Context) error {
+	// 1. 切换为 NOT_SERVING，通知健康检查客户端停止路由新请求
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+
+	// 2. 等待负载均衡器感知状态变化
+	if s.drainDelay > 0 {
+		select {
+		case <-time.After(s.drainDelay):
+		case <-ctx.Done():
+			s.recordDropped()
+			s.server.Stop()
+			return ctx.Err()
+		}
+	}
+
+	// 3. 优雅停止，4. ctx 超时后强制停止
 	// gRPC GracefulStop 是阻塞的，但没有 Context 超时参数
 	// 我们可以用一个 goroutine + select 来模拟超时
+	if s.streamDrain > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.streamDrain)
+		defer cancel()
+	}
+	var unaryDeadline <-chan time.Time
+	if s.unaryDrain > 0 && s.tracker != nil {
+		timer := time.NewTimer(s.unaryDrain)
+		defer timer.Stop()
+		unaryDeadline = timer.C
+	}
+
 	done := make(chan struct{})
 	go func() {
 		s.server.GracefulStop()
 		close(done)
 	}()
 
-	select {
-	case <-done:
-		return nil
-	case <-ctx.Done():
-		s.server.Stop() // 强制停止
-		return ctx.Err()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-unaryDeadline:
+			// unary 窗口到期：只中断 unary 调用，streaming 调用继续排空
+			unaryDeadline = nil
+			s.abortUnary()
+		case <-ctx.Done():
+			s.recordDropped()
+			s.server.Stop() // 强制停止
+			return ctx.Err()
+		}
+	}
+}
+
+// abortUnary 取消 unary 窗口到期后仍在进行中的 unary 调用。
+// 被取消的调用同时移出 tracker 的统计，之后强制停止时 recordDropped 不会重复计入。
+func (s *GrpcService) abortUnary() {
+	unary := s.tracker.abortUnary()
+	if unary == 0 {
+		return
+	}
+	shutdownDroppedTotal.WithLabelValues(droppedGrpcRPC).Add(float64(unary))
+	if s.logger != nil {
+		s.logger.Warn().
+			Str("name", s.name).
+			Int64("unary", unary).
+			Msg("Cancelled in-flight unary RPCs after unary drain window")
+	}
+}
+
+// recordDropped 记录强制停止时仍在进行中的 RPC
+func (s *GrpcService) recordDropped() {
+	if s.tracker == nil {
+		return
+	}
+	unary, stream := s.tracker.Active()
+	if unary+stream == 0 {
+		return
+	}
+	shutdownDroppedTotal.WithLabelValues(droppedGrpcRPC).Add(float64(unary + stream))
+	if s.logger != nil {
+		s.logger.Warn().
+			Str("name", s.name).
+			Int64("unary", unary).
+			Int64("stream", stream).
+			Msg("Force-terminating in-flight RPCs after graceful stop timeout")
 	}
 }
@@ -0,0 +1,243 @@
+package appx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oy3o/o11y"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGrpcService_StopDrainsHealth(t *testing.T) {
+	logger := zerolog.Nop()
+	svc := NewGrpcService("grpc-test", "127.0.0.1:0", grpc.NewServer()).
+		WithLogger(&logger).
+		WithHealth().
+		WithDrainDelay(300 * time.Millisecond)
+	require.NoError(t, svc.Start(context.Background()))
+
+	conn, err := grpc.NewClient(svc.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- svc.Stop(context.Background()) }()
+
+	// 排空期间连接仍可用，但状态已切换为 NOT_SERVING
+	require.Eventually(t, func() bool {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_NOT_SERVING
+	}, 250*time.Millisecond, 10*time.Millisecond)
+
+	require.NoError(t, <-stopped)
+}
+
+func TestGrpcService_StopReportsDroppedRPCs(t *testing.T) {
+	logger := zerolog.Nop()
+	tracker := NewRPCTracker()
+	svc := NewGrpcService("grpc-drop", "127.0.0.1:0", grpc.NewServer(tracker.ServerOptions()...)).
+		WithLogger(&logger).
+		WithHealth().
+		WithRPCTracker(tracker)
+	require.NoError(t, svc.Start(context.Background()))
+
+	conn, err := grpc.NewClient(svc.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Watch 是一个长期存在的 streaming RPC，会阻塞 GracefulStop
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	unary, streams := tracker.Active()
+	assert.Equal(t, int64(0), unary)
+	assert.Equal(t, int64(1), streams)
+
+	before := testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedGrpcRPC))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+	assert.Equal(t, before+1, testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedGrpcRPC)))
+}
+
+// blockingHealthServer 的 Check 阻塞直到调用的 Context 被取消，Watch 推送一次状态后保持打开
+type blockingHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (blockingHealthServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingHealthServer) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func TestGrpcService_StopDrainWindows(t *testing.T) {
+	logger := zerolog.Nop()
+	tracker := NewRPCTracker()
+	srv := grpc.NewServer(tracker.ServerOptions()...)
+	healthpb.RegisterHealthServer(srv, blockingHealthServer{})
+	svc := NewGrpcService("grpc-windows", "127.0.0.1:0", srv).
+		WithLogger(&logger).
+		WithRPCTracker(tracker).
+		WithDrainWindows(50*time.Millisecond, 5*time.Second)
+	require.NoError(t, svc.Start(context.Background()))
+
+	conn, err := grpc.NewClient(svc.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	streamCtx, closeStream := context.WithCancel(context.Background())
+	defer closeStream()
+	stream, err := client.Watch(streamCtx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	unaryErr := make(chan error, 1)
+	go func() {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		unaryErr <- err
+	}()
+	require.Eventually(t, func() bool {
+		unary, _ := tracker.Active()
+		return unary == 1
+	}, time.Second, 5*time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- svc.Stop(context.Background()) }()
+
+	// unary 窗口到期后 unary 调用被取消，stream 仍在排空
+	select {
+	case err := <-unaryErr:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("unary call was not cancelled after its drain window")
+	}
+	_, streams := tracker.Active()
+	assert.Equal(t, int64(1), streams)
+
+	// stream 在自己的窗口内结束后，Stop 正常返回
+	closeStream()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return after the stream finished")
+	}
+}
+
+// slowHealthServer 的 Check 忽略 Context 的取消，delay 之后才返回
+type slowHealthServer struct {
+	blockingHealthServer
+	delay time.Duration
+}
+
+func (h slowHealthServer) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	time.Sleep(h.delay)
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestGrpcService_AbortedUnaryCountedOnce(t *testing.T) {
+	logger := zerolog.Nop()
+	tracker := NewRPCTracker()
+	srv := grpc.NewServer(tracker.ServerOptions()...)
+	healthpb.RegisterHealthServer(srv, slowHealthServer{delay: 500 * time.Millisecond})
+	svc := NewGrpcService("grpc-abort-once", "127.0.0.1:0", srv).
+		WithLogger(&logger).
+		WithRPCTracker(tracker).
+		WithDrainWindows(50*time.Millisecond, 200*time.Millisecond)
+	require.NoError(t, svc.Start(context.Background()))
+
+	conn, err := grpc.NewClient(svc.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	go client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.Eventually(t, func() bool {
+		unary, _ := tracker.Active()
+		return unary == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// unary 调用被取消后直到强制停止时仍未返回，只计入一次
+	before := testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedGrpcRPC))
+	assert.ErrorIs(t, svc.Stop(context.Background()), context.DeadlineExceeded)
+	assert.Equal(t, before+2, testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedGrpcRPC)))
+	unary, _ := tracker.Active()
+	assert.Equal(t, int64(0), unary)
+}
+
+// loggingHealthServer 在 handler 中通过 o11y 从 Context 获取 Logger 记录日志
+type loggingHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	traceID chan string
+}
+
+func (h *loggingHealthServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	o11y.GetLoggerFromContext(ctx).Info().Msg("handling check")
+	h.traceID <- o11y.GetTraceID(ctx)
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestGrpcObservabilityOptions_LoggerCarriesTraceID(t *testing.T) {
+	assert.Nil(t, GrpcObservabilityOptions(o11y.Config{}))
+
+	prevTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	defer otel.SetTracerProvider(prevTP)
+
+	var buf syncBuffer
+	prevLogger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = prevLogger }()
+
+	h := &loggingHealthServer{traceID: make(chan string, 1)}
+	srv := grpc.NewServer(GrpcObservabilityOptions(o11y.Config{Enabled: true})...)
+	healthpb.RegisterHealthServer(srv, h)
+
+	logger := zerolog.Nop()
+	svc := NewGrpcService("grpc-o11y", "127.0.0.1:0", srv).WithLogger(&logger)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	conn, err := grpc.NewClient(svc.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	traceID := <-h.traceID
+	require.NotEmpty(t, traceID)
+	assert.Contains(t, buf.String(), `"trace_id":"`+traceID+`"`)
+	assert.Contains(t, buf.String(), "handling check")
+}
@@ -3,20 +3,105 @@ package appx
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/felixge/httpsnoop"
 	"github.com/oy3o/appx/cert"
 	"github.com/oy3o/httpx"
 	"github.com/oy3o/netx"
 	"github.com/oy3o/o11y"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
+// StopOrder 定义 HttpService 停止时 HTTP/3 与 TCP 服务器的关闭顺序
+type StopOrder int
+
+const (
+	// StopHTTP3First 先关闭 HTTP/3 再关闭 TCP (默认)
+	StopHTTP3First StopOrder = iota
+	// StopTCPFirst 先关闭 TCP 再关闭 HTTP/3
+	StopTCPFirst
+	// StopConcurrent 并发关闭 HTTP/3 与 TCP (推荐)
+	StopConcurrent
+)
+
+const (
+	// defaultQUICIdleTimeout 是 QUIC 连接默认的最大空闲时间
+	defaultQUICIdleTimeout = 30 * time.Second
+	// defaultAltSvcMaxAge 是 Alt-Svc 默认的 ma (30 天)
+	defaultAltSvcMaxAge = 30 * 24 * time.Hour
+)
+
+// TLSOptions 是 WithTLSOptions 的 TLS 协议参数，零值字段使用默认值
+type TLSOptions struct {
+	// MinVersion 最低 TLS 版本，默认 tls.VersionTLS13，不允许低于 tls.VersionTLS12
+	MinVersion uint16
+	// MaxVersion 最高 TLS 版本，默认使用 crypto/tls 支持的最高版本
+	MaxVersion uint16
+	// CipherSuites TLS 1.2 的密码套件 (TLS 1.3 的套件不可配置)，默认使用 crypto/tls 的安全列表。
+	// 只接受 tls.CipherSuites 中支持 TLS 1.2 的套件，并要求 MinVersion 为 TLS 1.2。
+	CipherSuites []uint16
+	// CurvePreferences 密钥交换曲线的优先顺序，默认使用 crypto/tls 的默认值
+	CurvePreferences []tls.CurveID
+}
+
+// minVersion 返回生效的最低 TLS 版本
+func (o TLSOptions) minVersion() uint16 {
+	if o.MinVersion == 0 {
+		return tls.VersionTLS13
+	}
+	return o.MinVersion
+}
+
+func (o TLSOptions) isZero() bool {
+	return o.MinVersion == 0 && o.MaxVersion == 0 && len(o.CipherSuites) == 0 && len(o.CurvePreferences) == 0
+}
+
+// validate 检查版本与密码套件，http3 为 true 时要求允许 TLS 1.3 (QUIC 只支持 TLS 1.3)
+func (o TLSOptions) validate(http3 bool) error {
+	minVer := o.minVersion()
+	if minVer != tls.VersionTLS12 && minVer != tls.VersionTLS13 {
+		return fmt.Errorf("TLS min version %s is not allowed, use TLS 1.2 or TLS 1.3", tls.VersionName(minVer))
+	}
+	if o.MaxVersion != 0 && o.MaxVersion < minVer {
+		return fmt.Errorf("TLS max version %s is lower than min version %s", tls.VersionName(o.MaxVersion), tls.VersionName(minVer))
+	}
+	if http3 && o.MaxVersion != 0 && o.MaxVersion < tls.VersionTLS13 {
+		return errors.New("HTTP/3 requires TLS 1.3, TLS max version must not be lower than TLS 1.3")
+	}
+	if len(o.CipherSuites) > 0 && minVer != tls.VersionTLS12 {
+		return errors.New("cipher suites only apply to TLS 1.2, set MinVersion to tls.VersionTLS12")
+	}
+	for _, id := range o.CipherSuites {
+		i := slices.IndexFunc(tls.CipherSuites(), func(c *tls.CipherSuite) bool { return c.ID == id })
+		if i < 0 {
+			return fmt.Errorf("cipher suite %s is insecure or unknown", tls.CipherSuiteName(id))
+		}
+		if !slices.Contains(tls.CipherSuites()[i].SupportedVersions, tls.VersionTLS12) {
+			return fmt.Errorf("cipher suite %s is not a TLS 1.2 suite", tls.CipherSuiteName(id))
+		}
+	}
+	return nil
+}
+
+// altSvcClear 通知客户端清除缓存的替代服务 (RFC 7838)，停止通过 HTTP/3 建立新连接
+var altSvcClear = []string{"clear"}
+
 // HttpService 是一个生产级的 HTTP 服务封装。
 // 它集成了 netx (限流/保活/ReusePort) 和 cert (TLS) 以及 HTTP/3 (QUIC)。
 type HttpService struct {
@@ -26,29 +111,77 @@ type HttpService struct {
 	logger  *zerolog.Logger
 
 	// Options
-	certMgr         *cert.Manager // 如果非 nil，开启 TLS
-	maxConns        int           // 最大并发连接数 (保护)
-	readTimeout     time.Duration // 读超时时间
-	keepAlivePeriod time.Duration // keepalive 周期
-	enableReusePort bool          // 开启 SO_REUSEPORT
-	enableHttp3     bool          // 开启 HTTP/3 (QUIC)
+	certMgr         *cert.Manager  // 如果非 nil，开启 TLS
+	clientCAs       *x509.CertPool // 如果非 nil，开启 mTLS (校验客户端证书)
+	clientCADir     string         // 如果非空，从目录加载客户端 CA 并热更新 (优先于 clientCAs)
+	clientCAPool    *cert.CAPool   // clientCADir 对应的运行时 CA 池
+	maxConns        int            // 最大并发连接数 (保护)
+	readHdrTimeout  time.Duration  // 读取请求头的超时时间
+	readTimeout     time.Duration  // 读取整个请求 (含请求体) 的超时，0 表示不限制
+	writeTimeout    time.Duration  // 写响应的超时，0 表示不限制
+	idleTimeout     time.Duration  // keep-alive 空闲超时
+	maxHeaderBytes  int            // 请求头最大字节数
+	keepAlivePeriod time.Duration  // keepalive 周期
+	enableReusePort bool           // 开启 SO_REUSEPORT
+	enableHttp3     bool           // 开启 HTTP/3 (QUIC)
+	http3Optional   bool           // HTTP/3 启动失败时降级为仅 TCP，而不是整体启动失败
+	quicConfig      *quic.Config   // 用户提供的 QUIC 参数，nil 表示使用默认值
+	altSvcMaxAge    time.Duration  // Alt-Svc 的 ma (客户端缓存 HTTP/3 端点的时长)
+	slowThreshold   time.Duration  // 慢请求日志阈值，0 表示关闭
+	durationMetric  bool           // 记录 appx_http_request_duration_seconds
+	durationBuckets []float64      // 请求耗时直方图的 buckets，为空时使用 prometheus.DefBuckets
+	latencyLimit    time.Duration  // 触发 latencyHook 的耗时阈值
+	slowClientRate  float64        // 慢速客户端防护的最低写入速率 (字节/秒)，0 表示关闭
+	slowClientGrace time.Duration  // 慢速客户端防护额外容忍的时间
+	handshakeLimit  int            // 同时进行中的 TLS 握手上限，0 表示不限制
+	stopOrder       StopOrder      // HTTP/3 与 TCP 的关闭顺序
+	drainTimeout    time.Duration  // Stop 时排空存量请求的最长时间，0 表示只受 Stop ctx 限制
+	serverHeader    *string        // 非 nil 时覆盖 Server 响应头 (空字符串表示移除)
+	stripHeaders    []string       // 写出响应前移除的响应头
+	listenBacklog   int            // TCP listen backlog，0 表示使用系统默认值 (somaxconn)
+	unixSocketMode  os.FileMode    // unix:// 地址的套接字文件权限
+	connStateHooks  []func(net.Conn, http.ConnState)
+	proxyCIDRs      []string // 允许发送 PROXY 协议头的来源网段，为空表示不解析
+	latencyHook     func(*http.Request, time.Duration)
+	contextValues   map[any]any        // 附加到每个请求 Context 上的值
+	clientAuth      tls.ClientAuthType // mTLS 的客户端证书校验方式，零值表示 RequireAndVerifyClientCert
+	sampler         *RequestSampler    // 采样慢请求与出错请求，nil 表示关闭
+	tlsOptions      TLSOptions         // TLS 版本、密码套件与曲线
 
 	// Network Middlewares (Layer 4)
 	netMiddlewares []netx.Middleware    // TCP 中间件扩展
 	udpMiddlewares []netx.UDPMiddleware // UDP 中间件扩展
 
 	// Observability Config
-	o11yCfg o11y.Config
+	o11yCfg     o11y.Config
+	o11yUseName bool // 使用服务名覆盖 o11yCfg.Service
+
+	// 预先创建的监听器 (如 systemd socket activation 或测试注入)
+	preListener net.Listener
 
 	// Runtime
 	server      *http.Server
-	http3Server *http3.Server  // HTTP/3 Server
-	listener    net.Listener   // TCP Listener
+	http3Server *http3.Server // HTTP/3 Server
+	rawListener net.Listener  // 未经 netx 包装的原始 Listener (用于平滑升级传递 FD)
+	listener    net.Listener  // TCP Listener
+	pauseMu     sync.Mutex
+	paused      bool           // Pause 设置的状态，跨越 Stop/Start 保留，Start 时应用到新的闸门
+	pauseLn     *pauseListener // Pause/Resume 使用的 Accept 闸门，由 pauseMu 保护
 	udpConn     net.PacketConn // UDP Listener for QUIC
+	quicLn      *quic.EarlyListener
+	altSvc      atomic.Pointer[[]string]
+	activeConns atomic.Int64 // 当前 TCP 连接数 (含空闲的 keep-alive 连接)
 	onFatal     ErrorNotifier
 }
 
-var _ Service = (*HttpService)(nil)
+var (
+	_ Service          = (*HttpService)(nil)
+	_ Upgradable       = (*HttpService)(nil)
+	_ upgradeCommitter = (*HttpService)(nil)
+	_ HealthChecker    = (*HttpService)(nil)
+	_ Readiness        = (*HttpService)(nil)
+	_ Validator        = (*HttpService)(nil)
+)
 
 func NewHttpService(name, addr string, handler http.Handler) *HttpService {
 	return &HttpService{
@@ -56,8 +189,11 @@ func NewHttpService(name, addr string, handler http.Handler) *HttpService {
 		addr:            addr,
 		handler:         handler,
 		maxConns:        100000,          // 默认保护：10万并发
-		readTimeout:     5 * time.Second, // 默认保护：防止 Slowloris
+		readHdrTimeout:  5 * time.Second, // 默认保护：防止 Slowloris
 		keepAlivePeriod: 3 * time.Minute, // 默认 3 分钟
+		idleTimeout:     time.Minute,     // 默认 60 秒
+		maxHeaderBytes:  1 << 20,         // 默认 1MB
+		unixSocketMode:  defaultUnixSocketMode,
 	}
 }
 
@@ -90,6 +226,45 @@ func (s *HttpService) WithTLS(mgr *cert.Manager) *HttpService {
 	return s
 }
 
+// WithClientCAs 开启 mTLS：要求客户端提供证书，并使用 pool 校验。
+// 需要配合 WithTLS 使用；授权 (谁可以访问) 请在 Handler 链中使用 ClientCertAuth 或 ClientIdentityFromContext。
+func (s *HttpService) WithClientCAs(pool *x509.CertPool) *HttpService {
+	s.clientCAs = pool
+	return s
+}
+
+// WithClientAuth 开启 mTLS 并指定客户端证书的校验方式，需要配合 WithTLS 使用：
+// tls.RequireAndVerifyClientCert 要求每个客户端都提供由 pool 中的 CA 签发的证书 (与 WithClientCAs 相同)；
+// tls.VerifyClientCertIfGiven 允许不带证书的客户端连接 (如同一端口同时服务浏览器与内部服务)，但提供的证书必须可信。
+// 不校验证书的类型 (RequestClientCert、RequireAnyClientCert) 会被 Validate 拒绝。
+// pool 为 nil 时使用 WithClientCADir 的证书池。校验通过的客户端身份可通过 ClientIdentityFromContext 获取。
+func (s *HttpService) WithClientAuth(pool *x509.CertPool, authType tls.ClientAuthType) *HttpService {
+	if pool != nil {
+		s.clientCAs = pool
+	}
+	s.clientAuth = authType
+	return s
+}
+
+// WithTLSOptions 设置 TLS 版本、密码套件与曲线，合并到服务生成的 tls.Config 中。
+// 未调用时只允许 TLS 1.3；需要兼容只支持 TLS 1.2 的客户端或满足合规要求时使用：
+//
+//	svc.WithTLSOptions(appx.TLSOptions{MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}})
+//
+// QUIC 只支持 TLS 1.3，HTTP/3 连接始终要求 TLS 1.3，MinVersion 只影响 TCP 上的 HTTPS。
+func (s *HttpService) WithTLSOptions(opts TLSOptions) *HttpService {
+	s.tlsOptions = opts
+	return s
+}
+
+// WithClientCADir 与 WithClientCAs 相同，但从目录加载所有 PEM 格式的 CA，
+// 并在目录内容变化时原子地重建证书池，CA 轮换无需重启。新的握手使用新证书池，已建立的连接不受影响。
+// 同时设置 WithClientCAs 时以目录为准。
+func (s *HttpService) WithClientCADir(dir string) *HttpService {
+	s.clientCADir = dir
+	return s
+}
+
 // WithMaxConns 设置最大连接数限制
 func (s *HttpService) WithMaxConns(n int) *HttpService {
 	s.maxConns = n
@@ -109,6 +284,13 @@ func (s *HttpService) WithObservability(cfg o11y.Config) *HttpService {
 	return s
 }
 
+// WithObservabilityName 使用服务自身的 Name() 覆盖 o11y 配置中的 Service，
+// 使多服务应用中的 Trace/Metrics 按 appx 服务区分，而不是共用全局服务名。
+func (s *HttpService) WithObservabilityName() *HttpService {
+	s.o11yUseName = true
+	return s
+}
+
 // WithReusePort 启用端口复用 (SO_REUSEPORT)
 // 允许在多核机器上运行多个进程/线程监听同一端口，由内核进行负载均衡，提升 Accept 吞吐。
 func (s *HttpService) WithReusePort() *HttpService {
@@ -123,38 +305,407 @@ func (s *HttpService) WithHTTP3() *HttpService {
 	return s
 }
 
+// WithQUICConfig 设置 HTTP/3 使用的 QUIC 参数 (如 MaxIncomingStreams、InitialStreamReceiveWindow、Allow0RTT)。
+// 传入的配置会被复制，未设置 MaxIdleTimeout 时使用默认的 30 秒；
+// 注意 Allow0RTT 以传入的配置为准 (默认配置开启 0-RTT)。仅在启用 HTTP/3 时生效。
+func (s *HttpService) WithQUICConfig(cfg *quic.Config) *HttpService {
+	s.quicConfig = cfg
+	return s
+}
+
+// WithAltSvcMaxAge 设置 Alt-Svc 响应头的 ma，即客户端缓存 HTTP/3 端点的时长 (默认 30 天)。
+// 计划下线 HTTP/3 或更换 UDP 端口前可先调小该值，缩短客户端继续尝试旧端点的时间。
+func (s *HttpService) WithAltSvcMaxAge(d time.Duration) *HttpService {
+	s.altSvcMaxAge = d
+	return s
+}
+
+// WithSlowRequestLog 仅记录耗时超过 threshold 的请求 (Warn 级别)。
+// 日志包含 method/path/status/latency/trace_id，作为指标直方图的补充，
+// 可以直接定位具体的长尾请求，且日志量远小于完整的访问日志。
+func (s *HttpService) WithSlowRequestLog(threshold time.Duration) *HttpService {
+	s.slowThreshold = threshold
+	return s
+}
+
+// WithRequestSampler 将慢请求与出错请求记录到 rs，通过 rs.Handler 在监控服务上查看。
+// 多个服务可以共享同一个采样器，记录中的 service 字段区分来源。
+func (s *HttpService) WithRequestSampler(rs *RequestSampler) *HttpService {
+	s.sampler = rs
+	return s
+}
+
+// WithRequestDurationMetric 开启请求耗时直方图 appx_http_request_duration_seconds{service,code}。
+// buckets 为空时使用 prometheus.DefBuckets (5ms ~ 10s)，应根据服务的延迟分布调整。
+// 同一指标在一个进程内只有一套 buckets：多个服务配置了不同的 buckets 时以最先启动的服务为准，其余服务会记录警告。
+func (s *HttpService) WithRequestDurationMetric(buckets ...float64) *HttpService {
+	s.durationMetric = true
+	s.durationBuckets = buckets
+	return s
+}
+
+// WithLatencyHook 在请求耗时达到 threshold 时调用 fn，用于对慢请求做采样与详细捕获
+// (如推送到追踪系统、触发 profile)。fn 在请求处理完成后于请求所在的 goroutine 中同步执行，
+// 此时响应已写出，但连接尚未复用，因此不应长时间阻塞。
+func (s *HttpService) WithLatencyHook(threshold time.Duration, fn func(r *http.Request, d time.Duration)) *HttpService {
+	s.latencyLimit = threshold
+	s.latencyHook = fn
+	return s
+}
+
+// WithSlowClientGuard 开启慢速客户端防护：响应写入速率低于 minBytesPerSec (字节/秒) 的连接会被中止，
+// 并计入 appx_http_slow_client_abort_total。用于没有反向代理、直接暴露在公网的服务防御 slow-read 攻击。
+// 每次写入的超时为 grace + 数据量/minBytesPerSec，grace 默认 10 秒，可通过 WithSlowClientGrace 调整；
+// 上层显式设置了写超时的连接 (如 http.ResponseController.SetWriteDeadline) 以上层设置为准。
+func (s *HttpService) WithSlowClientGuard(minBytesPerSec float64) *HttpService {
+	s.slowClientRate = minBytesPerSec
+	return s
+}
+
+// WithSlowClientGrace 设置慢速客户端防护在吞吐量预算之外额外容忍的时间。
+// 客户端网络较差、下载大文件时偶有停顿的场景可适当调大，避免误杀。
+func (s *HttpService) WithSlowClientGrace(grace time.Duration) *HttpService {
+	s.slowClientGrace = grace
+	return s
+}
+
+// WithHandshakeLimit 限制同时进行中的 TLS 握手数量 (不影响已建立的连接)，防御握手洪峰耗尽 CPU。
+// 超出上限的连接排队等待，排队与握手共享请求头读取超时 (ReadHeaderTimeout) 的时间预算，
+// 超时仍未轮到的连接被关闭并计入 appx_http_tls_handshake_rejected_total。
+// 只作用于 TCP (HTTP/1.1 与 HTTP/2)，HTTP/3 的握手由 QUIC 协议栈处理。
+func (s *HttpService) WithHandshakeLimit(n int) *HttpService {
+	s.handshakeLimit = n
+	return s
+}
+
+// WithContextValues 将 values 附加到每个请求的 Context 上 (HTTP/1.1、HTTP/2 与 HTTP/3)，
+// 用于注入应用版本、部署 ID、区域等全局信息，日志与链路追踪可直接从 r.Context() 读取，无需编写中间件。
+// 多次调用时合并，同名 key 以后一次为准。
+//
+// 与 context.WithValue 一样，key 应使用包内未导出的自定义类型 (如 type ctxKey struct{})，
+// 不要使用 string 等内置类型，避免与其他包的 key 冲突；key 不能为 nil。
+//
+//	type versionKey struct{}
+//	svc.WithContextValues(map[any]any{versionKey{}: buildVersion})
+func (s *HttpService) WithContextValues(values map[any]any) *HttpService {
+	if s.contextValues == nil {
+		s.contextValues = make(map[any]any, len(values))
+	}
+	for k, v := range values {
+		s.contextValues[k] = v
+	}
+	return s
+}
+
+// WithStopOrder 设置 Stop 时 HTTP/3 与 TCP 服务器的关闭顺序。
+// 无论哪种顺序，Stop 都会先把 Alt-Svc 切换为 "clear"，通知客户端不再发起新的 HTTP/3 连接。
+//
+// 推荐使用 StopConcurrent：h3 → h2 回退的客户端不会在 TCP 即将关闭时集中重连到 TCP，
+// 两条通道同时发送 GOAWAY 并排空存量请求。
+func (s *HttpService) WithStopOrder(order StopOrder) *HttpService {
+	s.stopOrder = order
+	return s
+}
+
+// WithReadTimeout 设置读取整个请求 (含请求体) 的超时。默认不限制，以允许上传大文件；
+// 请求头的读取始终受单独的 ReadHeaderTimeout 保护。
+func (s *HttpService) WithReadTimeout(d time.Duration) *HttpService {
+	s.readTimeout = d
+	return s
+}
+
+// WithWriteTimeout 设置写响应的超时 (从读完请求头开始计时)。默认不限制，避免误杀大文件下载与流式响应；
+// 没有反向代理时也可以用它防御慢速读取的客户端，此时优先于 WithSlowClientGuard。
+func (s *HttpService) WithWriteTimeout(d time.Duration) *HttpService {
+	s.writeTimeout = d
+	return s
+}
+
+// WithIdleTimeout 设置 keep-alive 连接的空闲超时 (默认 60 秒)
+func (s *HttpService) WithIdleTimeout(d time.Duration) *HttpService {
+	s.idleTimeout = d
+	return s
+}
+
+// WithMaxHeaderBytes 设置请求头的最大字节数 (默认 1MB)
+func (s *HttpService) WithMaxHeaderBytes(n int) *HttpService {
+	s.maxHeaderBytes = n
+	return s
+}
+
+// WithProxyProtocol 解析 L4 负载均衡发送的 PROXY 协议头 (v1/v2)，将连接的 RemoteAddr 改写为真实客户端地址，
+// 下游的 r.RemoteAddr、限流与访问日志随之使用真实 IP。
+// 只有来自 trustedCIDRs (如 "10.0.0.0/8") 的连接才会解析 PROXY 头，其余连接按普通连接处理，防止伪造来源地址。
+func (s *HttpService) WithProxyProtocol(trustedCIDRs ...string) *HttpService {
+	s.proxyCIDRs = append(s.proxyCIDRs, trustedCIDRs...)
+	return s
+}
+
+// WithConnStateHook 注册连接状态回调，在内置的连接计数之后调用 (对应 http.Server.ConnState)。
+// 被 WithMaxConns 的连接数限制拒绝的连接不会进入 http.Server，因此也不会触发回调。
+// 回调在连接所在的 goroutine 中同步执行，不应阻塞。
+func (s *HttpService) WithConnStateHook(fn func(net.Conn, http.ConnState)) *HttpService {
+	s.connStateHooks = append(s.connStateHooks, fn)
+	return s
+}
+
+// WithDrainTimeout 设置 Stop 时排空存量请求的最长时间。
+// Stop 会立即关闭监听器 (负载均衡的健康探测随即失败，不再有新连接进入)，
+// 最多等待 d 让进行中的请求完成，之后强制关闭剩余连接，并在返回的错误与日志中报告强制关闭的连接数。
+// HTTP/3 连接的排空同样受 d 限制。Stop 的 ctx 先于 d 结束时以 ctx 为准。
+func (s *HttpService) WithDrainTimeout(d time.Duration) *HttpService {
+	s.drainTimeout = d
+	return s
+}
+
+// WithHTTP3Optional 将 HTTP/3 设为可选：UDP 监听或 QUIC 初始化失败时记录警告并以仅 TCP 模式继续启动
+// (不再下发 Alt-Svc)。默认情况下 HTTP/3 启动失败会导致整个服务启动失败。
+func (s *HttpService) WithHTTP3Optional() *HttpService {
+	s.enableHttp3 = true
+	s.http3Optional = true
+	return s
+}
+
+// WithServerHeader 设置 Server 响应头为 value，传入空字符串则移除该响应头。
+// 用于避免中间件或业务 Handler 暴露框架/版本信息。
+func (s *HttpService) WithServerHeader(value string) *HttpService {
+	s.serverHeader = &value
+	return s
+}
+
+// WithStripHeaders 在响应写出前移除指定的响应头 (如 X-Powered-By)，减少服务指纹暴露
+func (s *HttpService) WithStripHeaders(names ...string) *HttpService {
+	s.stripHeaders = append(s.stripHeaders, names...)
+	return s
+}
+
+// WithListenBacklog 设置 TCP 监听队列 (backlog) 长度，缓解突发连接下的 SYN 丢弃。
+// 仅在 Linux 下生效；超过内核上限 net.core.somaxconn 时会被截断并记录警告。
+func (s *HttpService) WithListenBacklog(n int) *HttpService {
+	s.listenBacklog = n
+	return s
+}
+
+// WithUnixSocket 改为监听 Unix Domain Socket (等同于将地址设为 "unix://" + path)，用于 sidecar 与本机 IPC。
+// 启动时会清理上次异常退出遗留的套接字文件，Stop 时删除套接字文件。
+// mode 为 0 时使用默认权限 0600。TLS、o11y 与优雅关闭照常生效；HTTP/3 与 ReusePort 不可用。
+func (s *HttpService) WithUnixSocket(path string, mode os.FileMode) *HttpService {
+	s.addr = unixScheme + path
+	if mode != 0 {
+		s.unixSocketMode = mode
+	}
+	return s
+}
+
+// WithUnixSocketMode 设置 unix:// 地址的套接字文件权限 (默认 0600，仅属主可访问)。
+// 例如需要同组的采集器访问时可设为 0660。
+func (s *HttpService) WithUnixSocketMode(mode os.FileMode) *HttpService {
+	s.unixSocketMode = mode
+	return s
+}
+
+// WithListener 使用预先创建好的监听器，而不是在 Start 时根据 addr 监听。
+// 适用于 systemd socket activation、测试注入等场景。此时 WithReusePort 不生效。
+func (s *HttpService) WithListener(ln net.Listener) *HttpService {
+	s.preListener = ln
+	return s
+}
+
 func (s *HttpService) Name() string { return s.name }
 
-func (s *HttpService) Start(ctx context.Context) error {
-	// 1. 启动 TCP 监听 (HTTP/1.1 & HTTP/2)
+// ListenerFiles 实现 Upgradable 接口，返回监听器的文件描述符副本
+func (s *HttpService) ListenerFiles() (map[string]*os.File, error) {
+	files := make(map[string]*os.File)
+	if s.rawListener != nil {
+		fl, ok := s.rawListener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, errors.New("listener does not support file descriptor export")
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, err
+		}
+		files["tcp"] = f
+	}
+	if s.udpConn != nil {
+		fl, ok := s.udpConn.(interface{ File() (*os.File, error) })
+		if ok {
+			if f, err := fl.File(); err == nil {
+				files["udp"] = f
+			}
+		}
+	}
+	return files, nil
+}
+
+// commitUpgrade 在新进程就绪后调用：套接字文件交给新进程继续使用，当前进程关闭监听器时不能删除它。
+// 升级中止时不会调用，之后正常 Stop 仍然删除套接字文件。
+func (s *HttpService) commitUpgrade() {
+	if ul, ok := s.rawListener.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+}
+
+// listen 创建 TCP 监听器。优先级: 预创建 > 父进程继承 > 新建
+func (s *HttpService) listen() (net.Listener, error) {
+	if s.preListener != nil {
+		return s.preListener, nil
+	}
+	if ln, err := inheritedListener(s.name, "tcp"); ln != nil || err != nil {
+		return ln, err
+	}
+	if path, ok := unixSocketPath(s.addr); ok {
+		return listenUnix(path, s.unixSocketMode)
+	}
 	// 使用 netx.ListenTCP 支持 ReusePort
-	ln, err := netx.ListenTCP("tcp", s.addr, netx.ListenConfig{
+	return netx.ListenTCP("tcp", s.addr, netx.ListenConfig{
 		EnableReusePort: s.enableReusePort,
 	})
+}
+
+// applyListenBacklog 调整监听队列长度，并与内核上限 somaxconn 交叉校验。
+// 调整失败不影响启动，仅记录警告。
+func (s *HttpService) applyListenBacklog(ln net.Listener) {
+	if max, ok := somaxconn(); ok && int64(s.listenBacklog) > max && s.logger != nil {
+		s.logger.Warn().
+			Str("name", s.name).
+			Int("backlog", s.listenBacklog).
+			Int64("somaxconn", max).
+			Msg("Requested listen backlog exceeds net.core.somaxconn and will be capped by the kernel")
+	}
+	if err := setListenBacklog(ln, s.listenBacklog); err != nil && s.logger != nil {
+		s.logger.Warn().Err(err).Str("name", s.name).Msg("Failed to set listen backlog")
+	}
+}
+
+// listenPacket 创建 UDP 监听器。优先级: 父进程继承 > 新建
+func (s *HttpService) listenPacket() (net.PacketConn, error) {
+	if _, ok := unixSocketPath(s.addr); ok {
+		return nil, errors.New("HTTP/3 is not supported on unix sockets")
+	}
+	if pc, err := inheritedPacketConn(s.name, "udp"); pc != nil || err != nil {
+		return pc, err
+	}
+	return netx.ListenUDP("udp", s.addr, netx.ListenConfig{
+		EnableReusePort: s.enableReusePort,
+	})
+}
+
+// Validate 实现 Validator，在不监听端口的情况下检查配置组合是否合法
+func (s *HttpService) Validate(ctx context.Context) error {
+	if s.certMgr == nil {
+		if s.enableHttp3 {
+			return errors.New("HTTP/3 requires TLS, please call WithTLS()")
+		}
+		if s.clientCAs != nil || s.clientCADir != "" {
+			return errors.New("client certificate verification requires TLS, please call WithTLS()")
+		}
+		if s.handshakeLimit > 0 {
+			return errors.New("TLS handshake limit requires TLS, please call WithTLS()")
+		}
+		if !s.tlsOptions.isZero() {
+			return errors.New("TLS options require TLS, please call WithTLS()")
+		}
+	}
+	if s.handshakeLimit < 0 {
+		return fmt.Errorf("invalid TLS handshake limit %d", s.handshakeLimit)
+	}
+	if err := s.tlsOptions.validate(s.enableHttp3); err != nil {
+		return err
+	}
+	switch s.clientAuth {
+	case tls.NoClientCert, tls.RequireAndVerifyClientCert, tls.VerifyClientCertIfGiven:
+		if s.clientAuth != tls.NoClientCert && s.clientCAs == nil && s.clientCADir == "" {
+			return errors.New("client certificate verification requires a CA pool, please call WithClientAuth with a pool or WithClientCADir")
+		}
+	default:
+		return fmt.Errorf("client auth type %s does not verify client certificates, use RequireAndVerifyClientCert or VerifyClientCertIfGiven", s.clientAuth)
+	}
+	if _, ok := s.contextValues[nil]; ok {
+		return errors.New("context value key must not be nil")
+	}
+	for _, cidr := range s.proxyCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid PROXY protocol trusted CIDR %q: %w", cidr, err)
+		}
+	}
+	if s.preListener == nil {
+		if _, ok := unixSocketPath(s.addr); ok {
+			// WithHTTP3Optional 在 unix 地址上降级为仅 TCP，不视为错误
+			if s.enableHttp3 && !s.http3Optional {
+				return errors.New("HTTP/3 is not supported on unix sockets")
+			}
+			if s.enableReusePort {
+				return errors.New("ReusePort is not supported on unix sockets")
+			}
+		} else if _, _, err := net.SplitHostPort(s.addr); err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", s.addr, err)
+		}
+	}
+	return nil
+}
+
+func (s *HttpService) Start(ctx context.Context) error {
+	// 0. 先校验配置，避免监听之后才发现配置错误
+	if err := s.Validate(ctx); err != nil {
+		return err
+	}
+	if s.readHdrTimeout <= 0 {
+		s.readHdrTimeout = 30 * time.Second // 给 Header 读取充足的时间
+	}
+	if s.logger == nil {
+		// 未调用 WithLogger 时回退到全局 Logger，避免 Recovery 等钩子解引用 nil
+		s.logger = &log.Logger
+	}
+
+	// 1. 启动 TCP 监听 (HTTP/1.1 & HTTP/2)
+	ln, err := s.listen()
 	if err != nil {
 		return err
 	}
+	s.rawListener = ln
+	if s.listenBacklog > 0 {
+		s.applyListenBacklog(ln)
+	}
 	s.listener = ln
 
 	// 2. 启动 UDP 监听 (HTTP/3)
 	var pc net.PacketConn
 	if s.enableHttp3 {
-		pc, err = netx.ListenUDP("udp", s.addr, netx.ListenConfig{
-			EnableReusePort: s.enableReusePort,
-		})
+		pc, err = s.listenPacket()
 		if err != nil {
-			ln.Close()
-			return err
+			if !s.http3Optional {
+				ln.Close()
+				return err
+			}
+			s.disableHTTP3(err)
+			pc = nil
 		}
 		s.udpConn = pc
 	}
 
+	// Accept 闸门位于最内层，暂停期间不从内核队列取出新连接；Start 之前调用的 Pause 在此生效
+	pauseLn := newPauseListener(ln)
+	s.pauseMu.Lock()
+	if s.paused {
+		pauseLn.pause()
+	}
+	s.pauseLn = pauseLn
+	s.pauseMu.Unlock()
+	ln = pauseLn
+
 	// 3. [netx] 构建 TCP 网络层增强链
-	// 默认基础链：KeepAlive -> User Custom -> Context -> Limit
+	// 默认基础链：KeepAlive -> [SlowClientGuard] -> [ProxyProtocol] -> User Custom -> Context -> Limit
 	// 这样用户的中间件可以在 Context 绑定之前运行 (例如 Proxy Protocol)，也可以在 Limit 之前运行 (例如 IP 黑名单)
 	chain := []netx.Middleware{
 		netx.WithKeepAlive(s.keepAlivePeriod),
 	}
+	if s.slowClientRate > 0 {
+		chain = append(chain, slowClientGuard(s.name, s.slowClientRate, orDefault(s.slowClientGrace, defaultSlowClientGrace)))
+	}
+	if len(s.proxyCIDRs) > 0 {
+		chain = append(chain, netx.WithProxyProtocol(s.proxyCIDRs))
+	}
 	// 注入用户自定义中间件
 	chain = append(chain, s.netMiddlewares...)
 	// 注入核心生命周期与保护中间件
@@ -181,64 +732,143 @@ func (s *HttpService) Start(ctx context.Context) error {
 	if s.certMgr != nil {
 		protocol = "HTTPS"
 		if err := s.certMgr.Start(ctx); err != nil {
+			ln.Close()
+			s.closeUDP()
 			return err
 		}
+		// quic-go 会将 QUIC 连接的最低版本强制为 TLS 1.3，MinVersion 只对 TCP 生效
 		tlsConfig = &tls.Config{
-			GetCertificate: s.certMgr.GetCertificate, // 无锁化获取
-			MinVersion:     tls.VersionTLS13,
-			NextProtos:     []string{"h3", "h2", "http/1.1"}, // 增加 h3 协商
+			GetCertificate:   s.certMgr.GetCertificate, // 无锁化获取
+			MinVersion:       s.tlsOptions.minVersion(),
+			MaxVersion:       s.tlsOptions.MaxVersion,
+			CipherSuites:     slices.Clone(s.tlsOptions.CipherSuites),
+			CurvePreferences: slices.Clone(s.tlsOptions.CurvePreferences),
+			NextProtos:       []string{"h3", "h2", "http/1.1"}, // 增加 h3 协商
+		}
+		if s.clientCADir != "" {
+			pool, err := cert.NewCAPool(s.clientCADir, s.logger)
+			if err != nil {
+				ln.Close()
+				s.closeUDP()
+				return err
+			}
+			pool.Start(ctx)
+			s.clientCAPool = pool
+			tlsConfig.ClientAuth = s.clientAuthType()
+			// 每次握手使用当前的证书池
+			base := tlsConfig
+			tlsConfig = base.Clone()
+			tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				c := base.Clone()
+				c.ClientCAs = pool.Pool()
+				return c, nil
+			}
+		} else if s.clientCAs != nil {
+			tlsConfig.ClientCAs = s.clientCAs
+			tlsConfig.ClientAuth = s.clientAuthType()
 		}
 
 		// 绑定 TLS
 		ln = tls.NewListener(ln, tlsConfig)
-	} else if s.enableHttp3 {
-		return errors.New("HTTP/3 requires TLS, please call WithTLS()")
+		if s.handshakeLimit > 0 {
+			ln = newHandshakeLimitListener(ln, s.name, s.handshakeLimit, s.readHdrTimeout)
+		}
+	}
+
+	// 同步建立 QUIC 监听，在启动阶段暴露 HTTP/3 的初始化错误，
+	// 避免 TCP 正常服务而 HTTP/3 在后台失败、随后又通过 onFatal 拖垮整个应用
+	if pc != nil {
+		s.quicLn, err = quic.ListenEarly(pc, http3.ConfigureTLSConfig(tlsConfig), s.buildQUICConfig())
+		if err != nil {
+			s.closeUDP()
+			if !s.http3Optional {
+				ln.Close()
+				return fmt.Errorf("HTTP/3 listen failed: %w", err)
+			}
+			s.disableHTTP3(err)
+			s.quicLn = nil
+		}
 	}
 
 	// 4. 准备 Handler 链
-	// 顺序: Alt-Svc (注入头) -> o11y (监控/日志) -> 业务 Handler
+	// 顺序: Alt-Svc (注入头) -> o11y (监控/日志) -> 慢请求日志 -> 业务 Handler
+	// 包装 ResponseWriter 的中间件统一使用 httpsnoop，以保留 Flusher/Hijacker/Pusher/io.ReaderFrom
+	// 等可选接口 (SSE、WebSocket、gRPC-web 依赖它们)，不要使用自定义的结构体包装。
 	handler := s.handler
+	if tlsConfig != nil && tlsConfig.ClientAuth != tls.NoClientCert {
+		handler = clientIdentityMiddleware(handler)
+	}
+
+	// 慢请求日志与请求采样位于 o11y 内层，以便获取 trace_id
+	if s.sampler != nil {
+		handler = s.sampler.middleware(s.name, handler)
+	}
+	if s.slowThreshold > 0 && s.logger != nil {
+		handler = s.slowRequestMiddleware(handler)
+	}
+	if s.durationMetric || s.latencyHook != nil {
+		handler = s.latencyMiddleware(handler)
+	}
 
 	// 如果启用了 o11y，自动包裹中间件
 	if s.o11yCfg.Enabled {
 		// o11y.Handler 包含了 Trace, Metrics, Panic Recovery 和 Logger Injection
-		handler = o11y.Handler(s.o11yCfg)(handler)
+		wrapped, err := s.wrapObservability(handler)
+		if err != nil {
+			// 降级：可观测性不可用时仍然对外提供服务
+			if s.logger != nil {
+				s.logger.Error().Err(err).Str("name", s.name).
+					Msg("Observability middleware init failed, degraded to basic recovery")
+			}
+			handler = s.recoveryMiddleware(handler)
+		} else {
+			handler = wrapped
+		}
 	} else {
 		// 即使没有 o11y，也添加一个基础 Recovery
-		handler = httpx.Recovery(httpx.WithHook(func(ctx context.Context, err error) {
-			s.logger.Error().Err(err).Msg("Panic recovered")
-		}))(handler)
+		handler = s.recoveryMiddleware(handler)
 	}
 
 	// 通过中间件注入 Alt-Svc 头
-	if s.enableHttp3 && pc != nil {
+	if s.quicLn != nil {
 		// 预先计算 Alt-Svc 头部的值，避免在中间件热路径中调用有锁的 SetQUICHeaders
 		_, portStr, err := net.SplitHostPort(pc.LocalAddr().String())
 		if err == nil {
-			altSvcSlice := []string{`h3=":` + portStr + `"; ma=2592000`}
-			handler = s.altSvcMiddleware(handler, altSvcSlice)
+			maxAge := orDefault(s.altSvcMaxAge, defaultAltSvcMaxAge)
+			altSvcSlice := []string{`h3=":` + portStr + `"; ma=` + strconv.FormatInt(int64(maxAge/time.Second), 10)}
+			s.altSvc.Store(&altSvcSlice)
+			handler = s.altSvcMiddleware(handler)
 		}
 	}
 
+	// 响应头加固位于最外层，确保能处理内层所有中间件设置的响应头
+	if s.serverHeader != nil || len(s.stripHeaders) > 0 {
+		handler = s.responseHeaderMiddleware(handler)
+	}
+
 	// 5. 启动 HTTP/3 监听 (QUIC over UDP)
-	if s.enableHttp3 && tlsConfig != nil {
+	if s.quicLn != nil {
+		quicLn := s.quicLn
 		s.http3Server = &http3.Server{
 			Handler:   handler,
 			TLSConfig: tlsConfig,
-			QUICConfig: &quic.Config{
-				MaxIdleTimeout: 30 * time.Second,
-			},
+		}
+		if len(s.contextValues) > 0 {
+			s.http3Server.ConnContext = func(ctx context.Context, _ *quic.Conn) context.Context {
+				return valuesContext{Context: ctx, values: s.contextValues}
+			}
 		}
 
 		// 异步启动 HTTP/3 Server
 		go func() {
 			// 防止 QUIC 协程崩溃导致进程退出
-			defer handlePanic(s.logger, s.onFatal)
+			defer handlePanic(s.logger, s.name, s.onFatal)
 
 			printServiceListening(s.logger, s.name, "HTTP/3 (QUIC)", pc.LocalAddr().String())
 
-			// Serve 使用现有的 udpConn (ReusePort)
-			if err := s.http3Server.Serve(pc); err != nil && !errors.Is(err, quic.ErrServerClosed) {
+			// ServeListener 使用启动阶段已建立的 QUIC 监听 (基于 udpConn, 支持 ReusePort)，暂停期间拒绝新连接
+			err := s.http3Server.ServeListener(&pauseQUICListener{EarlyListener: quicLn, paused: s.Paused})
+			if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, quic.ErrServerClosed) {
 				if s.logger != nil {
 					s.logger.Error().Err(err).Msg("HTTP/3 service error")
 				}
@@ -251,21 +881,26 @@ func (s *HttpService) Start(ctx context.Context) error {
 	}
 
 	// 6. 启动 HTTP Server (TCP)
-	if s.readTimeout <= 0 {
-		s.readTimeout = 30 * time.Second // 给 Header 读取充足的时间
+	var base context.Context = context.WithoutCancel(ctx)
+	if len(s.contextValues) > 0 {
+		base = valuesContext{Context: base, values: s.contextValues}
 	}
 	s.server = &http.Server{
 		Handler:           handler,
-		MaxHeaderBytes:    1 << 20, // 1MB
-		ReadHeaderTimeout: s.readTimeout,
-		ReadTimeout:       0, // 设为 0，允许上传大文件
-		WriteTimeout:      0, // 防御慢速客户端由操作系统的 TCP 缓冲区管理或反向代理层处理更合适
-		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    s.maxHeaderBytes,
+		ReadHeaderTimeout: s.readHdrTimeout,
+		ReadTimeout:       s.readTimeout,  // 默认为 0，允许上传大文件
+		WriteTimeout:      s.writeTimeout, // 默认为 0，慢速客户端按吞吐量防御 (WithSlowClientGuard)，固定的写超时会误杀大文件下载
+		IdleTimeout:       s.idleTimeout,
+		ConnState:         s.trackConnState,
+		// 请求 Context 继承 Start ctx 中的值 (如 appx.ShuttingDown 依赖的关闭状态) 与 WithContextValues 的值，
+		// 但不继承其取消，避免应用开始关闭时中断仍在排空的请求
+		BaseContext: func(net.Listener) context.Context { return base },
 	}
 
 	go func() {
 		// 使用统一的 Panic 处理机制
-		defer handlePanic(s.logger, s.onFatal)
+		defer handlePanic(s.logger, s.name, s.onFatal)
 
 		// 打印启动信息
 		printServiceListening(s.logger, s.name, protocol, ln.Addr().String())
@@ -285,34 +920,373 @@ func (s *HttpService) Start(ctx context.Context) error {
 }
 
 func (s *HttpService) Stop(ctx context.Context) error {
+	// 1. 通知客户端停止使用 HTTP/3，后续响应都会携带 Alt-Svc: clear
+	if s.http3Server != nil {
+		s.altSvc.Store(&altSvcClear)
+	}
+
+	// 2. 按配置的顺序关闭 HTTP/3 与 TCP
 	var errs []error
+	switch s.stopOrder {
+	case StopTCPFirst:
+		errs = append(errs, s.stopTCP(ctx), s.stopHTTP3(ctx))
+	case StopConcurrent:
+		h3Err := make(chan error, 1)
+		go func() { h3Err <- s.stopHTTP3(ctx) }()
+		errs = append(errs, s.stopTCP(ctx), <-h3Err)
+	default:
+		errs = append(errs, s.stopHTTP3(ctx), s.stopTCP(ctx))
+	}
 
-	// 1. 关闭 HTTP/3 (如果存在)
-	if s.http3Server != nil {
-		// http3.Server 目前(quic-go v0.3x) Close 通常会关闭 PacketConn
-		if err := s.http3Server.Close(); err != nil {
-			errs = append(errs, err)
-		}
+	return errors.Join(errs...)
+}
+
+// Pause 暂停接收新连接，已建立的连接不受影响，可用于手动降载或故障演练。
+// 新的 TCP 连接留在内核队列中等待恢复；HTTP/3 (QUIC) 的新连接握手后被立即关闭，客户端回退到 TCP。
+// 暂停期间 Ready 返回 false，/readyz 随之失败，负载均衡会将流量切走 (无需额外注册健康检查)。
+// Start 之前调用时服务启动后即处于暂停状态，暂停状态在 Stop 后再次 Start 时保留。
+func (s *HttpService) Pause() {
+	s.pauseMu.Lock()
+	changed := !s.paused
+	s.paused = true
+	if s.pauseLn != nil {
+		s.pauseLn.pause()
+	}
+	s.pauseMu.Unlock()
+	if changed && s.logger != nil {
+		s.logger.Warn().Str("name", s.name).Msg("HTTP service paused, not accepting new connections")
+	}
+}
+
+// Resume 恢复接收新连接
+func (s *HttpService) Resume() {
+	s.pauseMu.Lock()
+	changed := s.paused
+	s.paused = false
+	if s.pauseLn != nil {
+		s.pauseLn.unpause()
+	}
+	s.pauseMu.Unlock()
+	if changed && s.logger != nil {
+		s.logger.Info().Str("name", s.name).Msg("HTTP service resumed")
 	}
+}
+
+// Paused 返回服务当前是否处于暂停状态
+func (s *HttpService) Paused() bool {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	return s.paused
+}
 
-	// 2. 关闭 TCP Server
-	if s.server != nil {
-		if err := s.server.Shutdown(ctx); err != nil {
-			errs = append(errs, err)
+// Ready 实现 Readiness，暂停期间返回 false
+func (s *HttpService) Ready(ctx context.Context) bool {
+	return !s.Paused()
+}
+
+// Check 实现 HealthChecker，暂停期间返回错误
+func (s *HttpService) Check(ctx context.Context) error {
+	if s.Paused() {
+		return errors.New("paused, not accepting new connections")
+	}
+	return nil
+}
+
+// stopHTTP3 优雅关闭 HTTP/3 服务器：
+// 向已建立的连接发送 GOAWAY，客户端不再在其上发起新请求，进行中的请求继续完成后连接以 H3_NO_ERROR 关闭；
+// 排空期间新建立的 QUIC 连接被立即关闭，客户端马上回退到 TCP。
+// 排空受 ctx 与 WithDrainTimeout 限制，超时后强制关闭剩余连接。
+func (s *HttpService) stopHTTP3(ctx context.Context) error {
+	if s.http3Server == nil {
+		return nil
+	}
+
+	var refuseDone chan struct{}
+	refuseCtx, stopRefuse := context.WithCancel(context.Background())
+	if s.quicLn != nil {
+		refuseDone = make(chan struct{})
+		go func() {
+			defer close(refuseDone)
+			s.refuseQUIC(refuseCtx)
+		}()
+	}
+
+	if s.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.drainTimeout)
+		defer cancel()
+	}
+	err := s.http3Server.Shutdown(ctx)
+	if err != nil {
+		err = errors.Join(err, s.http3Server.Close())
+	}
+
+	// http3.Server 不会关闭通过 ServeListener 传入的 QUIC 监听，需要手动释放
+	stopRefuse()
+	if s.quicLn != nil {
+		if cerr := s.quicLn.Close(); cerr != nil && !errors.Is(cerr, net.ErrClosed) && !errors.Is(cerr, quic.ErrServerClosed) {
+			err = errors.Join(err, cerr)
 		}
+		<-refuseDone
+		s.quicLn = nil
 	}
+	return errors.Join(err, s.closeUDP())
+}
 
-	if len(errs) > 0 {
-		return errors.Join(errs...)
+// closeUDP 关闭 HTTP/3 使用的 PacketConn，只关闭一次。
+// PacketConn 始终归 HttpService 所有：quic.ListenEarly 基于外部传入的 PacketConn 建立监听时不接管其所有权，
+// 关闭 QUIC 监听或 http3.Server 都不会关闭它，因此必须在二者关闭之后由这里释放；已被关闭的错误视为成功。
+func (s *HttpService) closeUDP() error {
+	pc := s.udpConn
+	if pc == nil {
+		return nil
+	}
+	s.udpConn = nil
+	if err := pc.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
 	}
 	return nil
 }
 
+// refuseQUIC 在 HTTP/3 排空期间接收新的 QUIC 连接并立即关闭。
+// 直接关闭 QUIC 监听时，新连接的握手包会被丢弃，客户端要等到握手超时才会回退到 TCP。
+func (s *HttpService) refuseQUIC(ctx context.Context) {
+	for {
+		conn, err := s.quicLn.Accept(ctx)
+		if err != nil {
+			return
+		}
+		conn.CloseWithError(quic.ApplicationErrorCode(http3.ErrCodeNoError), "server shutting down")
+	}
+}
+
+// disableHTTP3 在 HTTP/3 可选时记录启动失败并降级为仅 TCP
+func (s *HttpService) disableHTTP3(err error) {
+	if s.logger != nil {
+		s.logger.Warn().Err(err).Str("name", s.name).Msg("HTTP/3 unavailable, continuing with TCP only")
+	}
+}
+
+// stopTCP 优雅关闭 TCP 服务器，ctx 超时后强制关闭剩余连接
+func (s *HttpService) stopTCP(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+
+	// Shutdown 立即关闭监听器，随后等待进行中的请求完成
+	if s.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.drainTimeout)
+		defer cancel()
+	}
+	err := s.server.Shutdown(ctx)
+	if err != nil && ctx.Err() != nil {
+		// 仍有连接未能在超时前结束，强制关闭并计入丢弃指标
+		n := s.activeConns.Load()
+		if n > 0 {
+			shutdownDroppedTotal.WithLabelValues(droppedConnection).Add(float64(n))
+			if s.logger != nil {
+				s.logger.Warn().Int64("connections", n).Str("name", s.name).Msg("Forcibly closing connections after shutdown timeout")
+			}
+		}
+		s.server.Close()
+		if n > 0 {
+			err = fmt.Errorf("force-closed %d connection(s) after drain timeout: %w", n, err)
+		}
+	}
+	return err
+}
+
+// ActiveConns 返回当前的 TCP 连接数 (含空闲的 keep-alive 连接，不含已被 Hijack 的连接)，
+// 可用于导出连接数指标或排查连接泄漏。HTTP/3 连接不计入。
+func (s *HttpService) ActiveConns() int {
+	return int(s.activeConns.Load())
+}
+
+// trackConnState 统计当前连接数，并转发给 WithConnStateHook 注册的回调
+func (s *HttpService) trackConnState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.activeConns.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		s.activeConns.Add(-1)
+	}
+	for _, fn := range s.connStateHooks {
+		fn(conn, state)
+	}
+}
+
+// o11yHandler 便于在测试中替换 o11y 中间件的构造
+var o11yHandler = o11y.Handler
+
+// wrapObservability 构造 o11y 中间件，构造过程中的 panic (如配置异常) 会被转换为 error
+func (s *HttpService) wrapObservability(next http.Handler) (h http.Handler, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("o11y handler panic: %v", r)
+		}
+	}()
+	cfg := s.o11yCfg
+	if s.o11yUseName {
+		cfg.Service = s.name
+	}
+	return o11yHandler(cfg)(next), nil
+}
+
+// recoveryMiddleware 返回基础的 Panic Recovery 中间件。
+// panic 只影响当前请求：HTTP/2、HTTP/3 下同一连接上的其他流照常完成，连接保持打开。
+// http.ErrAbortHandler 是中止当前请求的约定信号 (如流式响应中途放弃)，会被重新抛出交给 net/http，
+// 由其只重置当前流 (HTTP/1.1 下关闭当前连接)，而不是在已写出一半的响应后追加错误内容。
+func (s *HttpService) recoveryMiddleware(next http.Handler) http.Handler {
+	hook := httpx.WithHook(func(ctx context.Context, err error) {
+		s.logger.Error().Err(err).Msg("Panic recovered")
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			val := recover()
+			if val == nil {
+				return
+			}
+			if val == http.ErrAbortHandler {
+				panic(val)
+			}
+			err, ok := val.(error)
+			if !ok {
+				err = fmt.Errorf("panic: %v", val)
+			}
+			httpx.Error(w, r, err, hook)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// slowRequestMiddleware 返回一个中间件，仅在请求耗时超过阈值时记录日志
+func (s *HttpService) slowRequestMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := httpsnoop.CaptureMetrics(next, w, r)
+		if m.Duration < s.slowThreshold {
+			return
+		}
+		s.logger.Warn().
+			Str("name", s.name).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", m.Code).
+			Dur("latency", m.Duration).
+			Str("trace_id", o11y.GetTraceID(r.Context())).
+			Msg("Slow request")
+	})
+}
+
+// responseHeaderMiddleware 返回一个中间件，在响应头写出前覆盖 Server 头并移除指定的响应头
+func (s *HttpService) responseHeaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		applied := false
+		apply := func() {
+			if applied {
+				return
+			}
+			applied = true
+			h := w.Header()
+			for _, name := range s.stripHeaders {
+				h.Del(name)
+			}
+			if s.serverHeader != nil {
+				if *s.serverHeader == "" {
+					h.Del("Server")
+				} else {
+					h.Set("Server", *s.serverHeader)
+				}
+			}
+		}
+
+		ww := httpsnoop.Wrap(w, httpsnoop.Hooks{
+			WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+				return func(code int) {
+					apply()
+					next(code)
+				}
+			},
+			Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+				return func(b []byte) (int, error) {
+					apply()
+					return next(b)
+				}
+			},
+			ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+				return func(src io.Reader) (int64, error) {
+					apply()
+					return next(src)
+				}
+			},
+			Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+				return func() {
+					apply()
+					next()
+				}
+			},
+		})
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// latencyMiddleware 返回一个中间件，记录请求耗时直方图并在超过阈值时调用 latencyHook
+func (s *HttpService) latencyMiddleware(next http.Handler) http.Handler {
+	var observer prometheus.ObserverVec
+	if s.durationMetric {
+		h, matched := requestDurationHistogram(s.durationBuckets)
+		if !matched && s.logger != nil {
+			s.logger.Warn().Str("name", s.name).
+				Msg("appx_http_request_duration_seconds already registered with different buckets, ignoring configured buckets")
+		}
+		observer = h.MustCurryWith(prometheus.Labels{"service": s.name})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := httpsnoop.CaptureMetrics(next, w, r)
+		if observer != nil {
+			observer.WithLabelValues(strconv.Itoa(m.Code)).Observe(m.Duration.Seconds())
+		}
+		if s.latencyHook != nil && m.Duration >= s.latencyLimit {
+			s.latencyHook(r, m.Duration)
+		}
+	})
+}
+
 // altSvcMiddleware 返回一个中间件，用于在响应头中注入 Alt-Svc
-func (s *HttpService) altSvcMiddleware(next http.Handler, altSvcSlice []string) http.Handler {
+func (s *HttpService) altSvcMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 直接通过预计算的切片注入，避免 http3.Server.SetQUICHeaders 中的 mutex RLock 导致的高并发性能瓶颈
-		w.Header()["Alt-Svc"] = altSvcSlice
+		// 使用原子指针以便在关闭时无锁切换为 "clear"
+		w.Header()["Alt-Svc"] = *s.altSvc.Load()
 		next.ServeHTTP(w, r)
 	})
 }
+
+// buildQUICConfig 返回 HTTP/3 监听使用的 QUIC 配置，用户配置会被复制以免 Start 修改调用方的对象
+func (s *HttpService) buildQUICConfig() *quic.Config {
+	if s.quicConfig == nil {
+		return &quic.Config{
+			MaxIdleTimeout: defaultQUICIdleTimeout,
+			Allow0RTT:      true,
+		}
+	}
+	cfg := s.quicConfig.Clone()
+	if cfg.MaxIdleTimeout <= 0 {
+		cfg.MaxIdleTimeout = defaultQUICIdleTimeout
+	}
+	return cfg
+}
+
+// valuesContext 将 WithContextValues 的值附加到 Context 上，只需一次分配，查找时先查 values 再交给父 Context
+type valuesContext struct {
+	context.Context
+	values map[any]any
+}
+
+func (c valuesContext) Value(key any) any {
+	if v, ok := c.values[key]; ok {
+		return v
+	}
+	return c.Context.Value(key)
+}
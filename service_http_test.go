@@ -1,6 +1,8 @@
 package appx
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
@@ -13,16 +15,27 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/bytedance/sonic"
 	"github.com/oy3o/appx/cert"
+	"github.com/oy3o/o11y"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // generateTempCert 辅助生成测试用的自签名证书
@@ -150,3 +163,1201 @@ func TestHttpService_Integration_H3(t *testing.T) {
 	default:
 	}
 }
+
+func TestHttpService_O11yFallback(t *testing.T) {
+	// 模拟异常配置导致 o11y 中间件构造时 panic
+	orig := o11yHandler
+	o11yHandler = func(cfg o11y.Config) func(http.Handler) http.Handler {
+		if cfg.Service == "" {
+			panic("o11y: service name is required")
+		}
+		return orig(cfg)
+	}
+	defer func() { o11yHandler = orig }()
+
+	logger := zerolog.Nop()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("degraded but alive"))
+	})
+	svc := NewHttpService("o11y-fallback", "127.0.0.1:0", handler).
+		WithLogger(&logger).
+		WithObservability(o11y.Config{Enabled: true})
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	resp, err := http.Get("http://" + svc.listener.Addr().String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "degraded but alive", string(body))
+}
+
+func TestHttpService_ObservabilityName(t *testing.T) {
+	var got []string
+	orig := o11yHandler
+	o11yHandler = func(cfg o11y.Config) func(http.Handler) http.Handler {
+		got = append(got, cfg.Service)
+		return func(next http.Handler) http.Handler { return next }
+	}
+	defer func() { o11yHandler = orig }()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cfg := o11y.Config{Enabled: true, Service: "global"}
+
+	_, err := NewHttpService("public-api", ":0", handler).
+		WithObservability(cfg).
+		wrapObservability(handler)
+	require.NoError(t, err)
+
+	_, err = NewHttpService("public-api", ":0", handler).
+		WithObservability(cfg).
+		WithObservabilityName().
+		wrapObservability(handler)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"global", "public-api"}, got)
+	assert.Equal(t, "global", cfg.Service, "原始配置不应被修改")
+}
+
+func TestHttpService_NilLoggerRecovery(t *testing.T) {
+	// 未调用 WithLogger 的服务在恢复 panic 时不应因 nil logger 崩溃
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	svc := NewHttpService("no-logger", "127.0.0.1:0", handler)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	resp, err := http.Get("http://" + svc.listener.Addr().String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	// 服务在恢复后仍可继续处理请求
+	resp, err = http.Get("http://" + svc.listener.Addr().String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestHttpService_PanicIsolationHTTP2(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/panic":
+			panic("boom")
+		case "/abort":
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	})
+	logger := zerolog.Nop()
+	svc := NewHttpService("h2-panic", "127.0.0.1:0", handler).WithTLS(certMgr).WithLogger(&logger)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	defer client.CloseIdleConnections()
+	base := "https://" + svc.listener.Addr().String()
+
+	var connMu sync.Mutex
+	conns := map[net.Conn]bool{}
+	get := func(path string) (*http.Response, string, error) {
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+			connMu.Lock()
+			conns[info.Conn] = true
+			connMu.Unlock()
+		}}
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", base+path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, string(body), err
+	}
+
+	// 先建立 HTTP/2 连接，后续请求复用同一连接
+	resp, _, err := get("/")
+	require.NoError(t, err)
+	require.Equal(t, 2, resp.ProtoMajor)
+
+	var wg sync.WaitGroup
+	slow := make([]string, 4)
+	for i := range slow {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, body, err := get("/slow")
+			if err != nil {
+				body = err.Error()
+			}
+			slow[i] = body
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	resp, _, err = get("/panic")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	_, _, err = get("/abort")
+	assert.Error(t, err, "aborted stream should be reset")
+
+	// 同一连接上的其他流不受影响，连接保持打开
+	wg.Wait()
+	assert.Equal(t, []string{"ok", "ok", "ok", "ok"}, slow)
+	resp, _, err = get("/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, conns, 1)
+}
+
+func TestHttpService_SlowRequestLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	svc := NewHttpService("slow", ":0", nil).WithLogger(&logger).WithSlowRequestLog(20 * time.Millisecond)
+
+	handler := svc.slowRequestMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast", nil))
+	assert.Empty(t, buf.String())
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	assert.Contains(t, buf.String(), `"level":"warn"`)
+	assert.Contains(t, buf.String(), `"path":"/slow"`)
+	assert.Contains(t, buf.String(), `"status":202`)
+	assert.Contains(t, buf.String(), `"trace_id"`)
+}
+
+func TestHttpService_RequestSampler(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(30 * time.Millisecond)
+		case "/error":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/panic":
+			panic("boom")
+		}
+	})
+	rs := NewRequestSampler(2, 20*time.Millisecond)
+	svc := NewHttpService("sampler", "127.0.0.1:0", handler).WithRequestSampler(rs)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	for _, path := range []string{"/fast", "/slow?token=secret", "/fast", "/error"} {
+		resp, err := http.Get("http://" + svc.listener.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	traces := rs.Traces()
+	require.Len(t, traces, 2)
+	assert.Equal(t, "sampler", traces[0].Service)
+	assert.Equal(t, "/slow", traces[0].Path, "query string is not recorded")
+	assert.Equal(t, http.StatusOK, traces[0].Status)
+	assert.GreaterOrEqual(t, traces[0].Duration, 20*time.Millisecond)
+	assert.Equal(t, "/error", traces[1].Path)
+	assert.Equal(t, http.StatusBadGateway, traces[1].Status)
+
+	// 超出容量后覆盖最旧的记录；panic 计为 500 并继续交给 recovery
+	resp, err := http.Get("http://" + svc.listener.Addr().String() + "/panic")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+	rec := httptest.NewRecorder()
+	rs.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/requests", nil))
+	var got []RequestTrace
+	require.NoError(t, sonic.Unmarshal(rec.Body.Bytes(), &got))
+	require.Len(t, got, 2)
+	assert.Equal(t, "/error", got[0].Path)
+	assert.Equal(t, "/panic", got[1].Path)
+	assert.Equal(t, http.StatusInternalServerError, got[1].Status)
+	assert.Equal(t, "boom", got[1].Error)
+}
+
+func TestRequestSampler_Spans(t *testing.T) {
+	rs := NewRequestSampler(10, 0)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rs))
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	handler := rs.middleware("spans", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracer.Start(r.Context(), "db.query")
+		span.End()
+		_, span = tracer.Start(r.Context(), "downstream")
+		span.SetStatus(codes.Error, "timeout")
+		span.End()
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	serve := func(path string) {
+		ctx, root := tracer.Start(context.Background(), "request")
+		defer root.End()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil).WithContext(ctx))
+	}
+
+	serve("/ok")
+	serve("/error")
+
+	traces := rs.Traces()
+	require.Len(t, traces, 1, "threshold 0 samples errors only")
+	assert.Equal(t, "/error", traces[0].Path)
+	assert.NotEmpty(t, traces[0].TraceID)
+	require.Len(t, traces[0].Spans, 2)
+	assert.Equal(t, "db.query", traces[0].Spans[0].Name)
+	assert.Equal(t, "downstream", traces[0].Spans[1].Name)
+	assert.Equal(t, "timeout", traces[0].Spans[1].Error)
+
+	// 未采样请求的 Span 同样被取走，不会残留
+	rs.mu.Lock()
+	assert.Empty(t, rs.pending)
+	rs.mu.Unlock()
+}
+
+func TestHttpService_StopClearsAltSvc(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		w.Write([]byte("done"))
+	})
+
+	// 默认顺序：先排空 HTTP/3，期间 TCP 仍在服务
+	svc := NewHttpService("h3-stop", "127.0.0.1:0", handler).
+		WithTLS(certMgr).
+		WithHTTP3().
+		WithLogger(&zerolog.Logger{})
+	require.NoError(t, svc.Start(context.Background()))
+	addr := svc.listener.Addr().String()
+	udpAddr := svc.udpConn.LocalAddr().String()
+
+	// 1. 通过 HTTP/3 发起一个慢请求，使 HTTP/3 的排空过程阻塞
+	h3Client := &http.Client{Transport: &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	h3Done := make(chan error, 1)
+	go func() {
+		resp, err := h3Client.Get("https://" + udpAddr + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		h3Done <- err
+	}()
+	<-started
+
+	stopErr := make(chan error, 1)
+	go func() { stopErr <- svc.Stop(context.Background()) }()
+	require.Eventually(t, func() bool { return (*svc.altSvc.Load())[0] == "clear" }, time.Second, 5*time.Millisecond)
+
+	// 2. HTTP/3 排空期间，TCP 上的新请求应收到 Alt-Svc: clear
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + addr)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "clear", resp.Header.Get("Alt-Svc"))
+
+	// 3. 新的 HTTP/3 连接被立即拒绝，而不是等到握手超时
+	start := time.Now()
+	freshClient := &http.Client{Transport: &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	_, err = freshClient.Get("https://" + udpAddr + "/")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	close(release)
+	assert.NoError(t, <-h3Done)
+	assert.NoError(t, <-stopErr)
+}
+
+func TestHttpService_ShutdownDroppedConnections(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+
+	svc := NewHttpService("drop", "127.0.0.1:0", handler).WithLogger(&zerolog.Logger{})
+	require.NoError(t, svc.Start(context.Background()))
+
+	go http.Get("http://" + svc.listener.Addr().String())
+	<-started
+
+	before := testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedConnection))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+	assert.Equal(t, before+1, testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedConnection)))
+}
+
+func TestHttpService_HTTP3Optional(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	// 预先占用 UDP 端口，使 HTTP/3 监听失败
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udp.Close()
+	addr := udp.LocalAddr().String()
+
+	t.Run("Required", func(t *testing.T) {
+		svc := NewHttpService("h3-required", addr, http.NotFoundHandler()).
+			WithTLS(certMgr).
+			WithHTTP3().
+			WithLogger(&zerolog.Logger{})
+		require.Error(t, svc.Start(context.Background()))
+
+		// TCP 监听已被释放
+		ln, err := net.Listen("tcp", addr)
+		require.NoError(t, err)
+		ln.Close()
+	})
+
+	t.Run("Optional", func(t *testing.T) {
+		svc := NewHttpService("h3-optional", addr, http.NotFoundHandler()).
+			WithTLS(certMgr).
+			WithHTTP3Optional().
+			WithLogger(&zerolog.Logger{})
+		require.NoError(t, svc.Start(context.Background()))
+		defer svc.Stop(context.Background())
+
+		assert.Nil(t, svc.http3Server)
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		resp, err := client.Get("https://" + addr)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Empty(t, resp.Header.Get("Alt-Svc"))
+	})
+}
+
+func TestHttpService_ResponseHeaders(t *testing.T) {
+	svc := NewHttpService("headers", ":0", nil).
+		WithServerHeader("edge").
+		WithStripHeaders("X-Powered-By")
+
+	handler := svc.responseHeaderMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "framework/1.2.3")
+		w.Header().Set("X-Powered-By", "framework")
+		w.Header().Set("X-Request-Id", "abc")
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, "edge", w.Header().Get("Server"))
+	assert.Empty(t, w.Header().Get("X-Powered-By"))
+	assert.Equal(t, "abc", w.Header().Get("X-Request-Id"))
+
+	// 空字符串表示移除 Server 头
+	svc.WithServerHeader("")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	_, ok := w.Header()["Server"]
+	assert.False(t, ok)
+}
+
+// syncBuffer 是并发安全的 bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHttpService_ListenBacklog(t *testing.T) {
+	max, ok := somaxconn()
+	if !ok {
+		t.Skip("net.core.somaxconn is not readable on this platform")
+	}
+
+	// 服务在后台 goroutine 中打印启动日志，需要并发安全的 buffer
+	var buf syncBuffer
+	logger := zerolog.New(&buf)
+	svc := NewHttpService("backlog", "127.0.0.1:0", http.NotFoundHandler()).
+		WithLogger(&logger).
+		WithListenBacklog(int(max) + 1)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	// 超过内核上限时给出警告，但服务仍然正常启动
+	assert.Contains(t, buf.String(), "exceeds net.core.somaxconn")
+	assert.NotContains(t, buf.String(), "Failed to set listen backlog")
+
+	resp, err := http.Get("http://" + svc.listener.Addr().String())
+	require.NoError(t, err)
+	resp.Body.Close()
+}
+
+func TestHttpService_PauseResume(t *testing.T) {
+	svc := NewHttpService("pause", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+	url := "http://" + svc.listener.Addr().String()
+
+	// 暂停前建立一条 keep-alive 连接
+	kept := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 1}}
+	resp, err := kept.Get(url)
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger))
+	app.Add(svc)
+	readyz := func() int {
+		w := httptest.NewRecorder()
+		app.ReadinessHandler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, readyz())
+
+	svc.Pause()
+	assert.True(t, svc.Paused())
+	assert.Error(t, svc.Check(context.Background()))
+	// 无需注册健康检查，/readyz 随暂停状态翻转
+	assert.Equal(t, http.StatusServiceUnavailable, readyz())
+
+	// 已建立的连接继续工作
+	resp, err = kept.Get(url)
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// 新连接不会被处理
+	fresh := &http.Client{Timeout: 200 * time.Millisecond, Transport: &http.Transport{DisableKeepAlives: true}}
+	_, err = fresh.Get(url)
+	assert.Error(t, err)
+
+	svc.Resume()
+	assert.False(t, svc.Paused())
+	assert.NoError(t, svc.Check(context.Background()))
+	assert.Equal(t, http.StatusOK, readyz())
+
+	fresh.Timeout = 5 * time.Second
+	resp, err = fresh.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestPauseListener_HoldsInFlightAccept(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	pl := newPauseListener(ln)
+	defer pl.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := pl.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	// 等待 Accept 阻塞在底层监听器中，再暂停
+	time.Sleep(50 * time.Millisecond)
+	pl.pause()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	select {
+	case <-accepted:
+		t.Fatal("connection handed over while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 恢复后连接被交给上层，而不是被关闭
+	pl.unpause()
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("held connection was not returned after resume")
+	}
+	defer conn.Close()
+	_, err = client.Write([]byte("x"))
+	require.NoError(t, err)
+	buf := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(buf))
+}
+
+func TestHttpService_PauseHTTP3(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+	svc := NewHttpService("pause-h3", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})).WithTLS(certMgr).WithHTTP3().WithLogger(&zerolog.Logger{})
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+	url := "https://" + svc.udpConn.LocalAddr().String() + "/"
+	newClient := func() *http.Client {
+		return &http.Client{Timeout: 5 * time.Second, Transport: &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	}
+
+	// 暂停期间新的 QUIC 连接被立即关闭
+	svc.Pause()
+	start := time.Now()
+	_, err = newClient().Get(url)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	svc.Resume()
+	resp, err := newClient().Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestHttpService_PauseBeforeStart(t *testing.T) {
+	svc := NewHttpService("pause-early", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	svc.Pause()
+	require.NoError(t, svc.Start(context.Background()))
+	assert.True(t, svc.Paused())
+
+	fresh := &http.Client{Timeout: 200 * time.Millisecond, Transport: &http.Transport{DisableKeepAlives: true}}
+	_, err := fresh.Get("http://" + svc.listener.Addr().String())
+	assert.Error(t, err, "paused before Start")
+
+	// 暂停状态在 Stop 后再次 Start 时保留
+	require.NoError(t, svc.Stop(context.Background()))
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+	assert.True(t, svc.Paused())
+	_, err = fresh.Get("http://" + svc.listener.Addr().String())
+	assert.Error(t, err, "paused after restart")
+
+	svc.Resume()
+	fresh.Timeout = 5 * time.Second
+	resp, err := fresh.Get("http://" + svc.listener.Addr().String())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// TestHttpService_StreamingThroughMiddlewares 验证完整中间件链 (o11y/慢请求/响应头) 下
+// ResponseWriter 仍然支持 SSE 所需的 Flusher 以及 WebSocket 所需的 Hijacker
+func TestHttpService_StreamingThroughMiddlewares(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "flusher unsupported", http.StatusInternalServerError)
+			return
+		}
+		_, isReaderFrom := w.(io.ReaderFrom)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Reader-From", fmt.Sprint(isReaderFrom))
+		fmt.Fprint(w, "data: 0\n\n")
+		f.Flush()
+		<-r.Context().Done() // 保持处理函数不返回，直到客户端读到事件后断开
+	})
+	mux.HandleFunc("/hijack", func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\nhello")
+		buf.Flush()
+	})
+
+	logger := zerolog.Nop()
+	svc := NewHttpService("stream", "127.0.0.1:0", mux).
+		WithLogger(&logger).
+		WithObservability(o11y.Config{Enabled: true}).
+		WithSlowRequestLog(time.Hour).
+		WithServerHeader("appx").
+		WithStripHeaders("X-Powered-By")
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+	addr := svc.listener.Addr().String()
+
+	t.Run("SSE flush", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", "http://"+addr+"/sse", nil)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get("X-Reader-From"))
+		assert.Equal(t, "appx", resp.Header.Get("Server"))
+
+		// 事件在处理函数返回之前就能被读到，说明 Flush 穿透了所有中间件
+		line, err := bufio.NewReader(resp.Body).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "data: 0\n", line)
+	})
+
+	t.Run("Hijack", func(t *testing.T) {
+		conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		fmt.Fprintf(conn, "GET /hijack HTTP/1.1\r\nHost: %s\r\n\r\n", addr)
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	})
+}
+
+func TestHttpService_SlowClientGuard(t *testing.T) {
+	logger := zerolog.Nop()
+	body := bytes.Repeat([]byte("x"), 32<<20)
+	writeErr := make(chan error, 1)
+	svc := NewHttpService("slow-client", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(body)
+		if r.URL.Path == "/stalled" {
+			writeErr <- err
+		}
+	})).WithLogger(&logger).WithSlowClientGuard(64 << 20).WithSlowClientGrace(100 * time.Millisecond)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+	addr := svc.listener.Addr().String()
+
+	// 正常读取的客户端不受影响
+	resp, err := http.Get("http://" + addr + "/")
+	require.NoError(t, err)
+	n, err := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), n)
+
+	// 发出请求后不再读取的客户端 (slow-read) 被中止
+	aborts := testutil.ToFloat64(slowClientAbortTotal.WithLabelValues("slow-client"))
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /stalled HTTP/1.1\r\nHost: test\r\n\r\n"))
+	require.NoError(t, err)
+
+	select {
+	case err := <-writeErr:
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled client was not aborted")
+	}
+	assert.Equal(t, aborts+1, testutil.ToFloat64(slowClientAbortTotal.WithLabelValues("slow-client")))
+}
+
+func TestHttpService_DrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+
+	svc := NewHttpService("drain", "127.0.0.1:0", handler).
+		WithLogger(&zerolog.Logger{}).
+		WithDrainTimeout(50 * time.Millisecond)
+	require.NoError(t, svc.Start(context.Background()))
+	addr := svc.listener.Addr().String()
+
+	go http.Get("http://" + addr)
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- svc.Stop(context.Background()) }()
+
+	// 排空期间不再接受新连接
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, time.Second, 5*time.Millisecond)
+
+	select {
+	case err := <-stopped:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "force-closed 1 connection(s)")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not force-close after the drain timeout")
+	}
+}
+
+func TestHttpService_ServerTimeouts(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("Defaults", func(t *testing.T) {
+		svc := NewHttpService("timeouts-default", "127.0.0.1:0", http.NotFoundHandler()).WithLogger(&logger)
+		require.NoError(t, svc.Start(context.Background()))
+		defer svc.Stop(context.Background())
+
+		assert.Equal(t, 5*time.Second, svc.server.ReadHeaderTimeout)
+		assert.Zero(t, svc.server.ReadTimeout)
+		assert.Zero(t, svc.server.WriteTimeout)
+		assert.Equal(t, 60*time.Second, svc.server.IdleTimeout)
+		assert.Equal(t, 1<<20, svc.server.MaxHeaderBytes)
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		svc := NewHttpService("timeouts", "127.0.0.1:0", http.NotFoundHandler()).
+			WithLogger(&logger).
+			WithReadTimeout(10 * time.Second).
+			WithWriteTimeout(20 * time.Second).
+			WithIdleTimeout(30 * time.Second).
+			WithMaxHeaderBytes(64 << 10)
+		require.NoError(t, svc.Start(context.Background()))
+		defer svc.Stop(context.Background())
+
+		assert.Equal(t, 10*time.Second, svc.server.ReadTimeout)
+		assert.Equal(t, 20*time.Second, svc.server.WriteTimeout)
+		assert.Equal(t, 30*time.Second, svc.server.IdleTimeout)
+		assert.Equal(t, 64<<10, svc.server.MaxHeaderBytes)
+	})
+}
+
+func TestHttpService_QUICConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg := NewHttpService("q", ":0", nil).buildQUICConfig()
+		assert.Equal(t, 30*time.Second, cfg.MaxIdleTimeout)
+		assert.True(t, cfg.Allow0RTT)
+	})
+
+	t.Run("Merged", func(t *testing.T) {
+		user := &quic.Config{MaxIncomingStreams: 500, InitialStreamReceiveWindow: 1 << 20}
+		cfg := NewHttpService("q", ":0", nil).WithQUICConfig(user).buildQUICConfig()
+		assert.Equal(t, int64(500), cfg.MaxIncomingStreams)
+		assert.Equal(t, uint64(1<<20), cfg.InitialStreamReceiveWindow)
+		assert.Equal(t, 30*time.Second, cfg.MaxIdleTimeout)
+		assert.False(t, cfg.Allow0RTT)
+		assert.Zero(t, user.MaxIdleTimeout, "caller's config must not be modified")
+	})
+
+	t.Run("Still Requires TLS", func(t *testing.T) {
+		svc := NewHttpService("q", ":0", nil).WithHTTP3().WithQUICConfig(&quic.Config{})
+		err := svc.Start(context.Background())
+		assert.ErrorContains(t, err, "HTTP/3 requires TLS")
+	})
+
+	t.Run("Alt-Svc Max Age", func(t *testing.T) {
+		cPath, kPath := generateTempCert(t)
+		certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+		require.NoError(t, err)
+
+		svc := NewHttpService("h3-ma", "127.0.0.1:0", http.NotFoundHandler()).
+			WithTLS(certMgr).
+			WithHTTP3().
+			WithQUICConfig(&quic.Config{MaxIdleTimeout: 10 * time.Second, MaxIncomingStreams: 10}).
+			WithAltSvcMaxAge(time.Hour).
+			WithLogger(&zerolog.Logger{})
+		require.NoError(t, svc.Start(context.Background()))
+		defer svc.Stop(context.Background())
+
+		port := svc.udpConn.LocalAddr().(*net.UDPAddr).Port
+		assert.Equal(t, []string{fmt.Sprintf(`h3=":%d"; ma=3600`, port)}, *svc.altSvc.Load())
+	})
+}
+
+func TestHttpService_ConnStateHook(t *testing.T) {
+	var mu sync.Mutex
+	var states []http.ConnState
+	logger := zerolog.Nop()
+	svc := NewHttpService("conn-state", "127.0.0.1:0", http.NotFoundHandler()).
+		WithLogger(&logger).
+		WithMaxConns(10).
+		WithConnStateHook(func(_ net.Conn, state http.ConnState) {
+			mu.Lock()
+			defer mu.Unlock()
+			states = append(states, state)
+		})
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+	assert.Zero(t, svc.ActiveConns())
+
+	conn, err := net.Dial("tcp", svc.listener.Addr().String())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return svc.ActiveConns() == 1 }, time.Second, 5*time.Millisecond)
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	io.Copy(io.Discard, conn)
+	conn.Close()
+	// 回调在计数之后执行，等待回调观察到关闭再检查计数
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return states[len(states)-1] == http.StateClosed
+	}, time.Second, 5*time.Millisecond)
+	assert.Zero(t, svc.ActiveConns())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []http.ConnState{http.StateNew, http.StateActive, http.StateClosed}, states)
+}
+
+func TestHttpService_LatencyHook(t *testing.T) {
+	var mu sync.Mutex
+	var slow []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+	logger := zerolog.Nop()
+	svc := NewHttpService("latency", "127.0.0.1:0", handler).
+		WithLogger(&logger).
+		WithRequestDurationMetric(0.01, 0.1, 1).
+		WithLatencyHook(40*time.Millisecond, func(r *http.Request, d time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			slow = append(slow, r.URL.Path)
+			assert.GreaterOrEqual(t, d, 40*time.Millisecond)
+		})
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	for _, path := range []string{"/fast", "/slow", "/fast"} {
+		resp, err := http.Get("http://" + svc.listener.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	mu.Lock()
+	assert.Equal(t, []string{"/slow"}, slow)
+	mu.Unlock()
+
+	assert.Equal(t, []float64{0.01, 0.1, 1}, requestDurationBuckets)
+	assert.Equal(t, 1, testutil.CollectAndCount(requestDuration))
+}
+
+func TestHttpService_UnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket permissions are not enforced on windows")
+	}
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	sock := filepath.Join(t.TempDir(), "api.sock")
+	svc := NewHttpService("unix-api", "", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("over unix"))
+	})).
+		WithUnixSocket(sock, 0o660).
+		WithTLS(certMgr).
+		WithLogger(&zerolog.Logger{})
+	require.NoError(t, svc.Start(context.Background()))
+
+	fi, err := os.Stat(sock)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), fi.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("https://api/")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "over unix", string(body))
+
+	// Stop 删除套接字文件
+	require.NoError(t, svc.Stop(context.Background()))
+	_, err = os.Stat(sock)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// HTTP/3 与 ReusePort 依赖 TCP/UDP，在 unix 地址上直接拒绝
+	err = NewHttpService("unix-h3", "", nil).WithUnixSocket(sock, 0).WithTLS(certMgr).WithHTTP3().Validate(context.Background())
+	assert.EqualError(t, err, "HTTP/3 is not supported on unix sockets")
+	err = NewHttpService("unix-rp", "", nil).WithUnixSocket(sock, 0).WithReusePort().Validate(context.Background())
+	assert.EqualError(t, err, "ReusePort is not supported on unix sockets")
+}
+
+func TestHttpService_ProxyProtocol(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	})
+	logger := zerolog.Nop()
+	newSvc := func(cidrs ...string) string {
+		svc := NewHttpService("proxy", "127.0.0.1:0", handler).WithLogger(&logger).WithProxyProtocol(cidrs...)
+		require.NoError(t, svc.Start(context.Background()))
+		t.Cleanup(func() { svc.Stop(context.Background()) })
+		return svc.listener.Addr().String()
+	}
+	// roundTrip 在 PROXY 头之后发送一个 HTTP 请求，返回服务端看到的 RemoteAddr
+	roundTrip := func(addr string, header []byte) string {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write(append(header, "GET / HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n"...))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	trusted := newSvc("127.0.0.0/8")
+
+	t.Run("V1", func(t *testing.T) {
+		header := []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\n")
+		assert.Equal(t, "203.0.113.7:51234", roundTrip(trusted, header))
+	})
+
+	t.Run("V2", func(t *testing.T) {
+		header := []byte("\r\n\r\n\x00\r\nQUIT\n")
+		header = append(header, 0x21, 0x11, 0x00, 12) // v2 PROXY, TCP over IPv4, 12 字节地址
+		header = append(header, 198, 51, 100, 9, 10, 0, 0, 1)
+		header = append(header, 0xC3, 0x50, 0x01, 0xBB) // 50000 -> 443
+		assert.Equal(t, "198.51.100.9:50000", roundTrip(trusted, header))
+	})
+
+	t.Run("Untrusted Source", func(t *testing.T) {
+		addr := newSvc("10.0.0.0/8")
+		assert.Contains(t, roundTrip(addr, nil), "127.0.0.1:")
+	})
+
+	t.Run("Invalid CIDR", func(t *testing.T) {
+		err := NewHttpService("proxy", "127.0.0.1:0", handler).WithProxyProtocol("10.0.0.1").Validate(context.Background())
+		assert.ErrorContains(t, err, `invalid PROXY protocol trusted CIDR "10.0.0.1"`)
+	})
+}
+
+func TestHttpService_HandshakeLimit(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	err = NewHttpService("plain", "127.0.0.1:0", nil).WithHandshakeLimit(8).Validate(context.Background())
+	assert.ErrorContains(t, err, "requires TLS")
+
+	svc := NewHttpService("hs-limit", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})).WithTLS(certMgr).WithHandshakeLimit(1).WithLogger(&zerolog.Logger{})
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	// 握手在 Accept 之前完成，HTTP/2 协商不受影响
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get("https://" + svc.listener.Addr().String())
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/2.0", string(body))
+
+	// 握手名额被占满时，新连接排队超时后被关闭
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tlsConfig := &tls.Config{GetCertificate: certMgr.GetCertificate}
+	ln := newHandshakeLimitListener(tls.NewListener(raw, tlsConfig), "hs-unit", 1, 100*time.Millisecond)
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	before := testutil.ToFloat64(tlsHandshakeRejectedTotal.WithLabelValues("hs-unit"))
+	ln.sem <- struct{}{}
+	_, err = tls.Dial("tcp", raw.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	assert.Error(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(tlsHandshakeRejectedTotal.WithLabelValues("hs-unit")))
+
+	// 名额释放后握手正常完成
+	<-ln.sem
+	conn, err := tls.Dial("tcp", raw.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	defer conn.Close()
+	select {
+	case c := <-accepted:
+		assert.True(t, c.(*tls.Conn).ConnectionState().HandshakeComplete)
+		c.Close()
+	case <-time.After(time.Second):
+		t.Fatal("connection was not accepted")
+	}
+}
+
+func TestHttpService_TLSOptions(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	for name, tt := range map[string]struct {
+		svc  *HttpService
+		want string
+	}{
+		"no TLS":          {NewHttpService("plain", "127.0.0.1:0", nil).WithTLSOptions(TLSOptions{MinVersion: tls.VersionTLS12}), "require TLS"},
+		"TLS 1.1":         {NewHttpService("v", "127.0.0.1:0", nil).WithTLS(certMgr).WithTLSOptions(TLSOptions{MinVersion: tls.VersionTLS11}), "is not allowed"},
+		"max below min":   {NewHttpService("v", "127.0.0.1:0", nil).WithTLS(certMgr).WithTLSOptions(TLSOptions{MaxVersion: tls.VersionTLS12}), "lower than min version"},
+		"HTTP/3 max 1.2":  {NewHttpService("v", "127.0.0.1:0", nil).WithTLS(certMgr).WithHTTP3().WithTLSOptions(TLSOptions{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12}), "HTTP/3 requires TLS 1.3"},
+		"suites with 1.3": {NewHttpService("v", "127.0.0.1:0", nil).WithTLS(certMgr).WithTLSOptions(TLSOptions{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}}), "only apply to TLS 1.2"},
+		"insecure suite":  {NewHttpService("v", "127.0.0.1:0", nil).WithTLS(certMgr).WithTLSOptions(TLSOptions{MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_RSA_WITH_RC4_128_SHA}}), "insecure or unknown"},
+		"TLS 1.3 suite":   {NewHttpService("v", "127.0.0.1:0", nil).WithTLS(certMgr).WithTLSOptions(TLSOptions{MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}}), "not a TLS 1.2 suite"},
+	} {
+		assert.ErrorContains(t, tt.svc.Validate(ctx), tt.want, name)
+	}
+	// QUIC 强制 TLS 1.3，HTTP/3 与 TCP 上的 TLS 1.2 可以共存
+	assert.NoError(t, NewHttpService("v", "127.0.0.1:0", nil).WithTLS(certMgr).WithHTTP3().
+		WithTLSOptions(TLSOptions{MinVersion: tls.VersionTLS12}).Validate(ctx))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	dial := func(svc *HttpService, cfg *tls.Config) (tls.ConnectionState, error) {
+		cfg.InsecureSkipVerify = true
+		conn, err := tls.Dial("tcp", svc.listener.Addr().String(), cfg)
+		if err != nil {
+			return tls.ConnectionState{}, err
+		}
+		defer conn.Close()
+		return conn.ConnectionState(), nil
+	}
+
+	// 默认只允许 TLS 1.3
+	def := NewHttpService("tls-default", "127.0.0.1:0", handler).WithTLS(certMgr).WithLogger(&zerolog.Logger{})
+	require.NoError(t, def.Start(ctx))
+	defer def.Stop(ctx)
+	_, err = dial(def, &tls.Config{MaxVersion: tls.VersionTLS12})
+	assert.Error(t, err)
+
+	svc := NewHttpService("tls-options", "127.0.0.1:0", handler).WithTLS(certMgr).WithLogger(&zerolog.Logger{}).
+		WithTLSOptions(TLSOptions{
+			MinVersion:       tls.VersionTLS12,
+			CipherSuites:     []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+			CurvePreferences: []tls.CurveID{tls.X25519},
+		})
+	require.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	state, err := dial(svc, &tls.Config{MaxVersion: tls.VersionTLS12})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), state.Version)
+	assert.Equal(t, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, state.CipherSuite)
+	assert.Equal(t, tls.X25519, state.CurveID)
+
+	_, err = dial(svc, &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}})
+	assert.Error(t, err, "cipher suite outside the configured list")
+
+	state, err = dial(svc, &tls.Config{})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), state.Version)
+}
+
+func TestHttpService_HTTP3ReleasesUDP(t *testing.T) {
+	cPath, kPath := generateTempCert(t)
+	certMgr, err := cert.New(cert.Config{CertFile: cPath, KeyFile: kPath}, &log.Logger)
+	require.NoError(t, err)
+
+	openFDs := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			return -1
+		}
+		return len(entries)
+	}
+	before := openFDs()
+
+	for range 5 {
+		svc := NewHttpService("h3-cycle", "127.0.0.1:0", http.NotFoundHandler()).
+			WithTLS(certMgr).
+			WithHTTP3().
+			WithLogger(&zerolog.Logger{})
+		require.NoError(t, svc.Start(context.Background()))
+		udpAddr := svc.udpConn.LocalAddr().String()
+		require.NoError(t, svc.Stop(context.Background()))
+
+		// PacketConn 只关闭一次，重复关闭不会报错
+		assert.Nil(t, svc.udpConn)
+		assert.NoError(t, svc.closeUDP())
+
+		// 端口已释放，可以立即重新绑定
+		pc, err := net.ListenPacket("udp", udpAddr)
+		require.NoError(t, err)
+		pc.Close()
+	}
+
+	if before >= 0 {
+		assert.Eventually(t, func() bool { return openFDs() <= before }, 2*time.Second, 20*time.Millisecond,
+			"file descriptors leaked across Start/Stop cycles")
+	}
+}
+
+func TestHttpService_ContextValues(t *testing.T) {
+	type versionKey struct{}
+	type regionKey struct{}
+	type startKey struct{}
+
+	svc := NewHttpService("ctxvals", "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		fmt.Fprintf(w, "%v %v %v", ctx.Value(versionKey{}), ctx.Value(regionKey{}), ctx.Value(startKey{}))
+	})).
+		WithContextValues(map[any]any{versionKey{}: "v1", regionKey{}: "us-east"}).
+		WithContextValues(map[any]any{versionKey{}: "v2"})
+
+	ctx := context.WithValue(context.Background(), startKey{}, "inherited")
+	require.NoError(t, svc.Start(ctx))
+	defer svc.Stop(context.Background())
+
+	resp, err := http.Get("http://" + svc.listener.Addr().String())
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "v2 us-east inherited", string(body))
+
+	bad := NewHttpService("ctxvals", "127.0.0.1:0", nil).WithContextValues(map[any]any{nil: "x"})
+	assert.EqualError(t, bad.Validate(context.Background()), "context value key must not be nil")
+}
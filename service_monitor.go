@@ -8,8 +8,32 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// MonitorOptions 是监控服务的可选配置
+type MonitorOptions struct {
+	// HealthHandler 挂载在 /healthz，为 nil 时返回固定的 "ok"
+	HealthHandler http.Handler
+
+	// ReadinessHandler 挂载在 /readyz (通常为 app.ReadinessHandler())，为 nil 时与 /healthz 相同
+	ReadinessHandler http.Handler
+
+	// LivenessHandler 挂载在 /livez (通常为 app.LivenessHandler())，为 nil 时返回固定的 "ok"
+	LivenessHandler http.Handler
+
+	// ExtraHandlers 注册额外的运维诊断端点 (如 dump 内部状态、切换特性开关、刷新缓存)。
+	// key 为 http.ServeMux 的 pattern，与内置端点共享同一组中间件 (鉴权/隔离)。
+	// 与内置端点 (/healthz, /readyz, /livez, /metrics, /debug/pprof/) 冲突时 panic。
+	ExtraHandlers map[string]http.Handler
+
+	// NotFoundHandler 处理未匹配任何端点的请求，同样位于中间件之后。
+	// 可用于向已认证的运维人员返回端点索引，或返回不透露服务类型的 404。
+	// 为 nil 时使用 http.ServeMux 默认的 404。
+	NotFoundHandler http.Handler
+}
+
 // NewMonitorService 创建监控服务。
-// 支持传入 mws 中间件对 /metrics, /healthz, /debug/pprof 进行保护。
+// 支持传入 mws 中间件对 /metrics, /healthz, /readyz, /livez, /debug/pprof 进行保护。
+// addr 可以是 Unix 套接字 (如 "unix:///run/monitor.sock")，不占用任何网络端口，
+// 套接字文件默认权限为 0600，可通过返回值的 WithUnixSocketMode 调整。
 //
 // 示例 - 添加 Basic Auth:
 //
@@ -17,6 +41,20 @@ import (
 //	  httpx.AuthBasic(myValidator, "Monitor"),
 //	))
 func NewMonitorService(addr string, healthHandler http.Handler, mws ...func(http.Handler) http.Handler) *HttpService {
+	return NewMonitorServiceWithOptions(addr, MonitorOptions{HealthHandler: healthHandler}, mws...)
+}
+
+// NewMonitorServiceWithOptions 与 NewMonitorService 相同，但支持更多配置。
+//
+// 示例 - 挂载自定义诊断端点:
+//
+//	app.Add(appx.NewMonitorServiceWithOptions(":9090", appx.MonitorOptions{
+//	  HealthHandler:    app.HealthHandler(),
+//	  ReadinessHandler: app.ReadinessHandler(),
+//	  LivenessHandler:  app.LivenessHandler(),
+//	  ExtraHandlers: map[string]http.Handler{"/debug/flags": flagsHandler},
+//	}, httpx.AuthBasic(myValidator, "Monitor")))
+func NewMonitorServiceWithOptions(addr string, opts MonitorOptions, mws ...func(http.Handler) http.Handler) *HttpService {
 	// 安全检查
 	if len(mws) == 0 {
 		log.Error().Msg("Monitor Service at " + addr + " is unprotected!")
@@ -26,14 +64,25 @@ func NewMonitorService(addr string, healthHandler http.Handler, mws ...func(http
 
 	mux := http.NewServeMux()
 
-	// 1. Dynamic Health Check
-	if healthHandler != nil {
-		mux.Handle("/healthz", healthHandler)
-	} else {
-		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte("ok"))
-		})
+	// 1. Dynamic Health Check (/healthz 保留向后兼容，/readyz 与 /livez 供 Kubernetes 分别配置探针)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	health := opts.HealthHandler
+	if health == nil {
+		health = ok
+	}
+	readiness := opts.ReadinessHandler
+	if readiness == nil {
+		readiness = health
+	}
+	liveness := opts.LivenessHandler
+	if liveness == nil {
+		liveness = ok
 	}
+	mux.Handle("/healthz", health)
+	mux.Handle("/readyz", readiness)
+	mux.Handle("/livez", liveness)
 
 	// 2. Metrics (Prometheus)
 	mux.Handle("/metrics", promhttp.Handler())
@@ -46,7 +95,17 @@ func NewMonitorService(addr string, healthHandler http.Handler, mws ...func(http
 	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
-	// 4. 应用中间件 (洋葱模型：后传入的先执行)
+	// 4. 自定义诊断端点
+	for pattern, h := range opts.ExtraHandlers {
+		mux.Handle(pattern, h)
+	}
+
+	// 5. 未匹配路由的兜底处理 ("/" 优先级最低)
+	if opts.NotFoundHandler != nil {
+		mux.Handle("/", opts.NotFoundHandler)
+	}
+
+	// 6. 应用中间件 (洋葱模型：后传入的先执行)
 	var handler http.Handler = mux
 	for i := len(mws) - 1; i >= 0; i-- {
 		handler = mws[i](handler)
@@ -0,0 +1,120 @@
+package appx
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorService_ExtraHandlers(t *testing.T) {
+	var authCalls int
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authCalls++
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	svc := NewMonitorServiceWithOptions(":0", MonitorOptions{
+		ExtraHandlers: map[string]http.Handler{
+			"/debug/flags": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("flags"))
+			}),
+		},
+	}, auth)
+
+	w := httptest.NewRecorder()
+	svc.handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/flags", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "flags", w.Body.String())
+	// 自定义端点同样经过监控服务的中间件
+	assert.Equal(t, 1, authCalls)
+}
+
+func TestMonitorService_NotFoundHandler(t *testing.T) {
+	svc := NewMonitorServiceWithOptions(":0", MonitorOptions{
+		NotFoundHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nothing here", http.StatusNotFound)
+		}),
+	}, func(next http.Handler) http.Handler { return next })
+
+	w := httptest.NewRecorder()
+	svc.handler.ServeHTTP(w, httptest.NewRequest("GET", "/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "nothing here\n", w.Body.String())
+
+	// 内置端点不受影响
+	w = httptest.NewRecorder()
+	svc.handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMonitorService_Probes(t *testing.T) {
+	unavailable := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	svc := NewMonitorServiceWithOptions(":0", MonitorOptions{
+		HealthHandler: unavailable,
+	}, func(next http.Handler) http.Handler { return next })
+
+	codes := map[string]int{}
+	for _, path := range []string{"/healthz", "/readyz", "/livez"} {
+		w := httptest.NewRecorder()
+		svc.handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		codes[path] = w.Code
+	}
+	// 未设置时 /readyz 沿用 /healthz，/livez 始终存活
+	assert.Equal(t, map[string]int{
+		"/healthz": http.StatusServiceUnavailable,
+		"/readyz":  http.StatusServiceUnavailable,
+		"/livez":   http.StatusOK,
+	}, codes)
+}
+
+func TestMonitorService_UnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket permissions are not enforced on windows")
+	}
+	sock := filepath.Join(t.TempDir(), "monitor.sock")
+	// 模拟上次异常退出遗留的套接字文件
+	stale, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	svc := NewMonitorService("unix://"+sock, nil, func(next http.Handler) http.Handler { return next })
+	require.NoError(t, svc.Start(context.Background()))
+
+	fi, err := os.Stat(sock)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// 已有进程在监听时不会抢占套接字
+	_, err = NewMonitorService("unix://"+sock, nil).listen()
+	assert.ErrorContains(t, err, "address already in use")
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("http://monitor/healthz")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	require.NoError(t, svc.Stop(context.Background()))
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err), "socket file should be removed on stop")
+}
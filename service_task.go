@@ -2,24 +2,262 @@ package appx
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/oy3o/task"
 )
 
+// Priority 定义任务优先级
+type Priority int
+
+const (
+	// PriorityLow 尽力而为的任务，队列饱和时最先被丢弃
+	PriorityLow Priority = iota
+	// PriorityHigh 关键任务，优先调度
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	if p == PriorityHigh {
+		return "high"
+	}
+	return "low"
+}
+
+// TaskStats 包含 TaskService 的运行时快照
+type TaskStats struct {
+	HighQueued int        `json:"high_queued"` // 高优先级队列中等待的任务数
+	LowQueued  int        `json:"low_queued"`  // 低优先级队列中等待的任务数
+	Runner     task.Stats `json:"runner"`
+}
+
+// TaskService 将 task.Runner 托管到 Appx 生命周期中，并在其之上提供优先级调度。
+// 任务先进入高/低两个优先级队列，由调度协程按 "高优先" 的顺序送入 Runner；
+// 调度协程只在 Runner 有空闲 Worker 时派发任务，因此真正的排队发生在优先级队列中。
 type TaskService struct {
+	name   string
 	runner *task.Runner
+
+	high chan task.TaskFunc
+	low  chan task.TaskFunc
+	// slots 限制派发给 Runner 但尚未执行完毕的任务数 (= Runner 的 Worker 数)
+	slots chan struct{}
+	done  chan struct{}
+	// abort 在 Stop 超时时通知调度协程放弃剩余任务并退出
+	abort       context.Context
+	cancelAbort context.CancelFunc
+
+	// mu 保护队列 channel 的关闭操作，防止 "send on closed channel" panic
+	mu      sync.RWMutex
+	running bool
+}
+
+var (
+	_ Service       = (*TaskService)(nil)
+	_ HealthChecker = (*TaskService)(nil)
+)
+
+func NewTaskService(runner *task.Runner) *TaskService {
+	return &TaskService{
+		name:   "background-tasks",
+		runner: runner,
+		high:   make(chan task.TaskFunc, 1000),
+		low:    make(chan task.TaskFunc, 1000),
+	}
+}
+
+// WithPriorityQueues 设置高/低优先级队列的长度 (默认均为 1000)。
+// 长度为 0 时不排队：只有调度协程空闲 (Runner 有空闲 Worker) 时提交才会成功。
+// 注意：必须在 Start 之前调用。
+func (t *TaskService) WithPriorityQueues(high, low int) *TaskService {
+	t.high = make(chan task.TaskFunc, high)
+	t.low = make(chan task.TaskFunc, low)
+	return t
 }
 
-func NewTaskService(runner *task.Runner) Service {
-	return &TaskService{runner: runner}
+// WithName 设置服务名称 (默认 "background-tasks")。
+// 注册多个 TaskService 时需要各自命名，名称同时用作日志字段与指标标签。
+func (t *TaskService) WithName(name string) *TaskService {
+	t.name = name
+	return t
 }
 
-func (t *TaskService) Name() string { return "background-tasks" }
+func (t *TaskService) Name() string { return t.name }
 
 func (t *TaskService) Start(ctx context.Context) error {
-	return t.runner.Start(ctx)
+	if err := t.runner.Start(ctx); err != nil {
+		return err
+	}
+
+	t.slots = make(chan struct{}, t.runner.Stats().MaxWorkers)
+	t.done = make(chan struct{})
+	t.abort, t.cancelAbort = context.WithCancel(context.Background())
+
+	t.mu.Lock()
+	t.running = true
+	t.mu.Unlock()
+
+	go t.dispatch()
+	return nil
 }
 
 func (t *TaskService) Stop(ctx context.Context) error {
-	return t.runner.Stop(ctx)
+	// 1. 停止接收新任务，关闭队列
+	t.mu.Lock()
+	if t.running {
+		t.running = false
+		close(t.high)
+		close(t.low)
+	}
+	t.mu.Unlock()
+
+	// 2. 等待调度协程把队列中的存量任务派发完毕
+	if t.done != nil {
+		select {
+		case <-t.done:
+		case <-ctx.Done():
+			// 调度协程可能阻塞在等待空闲 Worker 上，通知其退出；剩余任务计为丢弃。
+			// 仍然停止 Runner 以取消运行中任务的 Context，否则 Worker 与调度协程会泄漏。
+			t.cancelAbort()
+			t.recordDropped(len(t.high) + len(t.low) + t.runner.Stats().QueuedTasks)
+			t.runner.Stop(ctx)
+			return ctx.Err()
+		}
+	}
+
+	// 3. 等待 Runner 执行完所有任务
+	if err := t.runner.Stop(ctx); err != nil {
+		t.recordDropped(t.runner.Stats().QueuedTasks)
+		return err
+	}
+	return nil
+}
+
+// recordDropped 记录关闭超时时仍在排队、未能执行的任务数
+func (t *TaskService) recordDropped(n int) {
+	if n > 0 {
+		shutdownDroppedTotal.WithLabelValues(droppedTask).Add(float64(n))
+	}
+}
+
+// SubmitPriority 按优先级提交异步任务。
+// 队列饱和时低优先级任务最先被丢弃：只要高优先级队列中仍有积压，低优先级提交就会被拒绝。
+// 被拒绝时返回 task.ErrQueueFull，停止后返回 task.ErrRunnerClosed。
+func (t *TaskService) SubmitPriority(p Priority, fn task.TaskFunc) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.running {
+		return task.ErrRunnerClosed
+	}
+
+	q := t.low
+	if p == PriorityHigh {
+		q = t.high
+	} else if len(t.high) > 0 {
+		// 关键任务积压，优先保障高优先级
+		t.shed(p)
+		return task.ErrQueueFull
+	}
+
+	select {
+	case q <- fn:
+		t.updateDepth()
+		return nil
+	default:
+		t.shed(p)
+		return task.ErrQueueFull
+	}
+}
+
+// Stats 获取当前 TaskService 的状态快照
+func (t *TaskService) Stats() TaskStats {
+	return TaskStats{
+		HighQueued: len(t.high),
+		LowQueued:  len(t.low),
+		Runner:     t.runner.Stats(),
+	}
+}
+
+// Check 实现 HealthChecker 接口。
+// 仅当高优先级队列饱和时视为不健康；低优先级任务被丢弃属于预期的降级行为。
+// 长度为 0 的高优先级队列不排队，不会饱和。
+func (t *TaskService) Check(ctx context.Context) error {
+	if high := len(t.high); cap(t.high) > 0 && high >= cap(t.high) {
+		return fmt.Errorf("high priority queue saturated (high=%d, low=%d)", high, len(t.low))
+	}
+	return nil
+}
+
+// dispatch 按优先级把队列中的任务派发给 Runner，直到队列关闭且清空
+func (t *TaskService) dispatch() {
+	defer close(t.done)
+
+	high, low := t.high, t.low
+	for high != nil || low != nil {
+		// 1. 等待 Runner 出现空闲 Worker
+		select {
+		case t.slots <- struct{}{}:
+		case <-t.abort.Done():
+			return
+		}
+
+		// 2. 优先取高优先级任务
+		fn, ok := t.next(&high, &low)
+		if !ok {
+			<-t.slots
+			continue
+		}
+		t.updateDepth()
+
+		err := t.runner.Submit(func(ctx context.Context) {
+			defer func() { <-t.slots }()
+			fn(ctx)
+		})
+		if err != nil {
+			// Runner 已被外部停止或被其他调用方占满
+			<-t.slots
+		}
+	}
+}
+
+// next 按优先级取出下一个任务。队列关闭后将对应的指针置为 nil
+func (t *TaskService) next(high, low *chan task.TaskFunc) (task.TaskFunc, bool) {
+	for *high != nil || *low != nil {
+		select {
+		case fn, ok := <-*high:
+			if !ok {
+				*high = nil
+				continue
+			}
+			return fn, true
+		default:
+		}
+
+		select {
+		case fn, ok := <-*high:
+			if !ok {
+				*high = nil
+				continue
+			}
+			return fn, true
+		case fn, ok := <-*low:
+			if !ok {
+				*low = nil
+				continue
+			}
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+func (t *TaskService) shed(p Priority) {
+	taskShedTotal.WithLabelValues(t.Name(), p.String()).Inc()
+}
+
+func (t *TaskService) updateDepth() {
+	taskQueueDepth.WithLabelValues(t.Name(), PriorityHigh.String()).Set(float64(len(t.high)))
+	taskQueueDepth.WithLabelValues(t.Name(), PriorityLow.String()).Set(float64(len(t.low)))
 }
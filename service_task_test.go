@@ -0,0 +1,100 @@
+package appx
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oy3o/task"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskService_Priority(t *testing.T) {
+	svc := NewTaskService(task.NewRunner(task.WithMaxWorkers(1))).WithPriorityQueues(4, 4)
+	require.NoError(t, svc.Start(context.Background()))
+
+	// 占住唯一的 Worker
+	release := make(chan struct{})
+	require.NoError(t, svc.SubmitPriority(PriorityHigh, func(ctx context.Context) { <-release }))
+	require.Eventually(t, func() bool { return svc.Stats().HighQueued == 0 }, time.Second, 5*time.Millisecond)
+
+	var mu sync.Mutex
+	var order []Priority
+	record := func(p Priority) task.TaskFunc {
+		return func(ctx context.Context) {
+			mu.Lock()
+			order = append(order, p)
+			mu.Unlock()
+		}
+	}
+
+	require.NoError(t, svc.SubmitPriority(PriorityLow, record(PriorityLow)))
+	require.NoError(t, svc.SubmitPriority(PriorityHigh, record(PriorityHigh)))
+
+	// 高优先级积压时，低优先级提交被丢弃
+	assert.ErrorIs(t, svc.SubmitPriority(PriorityLow, record(PriorityLow)), task.ErrQueueFull)
+	stats := svc.Stats()
+	assert.Equal(t, 1, stats.HighQueued)
+	assert.Equal(t, 1, stats.LowQueued)
+
+	close(release)
+	require.NoError(t, svc.Stop(context.Background()))
+
+	// 高优先级任务先于更早提交的低优先级任务执行
+	assert.Equal(t, []Priority{PriorityHigh, PriorityLow}, order)
+	assert.ErrorIs(t, svc.SubmitPriority(PriorityHigh, record(PriorityHigh)), task.ErrRunnerClosed)
+}
+
+func TestTaskService_Check(t *testing.T) {
+	svc := NewTaskService(task.NewRunner(task.WithMaxWorkers(1))).WithPriorityQueues(1, 1)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+	require.NoError(t, svc.SubmitPriority(PriorityHigh, func(ctx context.Context) { <-release }))
+	require.Eventually(t, func() bool { return svc.Stats().HighQueued == 0 }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, svc.Check(context.Background()))
+
+	require.NoError(t, svc.SubmitPriority(PriorityHigh, func(ctx context.Context) {}))
+	assert.ErrorContains(t, svc.Check(context.Background()), "high priority queue saturated")
+
+	// 长度为 0 的队列不排队，不视为饱和
+	unbuffered := NewTaskService(task.NewRunner(task.WithMaxWorkers(1))).WithPriorityQueues(0, 0)
+	assert.NoError(t, unbuffered.Check(context.Background()))
+}
+
+func TestTaskService_StopTimeout(t *testing.T) {
+	svc := NewTaskService(task.NewRunner(task.WithMaxWorkers(1))).WithPriorityQueues(4, 4)
+	require.NoError(t, svc.Start(context.Background()))
+
+	// 占住唯一的 Worker，任务只在 Runner 停止 (Context 取消) 后返回
+	started, exited := make(chan struct{}), make(chan struct{})
+	require.NoError(t, svc.SubmitPriority(PriorityHigh, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(exited)
+	}))
+	<-started
+	require.NoError(t, svc.SubmitPriority(PriorityHigh, func(ctx context.Context) {}))
+	require.NoError(t, svc.SubmitPriority(PriorityHigh, func(ctx context.Context) {}))
+
+	before := testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedTask))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+	assert.Equal(t, before+2, testutil.ToFloat64(shutdownDroppedTotal.WithLabelValues(droppedTask)))
+
+	// 超时后 Runner 仍被停止：运行中的任务被取消，Worker 与调度协程退出
+	for name, ch := range map[string]chan struct{}{"task": exited, "dispatcher": svc.done} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("%s goroutine did not exit", name)
+		}
+	}
+	require.Eventually(t, func() bool { return svc.Stats().Runner.ActiveWorkers == 0 }, time.Second, 5*time.Millisecond)
+}
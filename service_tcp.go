@@ -0,0 +1,221 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/oy3o/netx"
+	"github.com/rs/zerolog"
+)
+
+// TCPHandler 处理一个 TCP 连接。ctx 在连接关闭或服务停止时取消；handler 返回后连接会被关闭。
+type TCPHandler func(ctx context.Context, conn net.Conn)
+
+// TCPService 是通用的 TCP 服务，用于行协议、类 Redis 协议或自定义二进制协议等非 HTTP 服务。
+// 与 HttpService 一样使用 netx 网络层增强链 (KeepAlive -> User Custom -> Context -> Limit)，
+// 每个连接在独立的 goroutine 中交给 handler 处理，handler 中的 panic 只会中断所在的连接。
+type TCPService struct {
+	name     string
+	addr     string
+	handler  TCPHandler
+	logger   *zerolog.Logger
+	onFatal  ErrorNotifier
+	maxConns int
+
+	keepAlivePeriod time.Duration
+	netMiddlewares  []netx.Middleware
+
+	// Runtime
+	listener net.Listener
+	cancel   context.CancelFunc // 取消所有连接的 Context，Stop 时调用
+	wg       sync.WaitGroup     // 进行中的 handler
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{} // 进行中的连接，Stop 超时后强制关闭
+	running  bool                  // Start 成功之后、Stop 之前为 true
+	stopping bool                  // Stop 之后不再登记新的 handler，Start 时重置
+}
+
+var (
+	_ Service         = (*TCPService)(nil)
+	_ ErrorNotifiable = (*TCPService)(nil)
+)
+
+func NewTCPService(name, addr string, handler TCPHandler) *TCPService {
+	return &TCPService{
+		name:            name,
+		addr:            addr,
+		handler:         handler,
+		maxConns:        10000,
+		keepAlivePeriod: 3 * time.Minute,
+		conns:           make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *TCPService) WithLogger(l *zerolog.Logger) *TCPService {
+	s.logger = l
+	return s
+}
+
+// WithMaxConns 设置最大并发连接数 (默认 10000)，达到上限后新连接在内核队列中等待
+func (s *TCPService) WithMaxConns(n int) *TCPService {
+	s.maxConns = n
+	return s
+}
+
+// WithNetMiddleware 注入自定义 TCP 网络层中间件 (如 IP 白名单、Proxy Protocol)
+func (s *TCPService) WithNetMiddleware(mws ...netx.Middleware) *TCPService {
+	s.netMiddlewares = append(s.netMiddlewares, mws...)
+	return s
+}
+
+func (s *TCPService) SetErrorNotify(fn ErrorNotifier) {
+	s.onFatal = fn
+}
+
+func (s *TCPService) Name() string { return s.name }
+
+// Start 开始监听并在后台接收连接。Stop 之后可以再次 Start (如被 WithRestartPolicy 重启)，运行中重复调用返回错误。
+func (s *TCPService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return fmt.Errorf("tcp service %s already running", s.name)
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	// 连接的 Context 继承 Start ctx 中的值，但不继承其取消，由 Stop 统一取消
+	base, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancel = cancel
+
+	chain := []netx.Middleware{netx.WithKeepAlive(s.keepAlivePeriod)}
+	chain = append(chain, s.netMiddlewares...)
+	chain = append(chain,
+		netx.WithContext(func(net.Conn) context.Context { return base }),
+		netx.WithLimit(s.maxConns),
+	)
+	s.listener = netx.Chain(ln, chain...)
+	s.running = true
+	s.stopping = false
+
+	go s.serve(s.listener)
+	return nil
+}
+
+// serve 循环接收连接，直到监听器关闭
+func (s *TCPService) serve(ln net.Listener) {
+	defer handlePanic(s.logger, s.name, s.onFatal)
+
+	printServiceListening(s.logger, s.name, "TCP", ln.Addr().String())
+
+	var backoff time.Duration
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// 与 http.Server 一致：临时错误 (如文件描述符耗尽) 退避后重试
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				backoff = min(max(2*backoff, 5*time.Millisecond), time.Second)
+				if s.logger != nil {
+					s.logger.Warn().Err(err).Str("name", s.name).Dur("retry_in", backoff).Msg("TCP accept error")
+				}
+				time.Sleep(backoff)
+				continue
+			}
+			if s.logger != nil {
+				s.logger.Error().Err(err).Str("name", s.name).Msg("TCP service crashed")
+			}
+			if s.onFatal != nil {
+				s.onFatal(err)
+			}
+			return
+		}
+		backoff = 0
+
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle 在独立的 goroutine 中处理连接，panic 只记录日志与指标，不会拖垮整个应用
+func (s *TCPService) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+	defer conn.Close()
+	defer handlePanic(s.logger, s.name, nil)
+
+	s.handler(netx.GetContext(conn), conn)
+}
+
+// track 登记新连接及其 handler，Stop 开始之后返回 false。
+// 登记与 Stop 共用同一把锁，保证 WaitGroup.Add 不会与 Stop 中的 Wait 并发。
+func (s *TCPService) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *TCPService) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// Stop 关闭监听器并取消所有连接的 Context，等待 handler 返回。
+// ctx 超时后强制关闭剩余连接，并计入 appx_shutdown_dropped_total{kind="connection"}。
+func (s *TCPService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	s.stopping = true
+	ln, cancel := s.listener, s.cancel
+	s.mu.Unlock()
+	ln.Close()
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	n := len(s.conns)
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	if n > 0 {
+		shutdownDroppedTotal.WithLabelValues(droppedConnection).Add(float64(n))
+		if s.logger != nil {
+			s.logger.Warn().Int("connections", n).Str("name", s.name).Msg("Forcibly closing connections after shutdown timeout")
+		}
+	}
+	return ctx.Err()
+}
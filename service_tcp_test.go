@@ -0,0 +1,113 @@
+package appx
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoHandler 是一个简单的行协议：逐行回显，收到 "panic" 时触发 panic
+func echoHandler(ctx context.Context, conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "panic" {
+			panic("boom")
+		}
+		conn.Write([]byte(strings.ToUpper(line) + "\n"))
+	}
+}
+
+func TestTCPService_Echo(t *testing.T) {
+	logger := zerolog.Nop()
+	svc := NewTCPService("echo", "127.0.0.1:0", echoHandler).WithLogger(&logger)
+	var fatal error
+	svc.SetErrorNotify(func(err error) { fatal = err })
+	require.NoError(t, svc.Start(context.Background()))
+	addr := svc.listener.Addr().String()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	_, err = conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", line)
+
+	// handler 的 panic 只关闭所在连接，服务继续接收新连接
+	panics := testutil.ToFloat64(servicePanicTotal.WithLabelValues("echo"))
+	_, err = conn.Write([]byte("panic\n"))
+	require.NoError(t, err)
+	_, err = r.ReadString('\n')
+	assert.Error(t, err)
+	assert.Equal(t, panics+1, testutil.ToFloat64(servicePanicTotal.WithLabelValues("echo")))
+	assert.NoError(t, fatal)
+
+	conn2, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn2.Close()
+	_, err = conn2.Write([]byte("again\n"))
+	require.NoError(t, err)
+	line, err = bufio.NewReader(conn2).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "AGAIN\n", line)
+
+	// Stop 关闭监听器，空闲连接上的 handler 在 Stop 超时后被强制关闭
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
+
+func TestTCPService_StopCancelsContext(t *testing.T) {
+	logger := zerolog.Nop()
+	accepted := make(chan struct{})
+	svc := NewTCPService("ctx", "127.0.0.1:0", func(ctx context.Context, conn net.Conn) {
+		close(accepted)
+		<-ctx.Done()
+	}).WithLogger(&logger)
+	require.NoError(t, svc.Start(context.Background()))
+
+	conn, err := net.Dial("tcp", svc.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	<-accepted
+
+	// handler 观察到 ctx 取消后返回，Stop 无需等到超时
+	assert.NoError(t, svc.Stop(context.Background()))
+}
+
+func TestTCPService_Restart(t *testing.T) {
+	logger := zerolog.Nop()
+	svc := NewTCPService("restart", "127.0.0.1:0", echoHandler).WithLogger(&logger)
+	require.NoError(t, svc.Start(context.Background()))
+	assert.Error(t, svc.Start(context.Background()), "Start while running")
+
+	require.NoError(t, svc.Stop(context.Background()))
+	assert.NoError(t, svc.Stop(context.Background()), "repeated Stop is a no-op")
+
+	// Stop 之后再次 Start，新连接照常处理
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+	conn, err := net.Dial("tcp", svc.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", line)
+}
@@ -0,0 +1,99 @@
+package appx
+
+import (
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// VirtualHostService 在同一个监听端口上按 Host 请求头托管多个逻辑应用。
+// 监听、TLS 与可观测性等能力完全复用 HttpService (可直接调用其 WithX 方法)；
+// 配合 cert.Config.Certificates 按 SNI 选择证书，可以把多个小服务合并到同一个 TLS 入口之后。
+//
+//	vh := appx.NewVirtualHostService(":443").
+//		Host("api.example.com", apiHandler).
+//		Host("*.example.com", tenantHandler).
+//		Default(http.NotFoundHandler())
+//	app.Add(vh.WithTLS(certMgr))
+type VirtualHostService struct {
+	*HttpService
+
+	mu    sync.Mutex // 串行化注册
+	hosts atomic.Pointer[vhostTable]
+}
+
+// vhostTable 是按 Host 索引的 Handler 表，注册时整体替换 (copy-on-write)，请求路径上无需加锁
+type vhostTable struct {
+	exact map[string]http.Handler
+	// wildcard 的 key 为 "*.example.com" 去掉 "*." 后的父域名
+	wildcard map[string]http.Handler
+	fallback http.Handler
+}
+
+// NewVirtualHostService 创建在 addr 上监听的虚拟主机服务，服务名为 "vhost:" + addr。
+// 未匹配任何 Host 的请求交给 Default 设置的 Handler，未设置时返回 404。
+func NewVirtualHostService(addr string) *VirtualHostService {
+	vh := &VirtualHostService{}
+	vh.hosts.Store(&vhostTable{exact: map[string]http.Handler{}, wildcard: map[string]http.Handler{}})
+	vh.HttpService = NewHttpService("vhost:"+addr, addr, http.HandlerFunc(vh.serveHTTP))
+	return vh
+}
+
+// Host 将 host 的请求交给 handler。host 不区分大小写，不含端口；
+// "*.example.com" 匹配 example.com 下一级的任意子域名，精确匹配优先。可在 Start 之后调用。
+func (vh *VirtualHostService) Host(host string, handler http.Handler) *VirtualHostService {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	vh.update(func(t *vhostTable) {
+		if parent, ok := strings.CutPrefix(host, "*."); ok {
+			t.wildcard[parent] = handler
+		} else {
+			t.exact[host] = handler
+		}
+	})
+	return vh
+}
+
+// Default 设置未匹配任何 Host 时使用的 Handler
+func (vh *VirtualHostService) Default(handler http.Handler) *VirtualHostService {
+	vh.update(func(t *vhostTable) { t.fallback = handler })
+	return vh
+}
+
+// update 复制当前的 Handler 表，修改后整体替换
+func (vh *VirtualHostService) update(fn func(*vhostTable)) {
+	vh.mu.Lock()
+	defer vh.mu.Unlock()
+
+	old := vh.hosts.Load()
+	t := &vhostTable{exact: maps.Clone(old.exact), wildcard: maps.Clone(old.wildcard), fallback: old.fallback}
+	fn(t)
+	vh.hosts.Store(t)
+}
+
+func (vh *VirtualHostService) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if h := vh.hosts.Load().lookup(r.Host); h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// lookup 按 Host 请求头查找 Handler：精确匹配 > 通配符 > 默认
+func (t *vhostTable) lookup(host string) http.Handler {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if h, ok := t.exact[host]; ok {
+		return h
+	}
+	if i := strings.IndexByte(host, '.'); i > 0 {
+		if h, ok := t.wildcard[host[i+1:]]; ok {
+			return h
+		}
+	}
+	return t.fallback
+}
@@ -0,0 +1,54 @@
+package appx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVirtualHostService(t *testing.T) {
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, body) })
+	}
+	logger := zerolog.Nop()
+	vh := NewVirtualHostService("127.0.0.1:0").
+		Host("api.example.com", respond("api")).
+		Host("*.example.com", respond("tenant"))
+	require.NoError(t, vh.WithLogger(&logger).Start(context.Background()))
+	defer vh.Stop(context.Background())
+	assert.Equal(t, "vhost:127.0.0.1:0", vh.Name())
+
+	addr := vh.listener.Addr().String()
+	get := func(host string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/", nil)
+		require.NoError(t, err)
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	_, body := get("api.example.com")
+	assert.Equal(t, "api", body)
+	_, body = get("API.Example.com:8443")
+	assert.Equal(t, "api", body, "matching ignores case and port")
+	_, body = get("acme.example.com")
+	assert.Equal(t, "tenant", body)
+
+	code, _ := get("other.org")
+	assert.Equal(t, http.StatusNotFound, code, "unmatched hosts get 404 without a default")
+
+	// 运行期间注册的 Host 与默认 Handler 立即生效
+	vh.Host("other.org", respond("other")).Default(respond("default"))
+	_, body = get("other.org")
+	assert.Equal(t, "other", body)
+	_, body = get("a.b.example.com")
+	assert.Equal(t, "default", body, "wildcard covers a single label only")
+}
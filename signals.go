@@ -0,0 +1,116 @@
+package appx
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// defaultShutdownSignals 是未调用 WithSignals 时触发优雅关闭的信号
+var defaultShutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+
+// signalAction 是信号对应的处理方式
+type signalAction int
+
+const (
+	signalShutdown signalAction = iota
+	signalUpgrade
+	signalReload  // 触发 WithReload 注册的配置重载
+	signalHandler // 调用 WithReloadSignal 注册的回调
+)
+
+// signalActions 汇总所有需要监听的信号。同一信号被多处声明时，
+// 优先级为 WithReloadSignal > WithSignals (关闭) > 平滑升级 > WithReload。
+func (s *Appx) signalActions() map[os.Signal]signalAction {
+	actions := make(map[os.Signal]signalAction)
+	if s.reloadEnabled() {
+		for _, sig := range reloadSignals {
+			actions[sig] = signalReload
+		}
+	}
+	if s.gracefulUpgrade {
+		for _, sig := range upgradeSignals {
+			actions[sig] = signalUpgrade
+		}
+	}
+	shutdown := s.shutdownSignals
+	if shutdown == nil {
+		shutdown = defaultShutdownSignals
+	}
+	for _, sig := range shutdown {
+		actions[sig] = signalShutdown
+	}
+	for sig := range s.signalHandlers {
+		actions[sig] = signalHandler
+	}
+	return actions
+}
+
+// watchSignals 在一个 goroutine 中监听所有信号并分发：关闭与升级信号转发给 Run 的等待循环，
+// 重载信号触发 Reload，WithReloadSignal 的回调在各自的 goroutine 中执行 (执行期间重复的信号会被合并)，
+// 不会阻塞关闭信号的处理。返回的函数用于停止监听；ctx 结束时相关 goroutine 退出。
+func (s *Appx) watchSignals(ctx context.Context, quit, upgrade chan<- os.Signal) (stop func()) {
+	actions := s.signalActions()
+	if len(actions) == 0 {
+		return func() {}
+	}
+
+	triggers := make(map[os.Signal]chan struct{}, len(s.signalHandlers))
+	for sig, fn := range s.signalHandlers {
+		trigger := make(chan struct{}, 1)
+		triggers[sig] = trigger
+		go s.runSignalHandler(ctx, sig, fn, trigger)
+	}
+
+	sigs := make([]os.Signal, 0, len(actions))
+	for sig := range actions {
+		sigs = append(sigs, sig)
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				switch actions[sig] {
+				case signalShutdown:
+					forwardSignal(quit, sig)
+				case signalUpgrade:
+					forwardSignal(upgrade, sig)
+				case signalReload:
+					s.Reload()
+				case signalHandler:
+					forwardSignal(triggers[sig], struct{}{})
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() { signal.Stop(ch) }
+}
+
+// forwardSignal 非阻塞发送，接收方尚未处理上一个信号时丢弃
+func forwardSignal[T any](ch chan<- T, v T) {
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
+// runSignalHandler 串行执行 WithReloadSignal 注册的回调，错误只记录日志
+func (s *Appx) runSignalHandler(ctx context.Context, sig os.Signal, fn func() error, trigger <-chan struct{}) {
+	for {
+		select {
+		case <-trigger:
+			s.logger.Info().Str("signal", sig.String()).Msg("Signal received, running handler")
+			if err := fn(); err != nil {
+				s.logger.Error().Err(err).Str("signal", sig.String()).Msg("Signal handler failed, keep running")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
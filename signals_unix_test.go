@@ -0,0 +1,51 @@
+//go:build unix
+
+package appx
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppx_Signals(t *testing.T) {
+	logger := zerolog.Nop()
+	handled := make(chan struct{}, 4)
+	app := New(
+		WithLogger(&logger),
+		// SIGUSR1 同时出现在关闭信号中，回调优先
+		WithSignals(syscall.SIGUSR1, syscall.SIGUSR2),
+		WithReloadSignal(syscall.SIGUSR1, func() error {
+			handled <- struct{}{}
+			return errors.New("handler failure is only logged")
+		}),
+	)
+	app.Add(&MockService{name: "svc"})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	for range 2 {
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+		select {
+		case <-handled:
+		case <-time.After(2 * time.Second):
+			t.Fatal("signal handler was not invoked")
+		}
+	}
+	assert.False(t, app.IsShuttingDown(), "handler signals and errors must not shut the app down")
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("custom shutdown signal did not stop the app")
+	}
+}
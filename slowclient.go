@@ -0,0 +1,77 @@
+package appx
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/oy3o/netx"
+)
+
+// defaultSlowClientGrace 是慢速客户端防护在吞吐量预算之外额外容忍的时间
+const defaultSlowClientGrace = 10 * time.Second
+
+// slowClientGuard 返回一个 netx 中间件，为每次写入设置与数据量成正比的写超时：
+// grace + len(p)/minBytesPerSec。客户端读取过慢 (slow-read) 导致写入超时时，连接被中止。
+// 每次写入都会重新计算超时，因此长时间的大文件下载只要平均速率不低于下限就不受影响，
+// grace 用于吸收网络抖动造成的短暂停顿。
+func slowClientGuard(service string, minBytesPerSec float64, grace time.Duration) netx.Middleware {
+	return func(l net.Listener) net.Listener {
+		return &slowClientListener{Listener: l, service: service, rate: minBytesPerSec, grace: grace}
+	}
+}
+
+type slowClientListener struct {
+	net.Listener
+	service string
+	rate    float64
+	grace   time.Duration
+}
+
+func (l *slowClientListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &slowClientConn{Conn: c, l: l}, nil
+}
+
+type slowClientConn struct {
+	net.Conn
+	l *slowClientListener
+	// manual 为 true 表示上层 (如 http.ResponseController、WebSocket 库) 显式设置了写超时，此时不再覆盖
+	manual atomic.Bool
+}
+
+func (c *slowClientConn) Write(p []byte) (int, error) {
+	if c.manual.Load() {
+		return c.Conn.Write(p)
+	}
+	budget := c.l.grace + time.Duration(float64(len(p))/c.l.rate*float64(time.Second))
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(budget)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(p)
+	if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		slowClientAbortTotal.WithLabelValues(c.l.service).Inc()
+		c.Conn.Close()
+	}
+	return n, err
+}
+
+func (c *slowClientConn) SetDeadline(t time.Time) error {
+	c.manual.Store(!t.IsZero())
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *slowClientConn) SetWriteDeadline(t time.Time) error {
+	c.manual.Store(!t.IsZero())
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// Unwrap 实现 netx.Wrapper，以便 netx.GetContext / AsTCPConn 穿透
+func (c *slowClientConn) Unwrap() net.Conn {
+	return c.Conn
+}
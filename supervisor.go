@@ -0,0 +1,111 @@
+package appx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Restartable 是一个可选接口。
+// 配置了 WithRestartPolicy 时，实现此接口的服务通过 ErrorNotifier 报告错误后，
+// 若 ShouldRestart(err) 返回 true，Appx 会先 Stop 再 Start 该服务，而不是关闭整个应用；
+// 重启次数超出预算后才升级为整体关闭。实现需保证 Stop 之后可以再次 Start。
+type Restartable interface {
+	ShouldRestart(err error) bool
+}
+
+// restartPolicy 是服务重启策略
+type restartPolicy struct {
+	maxRestarts int
+	backoff     time.Duration
+}
+
+// resetAfter 返回重启预算重置所需的稳定运行时间，即策略中最长的一次退避
+func (p restartPolicy) resetAfter() time.Duration {
+	return p.backoff << p.maxRestarts
+}
+
+// restartState 是单个服务的重启状态
+type restartState struct {
+	mu        sync.Mutex
+	restarts  int
+	lastStart time.Time
+}
+
+// notifierFor 返回注入给服务的错误回调：受监管的服务得到带重启策略的回调，其余服务直接触发关闭
+func (s *Appx) notifierFor(svc Service) ErrorNotifier {
+	r, ok := svc.(Restartable)
+	if !ok || s.restartPolicy == nil {
+		return s.notifyFatalError
+	}
+
+	st := &restartState{lastStart: time.Now()}
+	var notify ErrorNotifier
+	notify = func(err error) {
+		if s.inShutdown.Load() || !r.ShouldRestart(err) {
+			s.notifyFatalError(err)
+			return
+		}
+
+		st.mu.Lock()
+		if time.Since(st.lastStart) >= s.restartPolicy.resetAfter() {
+			// 上次重启后已稳定运行足够长的时间，视为新的故障
+			st.restarts = 0
+		}
+		if st.restarts >= s.restartPolicy.maxRestarts {
+			st.mu.Unlock()
+			s.logger.Error().Err(err).Str("name", svc.Name()).Int("restarts", st.restarts).
+				Msg("Service restart budget exhausted, shutting down")
+			s.notifyFatalError(err)
+			return
+		}
+		delay := s.restartPolicy.backoff << st.restarts
+		st.restarts++
+		attempt := st.restarts
+		st.mu.Unlock()
+
+		s.logger.Warn().Err(err).Str("name", svc.Name()).Int("attempt", attempt).Dur("backoff", delay).
+			Msg("Service failed, restarting")
+		// 回调可能在服务自身的 goroutine 中被调用 (Stop 会等待它退出)，因此异步重启
+		go s.restartService(svc, delay, st, notify)
+	}
+	return notify
+}
+
+// restartService 在退避后重启服务，再次启动失败时按同一策略继续处理
+func (s *Appx) restartService(svc Service, delay time.Duration, st *restartState, notify ErrorNotifier) {
+	select {
+	case <-time.After(delay):
+	case <-s.ctx.Done():
+		return
+	}
+
+	// 持有 mu 检查关闭状态并登记重启：关闭流程开始之后不会再有新的重启，
+	// 已登记的重启由关闭流程等待其结束后再停止服务。Stop 与 Start 在锁外执行，不阻塞其他服务的登记
+	s.mu.Lock()
+	if s.inShutdown.Load() {
+		s.mu.Unlock()
+		return
+	}
+	s.pending.Add(1)
+	s.mu.Unlock()
+	defer s.pending.Done()
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	if err := svc.Stop(stopCtx); err != nil {
+		s.logger.Warn().Err(err).Str("name", svc.Name()).Msg("Service stop error before restart")
+	}
+	cancel()
+
+	err := svc.Start(s.ctx)
+	if err != nil {
+		notify(err)
+		return
+	}
+
+	serviceRestartTotal.WithLabelValues(svc.Name()).Inc()
+	st.mu.Lock()
+	st.lastStart = time.Now()
+	st.mu.Unlock()
+	s.logger.Info().Str("name", svc.Name()).Msg("Service restarted")
+}
@@ -0,0 +1,151 @@
+package appx
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTransient = errors.New("connection lost")
+
+// pollerService 是一个可重启的服务，只对 errTransient 请求重启
+type pollerService struct {
+	MockService
+	starts atomic.Int32
+	stops  atomic.Int32
+}
+
+func newPollerService(name string) *pollerService {
+	p := &pollerService{MockService: MockService{name: name}}
+	p.startFunc = func(context.Context) error { p.starts.Add(1); return nil }
+	p.stopFunc = func(context.Context) error { p.stops.Add(1); return nil }
+	return p
+}
+
+func (p *pollerService) ShouldRestart(err error) bool { return errors.Is(err, errTransient) }
+
+// failAndWaitRestart 报告可重启的错误并等待服务被重新启动
+func (p *pollerService) failAndWaitRestart(t *testing.T) {
+	t.Helper()
+	want := p.starts.Load() + 1
+	p.errHandler(errTransient)
+	require.Eventually(t, func() bool { return p.starts.Load() == want }, time.Second, time.Millisecond)
+}
+
+func TestAppx_RestartPolicy_BudgetExhausted(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithRestartPolicy(2, 20*time.Millisecond))
+	svc := newPollerService("poller")
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	before := testutil.ToFloat64(serviceRestartTotal.WithLabelValues("poller"))
+	svc.failAndWaitRestart(t)
+	svc.failAndWaitRestart(t)
+	assert.Equal(t, before+2, testutil.ToFloat64(serviceRestartTotal.WithLabelValues("poller")))
+
+	// 预算耗尽后升级为整体关闭
+	svc.errHandler(errTransient)
+	select {
+	case err := <-runErr:
+		assert.ErrorIs(t, err, errTransient)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after restart budget was exhausted")
+	}
+	assert.Equal(t, int32(3), svc.starts.Load())
+}
+
+func TestAppx_RestartPolicy_NonRetryableError(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithRestartPolicy(3, 10*time.Millisecond))
+	svc := newPollerService("poller")
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	svc.errHandler(errors.New("invalid credentials"))
+	assert.EqualError(t, <-runErr, "invalid credentials")
+	assert.Equal(t, int32(1), svc.starts.Load())
+}
+
+func TestAppx_RestartPolicy_BackoffReset(t *testing.T) {
+	logger := zerolog.Nop()
+	// 预算为 1 次，稳定运行 40ms (20ms << 1) 后重置
+	app := New(WithLogger(&logger), WithRestartPolicy(1, 20*time.Millisecond))
+	svc := newPollerService("poller")
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	svc.failAndWaitRestart(t)
+	// 健康运行一段时间后再次故障，预算已重置，仍然会重启
+	time.Sleep(100 * time.Millisecond)
+	svc.failAndWaitRestart(t)
+
+	// 紧接着的故障超出预算
+	svc.errHandler(errTransient)
+	assert.ErrorIs(t, <-runErr, errTransient)
+	assert.Equal(t, int32(3), svc.starts.Load())
+}
+
+func TestAppx_RestartPolicy_ShutdownWaitsForRestart(t *testing.T) {
+	logger := zerolog.Nop()
+	app := New(WithLogger(&logger), WithRestartPolicy(3, 10*time.Millisecond))
+	svc := newPollerService("poller")
+	stopping := make(chan struct{})
+	release := make(chan struct{})
+	var inStop atomic.Int32
+	svc.stopFunc = func(context.Context) error {
+		if inStop.Add(1) > 1 {
+			t.Error("Stop called concurrently")
+		}
+		defer inStop.Add(-1)
+		// 重启时的 Stop 阻塞，直到测试放行
+		if svc.stops.Add(1) == 1 {
+			close(stopping)
+			<-release
+		}
+		return nil
+	}
+	app.Add(svc)
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run() }()
+	<-app.Ready()
+
+	svc.errHandler(errTransient)
+	<-stopping
+	go app.Shutdown(context.Background())
+
+	// 关闭流程等待重启结束后才停止服务
+	select {
+	case err := <-runErr:
+		t.Fatalf("Run returned during restart: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, int32(1), svc.stops.Load())
+
+	close(release)
+	select {
+	case err := <-runErr:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after restart finished")
+	}
+	assert.Equal(t, int32(2), svc.starts.Load())
+	assert.Equal(t, int32(2), svc.stops.Load())
+}
@@ -196,3 +196,47 @@ func TestE2E_FullFlow(t *testing.T) {
 		t.Fatal("Appx failed to shutdown in time")
 	}
 }
+
+// TestE2E_BaseContext 验证取消注入的根 Context 会像收到 SIGTERM 一样优雅关闭，并释放监听端口
+func TestE2E_BaseContext(t *testing.T) {
+	port, err := getFreePort()
+	require.NoError(t, err)
+	addr := fmt.Sprintf("localhost:%d", port)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	logger := zerolog.Nop()
+	app := appx.New(
+		appx.WithLogger(&logger),
+		appx.WithShutdownTimeout(2*time.Second),
+		appx.WithBaseContext(ctx),
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+	app.Add(appx.NewHttpService("base-ctx-api", addr, mux))
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- app.Run()
+	}()
+	<-app.Ready()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/ping", addr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// 取消根 Context 触发优雅关闭
+	stop()
+	select {
+	case err := <-errChan:
+		assert.NoError(t, err, "Appx should exit gracefully")
+	case <-time.After(3 * time.Second):
+		t.Fatal("Appx failed to shutdown in time")
+	}
+
+	_, err = net.DialTimeout("tcp", addr, 100*time.Millisecond)
+	assert.Error(t, err, "listener should be closed after shutdown")
+}
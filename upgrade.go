@@ -0,0 +1,228 @@
+package appx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envInheritedListeners 记录父进程在平滑升级时传递给子进程的监听器。
+	// 格式: "<service>/<key>=<fd>,<service>/<key>=<fd>"
+	envInheritedListeners = "APPX_INHERITED_LISTENERS"
+	// envUpgradeReadyFD 是子进程通知父进程 "已就绪" 的管道写端 FD
+	envUpgradeReadyFD = "APPX_UPGRADE_READY_FD"
+	// defaultUpgradeTimeout 是等待新进程就绪的默认时间
+	defaultUpgradeTimeout = 30 * time.Second
+)
+
+// Upgradable 是一个可选接口。
+// 如果 Service 实现了此接口，在平滑升级 (WithGracefulUpgrade) 时，
+// Appx 会将其返回的监听器文件描述符通过 ExtraFiles 传递给新进程。
+// key 用于区分同一服务的多个监听器 (例如 "tcp" 与 "udp")。
+type Upgradable interface {
+	ListenerFiles() (map[string]*os.File, error)
+}
+
+// upgradeCommitter 由需要在新进程接管监听器后调整关闭行为的服务实现 (如 unix socket 不再删除套接字文件)。
+// 只在新进程报告就绪后调用，升级中止时服务保持原有的关闭行为。
+type upgradeCommitter interface {
+	commitUpgrade()
+}
+
+// commitUpgrade 通知服务新进程已接管监听器
+func (s *Appx) commitUpgrade() {
+	for _, svc := range s.snapshotServices() {
+		if c, ok := svc.(upgradeCommitter); ok {
+			c.commitUpgrade()
+		}
+	}
+}
+
+var (
+	inheritedOnce sync.Once
+	// inheritedMu 保护 inheritedFiles：多个服务的 Start 可能并发执行 (AddAndStart、Supervisor 重启)
+	inheritedMu    sync.Mutex
+	inheritedFiles map[string]*os.File
+)
+
+// inheritedFile 返回父进程传递的监听器文件 (如果存在)。
+// 每个文件只能被取走一次，防止重复使用同一个 FD。并发安全。
+func inheritedFile(service, key string) *os.File {
+	inheritedOnce.Do(func() {
+		inheritedFiles = parseInheritedListeners(os.Getenv(envInheritedListeners))
+	})
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	id := service + "/" + key
+	f, ok := inheritedFiles[id]
+	if !ok {
+		return nil
+	}
+	delete(inheritedFiles, id)
+	return f
+}
+
+// parseInheritedListeners 解析环境变量中的监听器映射
+func parseInheritedListeners(v string) map[string]*os.File {
+	files := make(map[string]*os.File)
+	if v == "" {
+		return files
+	}
+	for _, entry := range strings.Split(v, ",") {
+		id, fdStr, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		fd, err := strconv.Atoi(fdStr)
+		if err != nil || fd < 3 {
+			continue
+		}
+		files[id] = os.NewFile(uintptr(fd), id)
+	}
+	return files
+}
+
+// inheritedListener 尝试从父进程继承 TCP (或 Unix) 监听器
+func inheritedListener(service, key string) (net.Listener, error) {
+	f := inheritedFile(service, key)
+	if f == nil {
+		return nil, nil
+	}
+	defer f.Close() // FileListener 内部会 dup 一份 FD
+	return net.FileListener(f)
+}
+
+// inheritedPacketConn 尝试从父进程继承 UDP 连接
+func inheritedPacketConn(service, key string) (net.PacketConn, error) {
+	f := inheritedFile(service, key)
+	if f == nil {
+		return nil, nil
+	}
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+// upgradeChild 是平滑升级中启动的新进程
+type upgradeChild struct {
+	cmd   *exec.Cmd
+	ready *os.File // 就绪管道的读端，子进程写入一个字节表示所有服务已启动
+}
+
+func (c *upgradeChild) pid() int { return c.cmd.Process.Pid }
+
+// startUpgradeChild 为 cmd 附加就绪管道 (作为最后一个 ExtraFiles) 并启动子进程
+func startUpgradeChild(cmd *exec.Cmd) (*upgradeChild, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close() // 子进程持有写端的副本，父进程必须关闭自己的，子进程退出时读端才能收到 EOF
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", envUpgradeReadyFD, 2+len(cmd.ExtraFiles)))
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &upgradeChild{cmd: cmd, ready: r}, nil
+}
+
+// waitReady 等待子进程报告就绪。子进程在就绪前退出 (如配置错误、安全自检失败) 或超时未就绪时，
+// 终止并回收子进程后返回错误，父进程继续服务。
+func (c *upgradeChild) waitReady(timeout time.Duration) error {
+	defer c.ready.Close()
+	c.ready.SetReadDeadline(time.Now().Add(timeout))
+	_, err := c.ready.Read(make([]byte, 1))
+	if err == nil {
+		return nil
+	}
+
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	switch {
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("new process %d exited before becoming ready", c.pid())
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return fmt.Errorf("new process %d not ready within %s", c.pid(), timeout)
+	default:
+		return fmt.Errorf("wait for new process %d: %w", c.pid(), err)
+	}
+}
+
+// notifyUpgradeReady 在平滑升级启动的子进程中通知父进程：所有服务已启动，父进程可以开始排空。
+// 非升级启动的进程没有对应的环境变量，直接返回。
+func notifyUpgradeReady() {
+	v := os.Getenv(envUpgradeReadyFD)
+	if v == "" {
+		return
+	}
+	os.Unsetenv(envUpgradeReadyFD)
+	fd, err := strconv.Atoi(v)
+	if err != nil || fd < 3 {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+// forkChild 启动一个新的进程 (当前可执行文件)，并将所有 Upgradable 服务的监听器交给它。
+// 返回的子进程需要通过 waitReady 确认就绪后，父进程才能开始关闭。
+func (s *Appx) forkChild() (*upgradeChild, error) {
+	var (
+		files   []*os.File
+		entries []string
+	)
+	defer func() {
+		// 子进程已持有 dup 后的 FD，父进程这边的副本可以关闭
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, svc := range s.snapshotServices() {
+		up, ok := svc.(Upgradable)
+		if !ok {
+			continue
+		}
+		fs, err := up.ListenerFiles()
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name(), err)
+		}
+		for key, f := range fs {
+			// ExtraFiles[i] 在子进程中的 FD 为 3+i
+			entries = append(entries, fmt.Sprintf("%s/%s=%d", svc.Name(), key, 3+len(files)))
+			files = append(files, f)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envInheritedListeners+"=") && !strings.HasPrefix(kv, envUpgradeReadyFD+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, envInheritedListeners+"="+strings.Join(entries, ","))
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.ExtraFiles = files
+	return startUpgradeChild(cmd)
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package appx
+
+import "os"
+
+// 非 Unix 平台不支持基于信号的平滑升级
+var upgradeSignals []os.Signal
+
+// 非 Unix 平台没有 SIGHUP，只能通过 Appx.Reload 触发配置重载
+var reloadSignals []os.Signal
@@ -0,0 +1,14 @@
+//go:build unix
+
+package appx
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals 触发平滑升级的信号
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// reloadSignals 触发配置重载的信号
+var reloadSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,147 @@
+//go:build unix
+
+package appx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeChild_WaitReady(t *testing.T) {
+	// 子进程写入就绪管道
+	child, err := startUpgradeChild(exec.Command("sh", "-c", `eval "printf x >&$`+envUpgradeReadyFD+`"; sleep 5`))
+	require.NoError(t, err)
+	defer func() {
+		child.cmd.Process.Kill()
+		child.cmd.Wait()
+	}()
+	assert.NoError(t, child.waitReady(5*time.Second))
+
+	// 就绪前退出 (如安全自检失败) 时放弃升级
+	crashed, err := startUpgradeChild(exec.Command("sh", "-c", "exit 1"))
+	require.NoError(t, err)
+	assert.ErrorContains(t, crashed.waitReady(5*time.Second), "exited before becoming ready")
+
+	// 超时未就绪时终止并回收子进程
+	hung, err := startUpgradeChild(exec.Command("sh", "-c", "sleep 5"))
+	require.NoError(t, err)
+	start := time.Now()
+	assert.ErrorContains(t, hung.waitReady(100*time.Millisecond), "not ready within")
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.NotNil(t, hung.cmd.ProcessState, "child is reaped")
+}
+
+func TestNotifyUpgradeReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	// notifyUpgradeReady 会关闭 FD，交给它一份独立的副本
+	fd, err := syscall.Dup(int(w.Fd()))
+	require.NoError(t, err)
+	w.Close()
+
+	t.Setenv(envUpgradeReadyFD, strconv.Itoa(fd))
+	notifyUpgradeReady()
+	_, ok := os.LookupEnv(envUpgradeReadyFD)
+	assert.False(t, ok, "env is cleared so later children do not inherit it")
+
+	buf := make([]byte, 2)
+	n, err := r.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	// 写端已关闭
+	_, err = r.Read(buf)
+	assert.Error(t, err)
+}
+
+func TestHttpService_InheritedListener(t *testing.T) {
+	// 模拟父进程：创建监听器并导出 FD
+	parent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer parent.Close()
+	addr := parent.Addr().String()
+
+	f, err := parent.(*net.TCPListener).File()
+	require.NoError(t, err)
+	// inheritedListener 会关闭导出的 FD，交给它一份独立的副本，避免同一 FD 号被关闭两次
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	require.NoError(t, err)
+
+	t.Setenv(envInheritedListeners, fmt.Sprintf("inherit-svc/tcp=%d", fd))
+	inheritedOnce = sync.Once{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("inherited"))
+	})
+	// addr 故意写成不可用的端口，验证确实使用了继承的 FD
+	svc := NewHttpService("inherit-svc", "127.0.0.1:1", handler).WithLogger(&zerolog.Logger{})
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop(context.Background())
+
+	assert.Equal(t, addr, svc.rawListener.Addr().String())
+
+	resp, err := http.Get("http://" + addr)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "inherited", string(body))
+
+	// 导出的 FD 可供下一代进程继续使用
+	files, err := svc.ListenerFiles()
+	require.NoError(t, err)
+	require.Contains(t, files, "tcp")
+	files["tcp"].Close()
+}
+
+func TestHttpService_UnixSocketUpgrade(t *testing.T) {
+	newService := func(sock string) *HttpService {
+		svc := NewHttpService("unix-upgrade", "", http.NotFoundHandler()).WithUnixSocket(sock, 0o660).WithLogger(&zerolog.Logger{})
+		require.NoError(t, svc.Start(context.Background()))
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+			},
+		}}
+		resp, err := client.Get("http://unix/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		return svc
+	}
+
+	// 升级中止 (只导出了 FD)：Stop 仍然删除套接字文件
+	sock := filepath.Join(t.TempDir(), "abort.sock")
+	svc := newService(sock)
+	files, err := svc.ListenerFiles()
+	require.NoError(t, err)
+	files["tcp"].Close()
+	require.NoError(t, svc.Stop(context.Background()))
+	_, err = os.Stat(sock)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// 新进程就绪后：套接字文件留给新进程
+	sock = filepath.Join(t.TempDir(), "commit.sock")
+	svc = newService(sock)
+	files, err = svc.ListenerFiles()
+	require.NoError(t, err)
+	defer files["tcp"].Close()
+	svc.commitUpgrade()
+	require.NoError(t, svc.Stop(context.Background()))
+	_, err = os.Stat(sock)
+	assert.NoError(t, err)
+}